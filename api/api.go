@@ -12,6 +12,8 @@ import (
 
 	"github.com/RTradeLtd/Temporal/api/middleware"
 	"github.com/RTradeLtd/Temporal/database"
+	"github.com/RTradeLtd/Temporal/models"
+	"github.com/RTradeLtd/Temporal/queue"
 	jwt "github.com/appleboy/gin-jwt"
 	helmet "github.com/danielkov/gin-helmet"
 	"github.com/jinzhu/gorm"
@@ -24,14 +26,22 @@ import (
 
 var xssMdlwr xss.XssMw
 
-// AdminAddress is the eth address of the admin account
-var AdminAddress = "0x7E4A2359c745A982a54653128085eAC69E446DE1"
-
 //const experimental = true
 
 // Setup is used to initialize our api.
 // it invokes all  non exported function to setup the api.
 func Setup(cfg *config.TemporalConfig) *gin.Engine {
+	publisher, err := queue.NewPublisher(cfg.RabbitMQ.URL, queue.DefaultPublisherPoolSize)
+	if err != nil {
+		fmt.Println("failed to connect to rabbitmq")
+		log.Fatal(err)
+	}
+	return SetupWithPublisher(cfg, publisher)
+}
+
+// SetupWithPublisher is used to initialize our api, publishing queue messages
+// with the given publisher instead of connecting to rabbitmq
+func SetupWithPublisher(cfg *config.TemporalConfig, publisher queue.MessagePublisher) *gin.Engine {
 	dbPass := cfg.Database.Password
 	dbURL := cfg.Database.URL
 	dbUser := cfg.Database.Username
@@ -43,6 +53,12 @@ func Setup(cfg *config.TemporalConfig) *gin.Engine {
 		log.Fatal(err)
 	}
 	db.LogMode(true)
+	um := models.NewUserManager(db)
+	for _, v := range cfg.API.Admins {
+		if err = um.GrantRole(v, models.RoleAdmin); err != nil {
+			fmt.Printf("failed to grant admin role to %s %s\n", v, err)
+		}
+	}
 	apiURL := fmt.Sprintf("%s:6768", listenAddress)
 	r := gin.Default()
 	r.Use(stats.RequestStats())
@@ -61,29 +77,30 @@ func Setup(cfg *config.TemporalConfig) *gin.Engine {
 	//r.Use(middleware.DatabaseMiddleware(db))
 	r.Use(middleware.CORSMiddleware())
 	authMiddleware := middleware.JwtConfigGenerate(jwtKey, db)
+	keys, err := middleware.NewKeyRing(db, cfg)
+	if err != nil {
+		fmt.Println("failed to load jwt signing keys")
+		log.Fatal(err)
+	}
+	go keys.StartRotation()
+	limiter := middleware.NewRateLimiter(db, cfg)
 
-	setupRoutes(r, authMiddleware, db, cfg)
+	setupRoutes(r, authMiddleware, keys, limiter, db, cfg, publisher)
 
 	statsProtected := r.Group("/api/v1/statistics")
-	statsProtected.Use(authMiddleware.MiddlewareFunc())
+	statsProtected.Use(middleware.AuthMiddleware(authMiddleware, keys, db))
 	statsProtected.Use(middleware.APIRestrictionMiddleware(db))
-	statsProtected.GET("/stats", func(c *gin.Context) { // admin locked
-		ethAddress := GetAuthenticatedUserFromContext(c)
-		if ethAddress != AdminAddress {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error": "unauthorized access",
-			})
-			return
-		}
+	statsProtected.Use(middleware.TwoFactorEnrollmentMiddleware(db, cfg.API.RequireTwoFactorForEnterprise))
+	statsProtected.Use(middleware.PermissionMiddleware(db, models.PermissionViewStatistics))
+	statsProtected.GET("/stats", func(c *gin.Context) {
 		c.JSON(http.StatusOK, stats.Report())
 	})
 	return r
 }
 
 // setupRoutes is used to setup all of our api routes
-func setupRoutes(g *gin.Engine, authWare *jwt.GinJWTMiddleware, db *gorm.DB, cfg *config.TemporalConfig) {
+func setupRoutes(g *gin.Engine, authWare *jwt.GinJWTMiddleware, keys *middleware.KeyRing, limiter *middleware.RateLimiter, db *gorm.DB, cfg *config.TemporalConfig, publisher queue.MessagePublisher) {
 
-	mqConnectionURL := cfg.RabbitMQ.URL
 	ethKey := cfg.Ethereum.Account.KeyFile
 	ethPass := cfg.Ethereum.Account.KeyPass
 	awsKey := cfg.AWS.KeyID
@@ -91,94 +108,146 @@ func setupRoutes(g *gin.Engine, authWare *jwt.GinJWTMiddleware, db *gorm.DB, cfg
 	endpoint := fmt.Sprintf("%s:%s", cfg.MINIO.Connection.IP, cfg.MINIO.Connection.Port)
 	minioKey := cfg.MINIO.AccessKey
 	minioSecret := cfg.MINIO.SecretKey
+	_, gracePeriod, err := queue.RetentionSettings(cfg)
+	if err != nil {
+		fmt.Println("failed to load retention settings")
+		log.Fatal(err)
+	}
 
 	// LOGIN
 	g.Use(middleware.DatabaseMiddleware(db))
-	g.POST("/api/v1/login", authWare.LoginHandler)
+	g.POST("/api/v1/login", middleware.RabbitMQMiddleware(publisher), LoginHandler(authWare, keys))
+	g.POST("/api/v1/login/refresh", RefreshSessionHandler(authWare, keys))
+	g.POST("/api/v1/login/wallet/challenge", CreateLoginChallenge)
+	g.POST("/api/v1/login/wallet", middleware.RabbitMQMiddleware(publisher), WalletLoginHandler(authWare, keys))
+	// the public keys tokens are signed with, so other services can verify them
+	g.GET("/.well-known/jwks.json", GetJWKS(keys))
 	// REGISTER
-	g.POST("/api/v1/register", RegisterUserAccount)
+	g.POST("/api/v1/register", middleware.RegistrationThrottleMiddleware(db), middleware.RabbitMQMiddleware(publisher), RegisterUserAccount)
+	g.POST("/api/v1/register/wallet", middleware.RegistrationThrottleMiddleware(db), middleware.RabbitMQMiddleware(publisher), RegisterUserAccountWithWallet)
+	// EMAIL VERIFICATION AND PASSWORD RESETS
+	g.POST("/api/v1/verify/email", VerifyEmailAddress)
+	g.POST("/api/v1/verify/email/resend", middleware.RabbitMQMiddleware(publisher), ResendEmailVerification)
+	g.POST("/api/v1/password/reset", middleware.RabbitMQMiddleware(publisher), RequestPasswordReset)
+	g.POST("/api/v1/password/reset/confirm", ConfirmPasswordReset)
 	//g.POST("/api/v1/register-enterprise", RegisterEnterpriseUserAccount)
 
 	// PROTECTED ROUTES -- BEGIN
+	// each request is limited by the class of its route
+	uploadLimit := middleware.RateLimitMiddleware(limiter, models.RateLimitClassUpload)
+	pinLimit := middleware.RateLimitMiddleware(limiter, models.RateLimitClassPin)
+	ipnsLimit := middleware.RateLimitMiddleware(limiter, models.RateLimitClassIPNS)
+	readLimit := middleware.RateLimitMiddleware(limiter, models.RateLimitClassRead)
+	// api keys need the read-only scope, or the scope of the route, to make read requests
+	readOnly := middleware.APIKeyScopeMiddleware(models.APIKeyScopeReadOnly)
 	accountProtected := g.Group("/api/v1/account")
-	accountProtected.Use(authWare.MiddlewareFunc())
+	accountProtected.Use(middleware.JWTMiddleware(authWare, keys))
 	accountProtected.Use(middleware.APIRestrictionMiddleware(db))
 	accountProtected.Use(middleware.DatabaseMiddleware(db))
 	accountProtected.POST("password/change", ChangeAccountPassword)
 	accountProtected.POST("/key/ipfs/new", CreateIPFSKey)
 	accountProtected.GET("/key/ipfs/get", GetIPFSKeyNamesForAuthUser)
+	accountProtected.POST("/key/api/new", CreateAPIKey)
+	accountProtected.GET("/key/api/get", GetAPIKeysForAuthUser)
+	accountProtected.DELETE("/key/api/revoke/:id", RevokeAPIKey)
+	accountProtected.POST("/two-factor/enroll", EnrollTwoFactor)
+	accountProtected.POST("/two-factor/confirm", ConfirmTwoFactor)
+	accountProtected.POST("/two-factor/disable", DisableTwoFactor)
+	accountProtected.POST("/two-factor/recovery-codes", RegenerateRecoveryCodes)
+	accountProtected.GET("/sessions", GetSessionsForAuthUser)
+	accountProtected.DELETE("/sessions", RevokeAllSessions)
+	accountProtected.DELETE("/sessions/:id", RevokeSession)
+	accountProtected.POST("/logout", Logout)
 
 	ipfsProtected := g.Group("/api/v1/ipfs")
-	ipfsProtected.Use(authWare.MiddlewareFunc())
+	ipfsProtected.Use(middleware.AuthMiddleware(authWare, keys, db))
 	ipfsProtected.Use(middleware.APIRestrictionMiddleware(db))
+	ipfsProtected.Use(middleware.TwoFactorEnrollmentMiddleware(db, cfg.API.RequireTwoFactorForEnterprise))
 	// DATABASE-LESS routes
-	ipfsProtected.POST("/pubsub/publish/:topic", IpfsPubSubPublish) // admin locked
-	ipfsProtected.GET("/pubsub/consume/:topic", IpfsPubSubConsume)  // admin locked
-	ipfsProtected.GET("/pins", GetLocalPins)                        // admin locked
-	ipfsProtected.GET("/object-stat/:key", GetObjectStatForIpfs)
-	ipfsProtected.GET("/object/size/:key", GetFileSizeInBytesForObject)
-	ipfsProtected.GET("/check-for-pin/:hash", CheckLocalNodeForPin)
+	manageIPFS := middleware.PermissionMiddleware(db, models.PermissionManageIPFS)
+	ipfsProtected.POST("/pubsub/publish/:topic", middleware.LoginRequiredMiddleware(), manageIPFS, IpfsPubSubPublish)
+	ipfsProtected.GET("/pubsub/consume/:topic", manageIPFS, IpfsPubSubConsume)
+	ipfsProtected.GET("/pins", manageIPFS, readLimit, GetLocalPins)
+	ipfsProtected.GET("/object-stat/:key", readOnly, readLimit, GetObjectStatForIpfs)
+	ipfsProtected.GET("/object/size/:key", readOnly, readLimit, GetFileSizeInBytesForObject)
+	ipfsProtected.GET("/check-for-pin/:hash", readOnly, readLimit, CheckLocalNodeForPin)
 	ipfsProtected.Use(middleware.DatabaseMiddleware(db))
-	ipfsProtected.POST("/download/:hash", DownloadContentHash)
+	ipfsProtected.GET("/download/:hash", readOnly, readLimit, DownloadContentHash)
+	ipfsProtected.HEAD("/download/:hash", readOnly, readLimit, DownloadContentHash)
+	ipfsProtected.POST("/download/:hash", readOnly, readLimit, DownloadContentHash)
 
 	// DATABASE-USING ROUTES
-	ipfsProtected.Use(middleware.RabbitMQMiddleware(mqConnectionURL))
+	ipfsProtected.Use(middleware.RabbitMQMiddleware(publisher))
 	ipfsProtected.Use(middleware.DatabaseMiddleware(db))
-	ipfsProtected.POST("/pin/:hash", PinHashLocally)
-	ipfsProtected.POST("/add-file", AddFileLocally)
+	ipfsProtected.POST("/pin/:hash", middleware.APIKeyScopeMiddleware(models.APIKeyScopePin), pinLimit, PinHashLocally)
+	ipfsProtected.POST("/add-file", middleware.APIKeyScopeMiddleware(models.APIKeyScopeUpload), uploadLimit, AddFileLocally)
+	ipfsProtected.POST("/add-directory", middleware.APIKeyScopeMiddleware(models.APIKeyScopeUpload), uploadLimit, AddDirectoryLocally)
 	ipfsProtected.Use(middleware.MINIMiddleware(minioKey, minioSecret, endpoint, true))
-	ipfsProtected.POST("/add-file/advanced", AddFileLocallyAdvanced)
+	ipfsProtected.POST("/add-file/advanced", middleware.APIKeyScopeMiddleware(models.APIKeyScopeUpload), uploadLimit, AddFileLocallyAdvanced)
 
-	//ipfsProtected.DELETE("/remove-pin/:hash", RemovePinFromLocalHost)
+	ipfsProtected.DELETE("/remove-pin/:hash", middleware.APIKeyScopeMiddleware(models.APIKeyScopePin), pinLimit, RemovePinFromLocalHost)
 
 	ipfsPrivateProtected := g.Group("/api/v1/ipfs-private")
-	ipfsPrivateProtected.Use(authWare.MiddlewareFunc())
+	ipfsPrivateProtected.Use(middleware.AuthMiddleware(authWare, keys, db))
 	ipfsPrivateProtected.Use(middleware.APIRestrictionMiddleware(db))
+	ipfsPrivateProtected.Use(middleware.TwoFactorEnrollmentMiddleware(db, cfg.API.RequireTwoFactorForEnterprise))
 	ipfsPrivateProtected.Use(middleware.DatabaseMiddleware(db))
-	ipfsPrivateProtected.POST("/new/network", CreateHostedIPFSNetworkEntryInDatabase)
-	ipfsPrivateProtected.POST("/network/name", GetIPFSPrivateNetworkByName)
-	ipfsPrivateProtected.POST("/ipfs/check-for-pin/:hash", CheckLocalNodeForPinForHostedIPFSNetwork)
-	ipfsPrivateProtected.POST("/ipfs/object-stat/:key", GetObjectStatForIpfsForHostedIPFSNetwork)
-	ipfsPrivateProtected.POST("/ipfs/object/size/:key", GetFileSizeInBytesForObjectForHostedIPFSNetwork)
-	ipfsPrivateProtected.POST("/pubsub/publish/:topic", IpfsPubSubPublishToHostedIPFSNetwork)
-	ipfsPrivateProtected.POST("/pubsub/consume/:topic", IpfsPubSubConsumeForHostedIPFSNetwork)
-	ipfsPrivateProtected.POST("/pins", GetLocalPinsForHostedIPFSNetwork)
-	ipfsPrivateProtected.GET("/networks", GetAuthorizedPrivateNetworks)
-	ipfsPrivateProtected.POST("/uploads", GetUploadsByNetworkName)
-	ipfsPrivateProtected.DELETE("/pin/remove/:hash", RemovePinFromLocalHostForHostedIPFSNetwork)
+	ipfsPrivateProtected.Use(readLimit)
+	managePrivateNetworks := middleware.PermissionMiddleware(db, models.PermissionManagePrivateNetworks)
+	ipfsPrivateProtected.POST("/new/network", managePrivateNetworks, CreateHostedIPFSNetworkEntryInDatabase)
+	ipfsPrivateProtected.POST("/network/name", managePrivateNetworks, GetIPFSPrivateNetworkByName)
+	ipfsPrivateProtected.POST("/ipfs/check-for-pin/:hash", readOnly, CheckLocalNodeForPinForHostedIPFSNetwork)
+	ipfsPrivateProtected.POST("/ipfs/object-stat/:key", readOnly, GetObjectStatForIpfsForHostedIPFSNetwork)
+	ipfsPrivateProtected.POST("/ipfs/object/size/:key", readOnly, GetFileSizeInBytesForObjectForHostedIPFSNetwork)
+	ipfsPrivateProtected.POST("/pubsub/publish/:topic", middleware.LoginRequiredMiddleware(), IpfsPubSubPublishToHostedIPFSNetwork)
+	ipfsPrivateProtected.POST("/pubsub/consume/:topic", managePrivateNetworks, IpfsPubSubConsumeForHostedIPFSNetwork)
+	ipfsPrivateProtected.POST("/pins", readOnly, GetLocalPinsForHostedIPFSNetwork)
+	ipfsPrivateProtected.GET("/networks", readOnly, GetAuthorizedPrivateNetworks)
+	ipfsPrivateProtected.POST("/uploads", readOnly, GetUploadsByNetworkName)
+	ipfsPrivateProtected.DELETE("/pin/remove/:hash", middleware.APIKeyScopeMiddleware(models.APIKeyScopePin), middleware.RabbitMQMiddleware(publisher), RemovePinFromLocalHostForHostedIPFSNetwork)
 
 	ipnsProtected := g.Group("/api/v1/ipns")
-	ipnsProtected.Use(authWare.MiddlewareFunc())
+	ipnsProtected.Use(middleware.AuthMiddleware(authWare, keys, db))
 	ipnsProtected.Use(middleware.APIRestrictionMiddleware(db))
-	ipnsProtected.Use(middleware.RabbitMQMiddleware(mqConnectionURL))
+	ipnsProtected.Use(middleware.TwoFactorEnrollmentMiddleware(db, cfg.API.RequireTwoFactorForEnterprise))
+	ipnsProtected.Use(middleware.APIKeyScopeMiddleware(models.APIKeyScopeIPNS))
+	ipnsProtected.Use(ipnsLimit)
+	ipnsProtected.Use(middleware.RabbitMQMiddleware(publisher))
 	ipnsProtected.Use(middleware.DatabaseMiddleware(db))
 	ipnsProtected.POST("/publish/details", PublishToIPNSDetails) // admin locked
 	ipnsProtected.Use(middleware.AWSMiddleware(awsKey, awsSecret))
-	ipnsProtected.POST("/dnslink/aws/add", GenerateDNSLinkEntry) // admin locked
+	ipnsProtected.POST("/dnslink/aws/add", middleware.PermissionMiddleware(db, models.PermissionManageDNS), GenerateDNSLinkEntry)
 
 	clusterProtected := g.Group("/api/v1/ipfs-cluster")
-	clusterProtected.Use(authWare.MiddlewareFunc())
+	clusterProtected.Use(middleware.AuthMiddleware(authWare, keys, db))
 	clusterProtected.Use(middleware.APIRestrictionMiddleware(db))
-	clusterProtected.POST("/sync-errors-local", SyncClusterErrorsLocally)          // admin locked
-	clusterProtected.GET("/status-local-pin/:hash", GetLocalStatusForClusterPin)   // admin locked
-	clusterProtected.GET("/status-global-pin/:hash", GetGlobalStatusForClusterPin) // admin locked
-	clusterProtected.GET("/status-local", FetchLocalClusterStatus)                 // admin locked
-	clusterProtected.Use(middleware.RabbitMQMiddleware(mqConnectionURL))
-	clusterProtected.POST("/pin/:hash", PinHashToCluster)
-	//clusterProtected.DELETE("/remove-pin/:hash", RemovePinFromCluster)
+	clusterProtected.Use(middleware.TwoFactorEnrollmentMiddleware(db, cfg.API.RequireTwoFactorForEnterprise))
+	clusterProtected.Use(middleware.APIKeyScopeMiddleware(models.APIKeyScopePin))
+	manageCluster := middleware.PermissionMiddleware(db, models.PermissionManageCluster)
+	clusterProtected.POST("/sync-errors-local", manageCluster, SyncClusterErrorsLocally)
+	clusterProtected.GET("/status-local-pin/:hash", manageCluster, GetLocalStatusForClusterPin)
+	clusterProtected.GET("/status-global-pin/:hash", readLimit, GetGlobalStatusForClusterPin)
+	clusterProtected.GET("/status-local", manageCluster, FetchLocalClusterStatus)
+	clusterProtected.Use(middleware.RabbitMQMiddleware(publisher))
+	clusterProtected.POST("/pin/:hash", pinLimit, PinHashToCluster)
 
 	databaseProtected := g.Group("/api/v1/database")
-	databaseProtected.Use(authWare.MiddlewareFunc())
+	databaseProtected.Use(middleware.AuthMiddleware(authWare, keys, db))
 	databaseProtected.Use(middleware.APIRestrictionMiddleware(db))
+	databaseProtected.Use(middleware.TwoFactorEnrollmentMiddleware(db, cfg.API.RequireTwoFactorForEnterprise))
 	databaseProtected.Use(middleware.DatabaseMiddleware(db))
-	databaseProtected.DELETE("/garbage-collect/test", RunTestGarbageCollection)    // admin locked
-	databaseProtected.DELETE("/garbage-collect/run", RunDatabaseGarbageCollection) // admin locked
-	databaseProtected.GET("/uploads", GetUploadsFromDatabase)                      // admin locked
-	databaseProtected.GET("/uploads/:address", GetUploadsForAddress)               // partial admin locked
+	databaseProtected.Use(middleware.RetentionMiddleware(gracePeriod))
+	databaseProtected.Use(readLimit)
+	manageRetention := middleware.PermissionMiddleware(db, models.PermissionManageRetention)
+	databaseProtected.GET("/garbage-collect/dry-run", manageRetention, GetRetentionReport)
+	databaseProtected.DELETE("/garbage-collect/run", middleware.LoginRequiredMiddleware(), manageRetention, RunDatabaseGarbageCollection)
+	databaseProtected.GET("/uploads", middleware.PermissionMiddleware(db, models.PermissionViewUploads), GetUploadsFromDatabase)
+	databaseProtected.GET("/uploads/:address", readOnly, GetUploadsForAddress) // other addresses need the view-uploads permission
 
 	frontendProtected := g.Group("/api/v1/frontend/")
-	frontendProtected.Use(authWare.MiddlewareFunc())
-	frontendProtected.Use(middleware.RabbitMQMiddleware(mqConnectionURL))
+	frontendProtected.Use(middleware.JWTMiddleware(authWare, keys))
+	frontendProtected.Use(middleware.TwoFactorEnrollmentMiddleware(db, cfg.API.RequireTwoFactorForEnterprise))
+	frontendProtected.Use(middleware.RabbitMQMiddleware(publisher))
 	frontendProtected.Use(middleware.BlockchainMiddleware(true, ethKey, ethPass))
 	frontendProtected.GET("/cost/calculate/:hash/:holdtime", CalculatePinCost)
 	frontendProtected.POST("/cost/calculate/file", CalculateFileCost)
@@ -190,12 +259,88 @@ func setupRoutes(g *gin.Engine, authWare *jwt.GinJWTMiddleware, db *gorm.DB, cfg
 	frontendProtected.Use(middleware.MINIMiddleware(minioKey, minioSecret, endpoint, true))
 	frontendProtected.POST("/payment/file/create", CreateFilePayment)
 
+	// IPFS Pinning Service API
+	pinningServiceProtected := g.Group("/pins")
+	pinningServiceProtected.Use(middleware.AuthMiddleware(authWare, keys, db))
+	pinningServiceProtected.Use(middleware.APIRestrictionMiddleware(db))
+	pinningServiceProtected.Use(middleware.TwoFactorEnrollmentMiddleware(db, cfg.API.RequireTwoFactorForEnterprise))
+	pinningServiceProtected.Use(middleware.APIKeyScopeMiddleware(models.APIKeyScopePin))
+	pinningServiceProtected.Use(middleware.RabbitMQMiddleware(publisher))
+	pinningServiceProtected.Use(middleware.DatabaseMiddleware(db))
+	pinningServiceProtected.GET("", readLimit, ListPinRequests)
+	pinningServiceProtected.POST("", pinLimit, AddPinRequest)
+	pinningServiceProtected.GET("/:requestid", readLimit, GetPinRequest)
+	pinningServiceProtected.POST("/:requestid", pinLimit, ReplacePinRequest)
+	pinningServiceProtected.DELETE("/:requestid", pinLimit, RemovePinRequest)
+
+	// V2 -- json bodies, typed responses, and one error envelope
+	v2 := g.Group("/api/v2")
+	v2.Use(middleware.V2Middleware())
+	v2.Use(middleware.DatabaseMiddleware(db))
+	v2.POST("/login", middleware.RabbitMQMiddleware(publisher), LoginV2(authWare, keys))
+	v2.POST("/login/refresh", RefreshSessionV2(authWare, keys))
+
+	v2Account := v2.Group("/account")
+	v2Account.Use(middleware.JWTMiddleware(authWare, keys))
+	v2Account.Use(middleware.APIRestrictionMiddleware(db))
+	v2Account.Use(middleware.TwoFactorEnrollmentMiddleware(db, cfg.API.RequireTwoFactorForEnterprise))
+	v2Account.Use(readLimit)
+	v2Account.GET("/sessions", GetSessionsV2)
+	v2Account.POST("/api-keys", CreateAPIKeyV2)
+	v2Account.GET("/api-keys", GetAPIKeysV2)
+	v2Account.DELETE("/api-keys/:id", RevokeAPIKeyV2)
+	v2Account.POST("/webhooks", CreateWebhookV2)
+	v2Account.GET("/webhooks", GetWebhooksV2)
+	v2Account.DELETE("/webhooks/:id", DeleteWebhookV2)
+	v2Account.GET("/webhooks/:id/deliveries", GetWebhookDeliveriesV2)
+
+	v2Protected := v2.Group("")
+	v2Protected.Use(middleware.AuthMiddleware(authWare, keys, db))
+	v2Protected.Use(middleware.APIRestrictionMiddleware(db))
+	v2Protected.Use(middleware.TwoFactorEnrollmentMiddleware(db, cfg.API.RequireTwoFactorForEnterprise))
+	v2Protected.Use(middleware.RabbitMQMiddleware(publisher))
+	v2Protected.POST("/ipfs/pins", middleware.APIKeyScopeMiddleware(models.APIKeyScopePin), pinLimit, PinV2)
+	v2Protected.DELETE("/ipfs/pins/:hash", middleware.APIKeyScopeMiddleware(models.APIKeyScopePin), pinLimit, UnpinV2)
+	v2Protected.POST("/ipns/records", middleware.APIKeyScopeMiddleware(models.APIKeyScopeIPNS), ipnsLimit, PublishIPNSRecordV2)
+	v2Protected.GET("/uploads", readOnly, readLimit, GetUploadsV2)
+	v2Protected.GET("/uploads/:address", readOnly, readLimit, GetUploadsForAddressV2)
+	v2Protected.GET("/jobs", readOnly, readLimit, GetJobsForAuthUser)
+	v2Protected.GET("/jobs/:id", readOnly, readLimit, GetJob)
+
+	webhooksProtected := g.Group("/api/v1/webhooks")
+	webhooksProtected.Use(middleware.JWTMiddleware(authWare, keys))
+	webhooksProtected.Use(middleware.APIRestrictionMiddleware(db))
+	webhooksProtected.Use(middleware.TwoFactorEnrollmentMiddleware(db, cfg.API.RequireTwoFactorForEnterprise))
+	webhooksProtected.Use(middleware.DatabaseMiddleware(db))
+	webhooksProtected.Use(readLimit)
+	webhooksProtected.POST("", CreateWebhook)
+	webhooksProtected.GET("", GetWebhooksForAuthUser)
+	webhooksProtected.DELETE("/:id", DeleteWebhook)
+	webhooksProtected.GET("/:id/deliveries", GetWebhookDeliveries)
+
 	adminProtected := g.Group("/api/v1/admin")
-	adminProtected.Use(authWare.MiddlewareFunc())
+	adminProtected.Use(middleware.JWTMiddleware(authWare, keys))
 	adminProtected.Use(middleware.APIRestrictionMiddleware(db))
+	adminProtected.Use(middleware.TwoFactorEnrollmentMiddleware(db, cfg.API.RequireTwoFactorForEnterprise))
+	adminProtected.Use(middleware.DatabaseMiddleware(db))
 	mini := adminProtected.Group("/mini")
+	mini.Use(middleware.PermissionMiddleware(db, models.PermissionManageStorage))
 	mini.Use(middleware.MINIMiddleware(minioKey, minioSecret, endpoint, true))
 	mini.POST("/create/bucket", MakeBucket)
+	queues := adminProtected.Group("/queues")
+	queues.Use(middleware.PermissionMiddleware(db, models.PermissionManageQueues))
+	queues.Use(middleware.RabbitMQMiddleware(publisher))
+	queues.GET("/:queue/dead-letters", InspectDeadLetters)
+	queues.POST("/:queue/dead-letters/replay", ReplayDeadLetters)
+	queues.DELETE("/:queue/dead-letters", PurgeDeadLetters)
+	roles := adminProtected.Group("/roles")
+	roles.Use(middleware.PermissionMiddleware(db, models.PermissionManageRoles))
+	roles.GET("", GetRoles)
+	roles.POST("/grant", GrantRole)
+	roles.POST("/revoke", RevokeRole)
+	users := adminProtected.Group("/users")
+	users.Use(middleware.PermissionMiddleware(db, models.PermissionManageUsers))
+	users.POST("/unlock", UnlockAccount)
 	// PROTECTED ROUTES -- END
 
 }
@@ -1,21 +1,33 @@
 package middleware
 
 import (
-	"errors"
+	"fmt"
 	"net/http"
 
+	"github.com/RTradeLtd/Temporal/models"
 	jwt "github.com/appleboy/gin-jwt"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 )
 
-// AdminRestrictionMiddleware is used to lock down admin protected routes
-func AdminRestrictionMiddleware(db *gorm.DB, adminAdress string) gin.HandlerFunc {
+// PermissionMiddleware is used to lock down routes to users whose roles grant the given permission.
+// Api keys can't be used for these routes, as roles are only granted to users who have logged in
+func PermissionMiddleware(db *gorm.DB, permission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if _, ok := APIKeyFromContext(c); ok {
+			abortWithError(c, http.StatusForbidden, "api keys can not be used for this route", gin.H{"error": "api keys can not be used for this route"})
+			return
+		}
 		claims := jwt.ExtractClaims(c)
-		ethAddress := claims["id"]
-		if ethAddress != adminAdress {
-			c.AbortWithError(http.StatusForbidden, errors.New("user is not an admin"))
+		ethAddress, ok := claims["id"].(string)
+		if !ok {
+			abortWithError(c, http.StatusForbidden, "unauthorized access", gin.H{"error": "unauthorized access"})
+			return
+		}
+		allowed, err := models.NewUserManager(db).CheckIfUserHasPermission(ethAddress, permission)
+		if err != nil || !allowed {
+			message := fmt.Sprintf("unauthorized access, the %s permission is required", permission)
+			abortWithError(c, http.StatusForbidden, message, gin.H{"error": message})
 			return
 		}
 		c.Next()
@@ -15,19 +15,28 @@ import (
 api middleware is used to secure access to the api
 */
 
-var user models.User
 var nilTime time.Time
 
 func APIRestrictionMiddleware(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		claims := jwt.ExtractClaims(c)
 		ethAddress := claims["id"]
+		// the user is loaded per request, as requests are handled concurrently
+		var user models.User
 		db.Where("eth_address = ?", ethAddress).First(&user)
 		if user.CreatedAt == nilTime {
+			if IsV2(c) {
+				AbortV2(c, http.StatusUnauthorized, ErrorCodeUnauthorized, "invalid user account")
+				return
+			}
 			c.AbortWithError(http.StatusBadRequest, errors.New("invalid user account"))
 			return
 		}
 		if !user.APIAccess {
+			if IsV2(c) {
+				AbortV2(c, http.StatusForbidden, ErrorCodeForbidden, "unauthorized api access")
+				return
+			}
 			c.AbortWithError(http.StatusForbidden, errors.New("unauthorized api access"))
 			return
 		}
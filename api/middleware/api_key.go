@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/RTradeLtd/Temporal/models"
+	jwt "github.com/appleboy/gin-jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	jwtgo "gopkg.in/dgrijalva/jwt-go.v3"
+)
+
+/*
+API keys are accepted by the same route groups as JWTs. A key may be given with the
+X-API-Key header, or as the bearer token of the Authorization header
+*/
+
+// APIKeyHeader is the header api keys may be given with
+var APIKeyHeader = "X-API-Key"
+
+// AuthMiddleware is used to authenticate requests with either an api key, or a JWT
+func AuthMiddleware(authWare *jwt.GinJWTMiddleware, keys *KeyRing, db *gorm.DB) gin.HandlerFunc {
+	jwtAuth := JWTMiddleware(authWare, keys)
+	return func(c *gin.Context) {
+		token := apiKeyFromRequest(c)
+		if token == "" {
+			jwtAuth(c)
+			return
+		}
+		akm := models.NewAPIKeyManager(db)
+		key, err := akm.FindAPIKeyByToken(token)
+		if err != nil {
+			abortWithError(c, http.StatusUnauthorized, "invalid api key", gin.H{
+				"code":    http.StatusUnauthorized,
+				"message": "invalid api key",
+			})
+			return
+		}
+		// keys stop working as soon as the account of their owner is disabled
+		enabled, err := models.NewUserManager(db).CheckIfUserAccountEnabled(key.EthAddress, db)
+		if err != nil || !enabled {
+			abortWithError(c, http.StatusUnauthorized, "account is disabled", gin.H{
+				"code":    http.StatusUnauthorized,
+				"message": "account is disabled",
+			})
+			return
+		}
+		if err = akm.UpdateLastUsed(key); err != nil {
+			fmt.Println("error updating api key last used ", err)
+		}
+		// mimic the claims of a JWT so that the authenticated user is retrieved the same way
+		c.Set("JWT_PAYLOAD", jwtgo.MapClaims{"id": key.EthAddress})
+		c.Set("userID", key.EthAddress)
+		c.Set("api_key", key)
+		c.Next()
+	}
+}
+
+// APIKeyScopeMiddleware is used to restrict requests made with an api key to
+// those its scopes allow. Requests authenticated with a JWT are unrestricted
+func APIKeyScopeMiddleware(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := APIKeyFromContext(c)
+		if ok && !key.Allows(scope, c.Request.Method) {
+			message := fmt.Sprintf("api key is missing the %s scope", scope)
+			abortWithError(c, http.StatusForbidden, message, gin.H{"error": message})
+			return
+		}
+		c.Next()
+	}
+}
+
+// LoginRequiredMiddleware is used to reject requests made with an api key, for routes
+// which must only be used by a user who has logged in
+func LoginRequiredMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := APIKeyFromContext(c); ok {
+			abortWithError(c, http.StatusForbidden, "api keys can not be used for this route", gin.H{"error": "api keys can not be used for this route"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func apiKeyFromRequest(c *gin.Context) string {
+	if token := c.GetHeader(APIKeyHeader); token != "" {
+		return token
+	}
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if strings.HasPrefix(token, models.APIKeyPrefix) {
+		return token
+	}
+	return ""
+}
+
+// APIKeyFromContext is used to get the api key a request was authenticated with, if any
+func APIKeyFromContext(c *gin.Context) (*models.APIKey, bool) {
+	value, exists := c.Get("api_key")
+	if !exists {
+		return nil, false
+	}
+	key, ok := value.(*models.APIKey)
+	return key, ok
+}
@@ -1,26 +1,33 @@
 package middleware
 
 import (
+	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/RTradeLtd/Temporal/models"
 	jwt "github.com/appleboy/gin-jwt"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
+	jwtgo "gopkg.in/dgrijalva/jwt-go.v3"
 )
 
 var realmName = "temporal-realm"
 
+// TwoFactorHeader is the header the totp, or recovery, code of a login is given with
+var TwoFactorHeader = "X-Two-Factor-Code"
+
+// AccessTokenTimeout is how long a JWT is valid for, after which it must be refreshed with the refresh token of its session
+var AccessTokenTimeout = time.Hour
+
 // JwtConfigGenerate is used to generate our JWT configuration
 func JwtConfigGenerate(jwtKey string, db *gorm.DB) *jwt.GinJWTMiddleware {
 
-	// will implement metamaks/msg signing with ethereum accounts
-	// as the authentication metho
+	// this checks passwords for logins, tokens are issued with a session by GenerateJWT
 	authMiddleware := &jwt.GinJWTMiddleware{
-		Realm:      realmName,
-		Key:        []byte(jwtKey),
-		Timeout:    time.Hour * 24,
-		MaxRefresh: time.Hour * 24,
+		Realm:   realmName,
+		Key:     []byte(jwtKey),
+		Timeout: AccessTokenTimeout,
 		Authenticator: func(userId string, password string, c *gin.Context) (string, bool) { // userId = uploader address
 			userManager := models.NewUserManager(db)
 			validLogin, err := userManager.SignIn(userId, password)
@@ -30,13 +37,56 @@ func JwtConfigGenerate(jwtKey string, db *gorm.DB) *jwt.GinJWTMiddleware {
 			if !validLogin {
 				return userId, false
 			}
+			// the second step of the login, for users with two factor authentication enabled
+			validCode, err := userManager.ValidateTwoFactor(userId, c.GetHeader(TwoFactorHeader))
+			if err != nil {
+				c.Set("auth_error", err.Error())
+				return userId, false
+			}
+			if !validCode {
+				c.Set("auth_error", models.ErrInvalidTwoFactorCode.Error())
+				return userId, false
+			}
 			return userId, true
 		},
+		// tokens are only valid while their session is, and the account is enabled,
+		// so that revoking a session or disabling an account takes effect immediately
 		Authorizator: func(userId string, c *gin.Context) bool {
-
+			claims := jwt.ExtractClaims(c)
+			sessionID, ok := claims["sid"].(string)
+			if !ok {
+				c.Set("auth_error", "token has no session, please log in again")
+				return false
+			}
+			sm := models.NewSessionManager(db)
+			session, err := sm.FindActiveSession(sessionID)
+			if err != nil || session.EthAddress != userId {
+				c.Set("auth_error", "session has been revoked or has expired")
+				return false
+			}
+			enabled, err := models.NewUserManager(db).CheckIfUserAccountEnabled(userId, db)
+			if err != nil || !enabled {
+				c.Set("auth_error", "account is disabled")
+				return false
+			}
+			if err = sm.UpdateLastUsed(session); err != nil {
+				fmt.Println("error updating session last used ", err)
+			}
+			c.Set("session_id", sessionID)
 			return true
 		},
 		Unauthorized: func(c *gin.Context, code int, message string) {
+			if reason, exists := c.Get("auth_error"); exists {
+				message = reason.(string)
+			}
+			if IsV2(c) {
+				errorCode := ErrorCodeForStatus(code)
+				if message == models.ErrTwoFactorCodeRequired.Error() {
+					errorCode = ErrorCodeTwoFactorRequired
+				}
+				AbortV2(c, code, errorCode, message)
+				return
+			}
 			c.JSON(code, gin.H{
 				"code":    code,
 				"message": message,
@@ -50,3 +100,50 @@ func JwtConfigGenerate(jwtKey string, db *gorm.DB) *jwt.GinJWTMiddleware {
 
 	return authMiddleware
 }
+
+// GenerateJWT is used to issue a token for a session of a user, signed with the current key of the key ring
+func GenerateJWT(authWare *jwt.GinJWTMiddleware, keys *KeyRing, userID, sessionID string) (string, time.Time, error) {
+	if err := authWare.MiddlewareInit(); err != nil {
+		return "", time.Time{}, err
+	}
+	claims := jwtgo.MapClaims{}
+	if authWare.PayloadFunc != nil {
+		for key, value := range authWare.PayloadFunc(userID) {
+			claims[key] = value
+		}
+	}
+	expire := authWare.TimeFunc().Add(authWare.Timeout)
+	claims["id"] = userID
+	claims["sid"] = sessionID
+	claims["exp"] = expire.Unix()
+	claims["orig_iat"] = authWare.TimeFunc().Unix()
+	tokenString, err := keys.SignClaims(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return tokenString, expire, nil
+}
+
+// TwoFactorEnrollmentMiddleware is used to stop users who must enable two factor authentication
+// from using the api until they have done so
+func TwoFactorEnrollmentMiddleware(db *gorm.DB, requireForEnterprise bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireForEnterprise {
+			c.Next()
+			return
+		}
+		claims := jwt.ExtractClaims(c)
+		ethAddress, ok := claims["id"].(string)
+		if !ok {
+			abortWithError(c, http.StatusForbidden, "unauthorized access", gin.H{"error": "unauthorized access"})
+			return
+		}
+		user := models.NewUserManager(db).FindByAddress(ethAddress)
+		if user == nil || user.NeedsTwoFactorEnrollment(requireForEnterprise) {
+			message := "two factor authentication must be enabled for this account, see /api/v1/account/two-factor/enroll"
+			abortWithError(c, http.StatusForbidden, message, gin.H{"error": message})
+			return
+		}
+		c.Next()
+	}
+}
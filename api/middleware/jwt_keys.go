@@ -0,0 +1,346 @@
+package middleware
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/config"
+	"github.com/RTradeLtd/Temporal/models"
+	"github.com/RTradeLtd/Temporal/utils"
+	jwt "github.com/appleboy/gin-jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	jwtgo "gopkg.in/dgrijalva/jwt-go.v3"
+)
+
+/*
+JWTs are signed with the jwt key using HS256, or with rotating asymmetric keys when an algorithm
+is configured. Asymmetric keys are identified by the kid header of tokens, and their public keys
+are published as a JSON Web Key Set, so other services can verify our tokens without a shared secret
+*/
+
+var (
+	// DefaultKeyRotationInterval is how long each signing key signs tokens for when no interval is configured
+	DefaultKeyRotationInterval = time.Hour * 24 * 30
+	// DefaultKeyOverlap is how long keys are published before, and after, signing tokens when no overlap is configured
+	DefaultKeyOverlap = time.Hour * 24
+	// keyRotationCheckInterval is how often we check whether the next signing key needs to be published
+	keyRotationCheckInterval = time.Minute * 10
+	// keyRingRefreshInterval is how often keys published by other api instances are loaded
+	keyRingRefreshInterval = time.Minute
+	// keyRingReloadInterval limits how often tokens signed with an unknown key cause the keys to be loaded
+	keyRingReloadInterval = time.Second * 10
+)
+
+// KeyRing holds the keys JWTs are signed, and verified, with
+type KeyRing struct {
+	DB               *gorm.DB
+	Algorithm        string
+	HMACKey          []byte
+	EncryptionKey    []byte
+	RotationInterval time.Duration
+	Overlap          time.Duration
+
+	mux      sync.RWMutex
+	keys     []signingKey
+	loadedAt time.Time
+}
+
+// signingKey is a signing key along with its parsed private and public keys
+type signingKey struct {
+	key     models.SigningKey
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// NewKeyRing is used to generate our key ring from the config, creating the first signing key if needed
+func NewKeyRing(db *gorm.DB, cfg *config.TemporalConfig) (*KeyRing, error) {
+	kr := &KeyRing{
+		DB:               db,
+		Algorithm:        cfg.API.SigningKeys.Algorithm,
+		HMACKey:          []byte(cfg.API.JwtKey),
+		EncryptionKey:    []byte(cfg.API.SigningKeys.EncryptionKey),
+		RotationInterval: DefaultKeyRotationInterval,
+		Overlap:          DefaultKeyOverlap,
+	}
+	if !kr.Asymmetric() {
+		if len(kr.HMACKey) == 0 {
+			return nil, errors.New("jwt key is required when no signing algorithm is configured")
+		}
+		return kr, nil
+	}
+	if kr.Algorithm != utils.SigningAlgorithmRS256 && kr.Algorithm != utils.SigningAlgorithmEdDSA {
+		return nil, fmt.Errorf("unsupported signing algorithm %s", kr.Algorithm)
+	}
+	if len(kr.EncryptionKey) == 0 {
+		return nil, errors.New("signing key encryption key is required when a signing algorithm is configured")
+	}
+	var err error
+	if cfg.API.SigningKeys.RotationInterval != "" {
+		kr.RotationInterval, err = time.ParseDuration(cfg.API.SigningKeys.RotationInterval)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.API.SigningKeys.Overlap != "" {
+		kr.Overlap, err = time.ParseDuration(cfg.API.SigningKeys.Overlap)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// tokens must stay verifiable for as long as they are valid, once the key that signed them retires
+	if kr.Overlap < AccessTokenTimeout {
+		return nil, fmt.Errorf("signing key overlap must be at least %s", AccessTokenTimeout)
+	}
+	if kr.RotationInterval <= kr.Overlap {
+		return nil, errors.New("signing key rotation interval must be longer than the overlap")
+	}
+	if err = kr.Rotate(); err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
+// Asymmetric is used to check whether tokens are signed with asymmetric keys, rather than the jwt key
+func (kr *KeyRing) Asymmetric() bool {
+	return kr.Algorithm != "" && kr.Algorithm != "HS256"
+}
+
+// Rotate is used to publish the next signing key when the current one is about to retire, and load the keys
+func (kr *KeyRing) Rotate() error {
+	if err := models.NewSigningKeyManager(kr.DB, kr.EncryptionKey).RotateSigningKeys(kr.Algorithm, kr.RotationInterval, kr.Overlap); err != nil {
+		return err
+	}
+	return kr.load()
+}
+
+// StartRotation is used to rotate the signing keys on schedule, until the process exits
+func (kr *KeyRing) StartRotation() {
+	if !kr.Asymmetric() {
+		return
+	}
+	for {
+		time.Sleep(keyRotationCheckInterval)
+		if err := kr.Rotate(); err != nil {
+			fmt.Println("error rotating signing keys ", err)
+		}
+	}
+}
+
+// SignClaims is used to sign a token with the current signing key
+func (kr *KeyRing) SignClaims(claims jwtgo.MapClaims) (string, error) {
+	if !kr.Asymmetric() {
+		return jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, claims).SignedString(kr.HMACKey)
+	}
+	key, err := kr.currentKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwtgo.NewWithClaims(jwtgo.GetSigningMethod(key.key.Algorithm), claims)
+	token.Header["kid"] = key.key.KeyID
+	return token.SignedString(key.private)
+}
+
+// ParseToken is used to parse a token, verifying it was signed by one of our keys
+func (kr *KeyRing) ParseToken(tokenString string) (*jwtgo.Token, error) {
+	return jwtgo.Parse(tokenString, func(token *jwtgo.Token) (interface{}, error) {
+		if !kr.Asymmetric() {
+			if token.Method != jwtgo.SigningMethodHS256 {
+				return nil, errors.New("invalid signing algorithm")
+			}
+			return kr.HMACKey, nil
+		}
+		keyID, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, errors.New("token has no key id")
+		}
+		key, err := kr.findKey(keyID)
+		if err != nil {
+			return nil, err
+		}
+		// the algorithm of the token must be the one of the key, so a public key can't be used as an hmac secret
+		if token.Method.Alg() != key.key.Algorithm {
+			return nil, errors.New("invalid signing algorithm")
+		}
+		return key.public, nil
+	})
+}
+
+// JWKS is used to return the public keys tokens may be verified with
+func (kr *KeyRing) JWKS() ([]utils.JWK, error) {
+	jwks := []utils.JWK{}
+	if !kr.Asymmetric() {
+		return jwks, nil
+	}
+	keys, err := kr.loadedKeys()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range keys {
+		jwk, err := utils.PublicKeyToJWK(v.key.KeyID, v.public)
+		if err != nil {
+			return nil, err
+		}
+		jwks = append(jwks, jwk)
+	}
+	return jwks, nil
+}
+
+// currentKey is used to find the key tokens are currently signed with
+func (kr *KeyRing) currentKey() (*signingKey, error) {
+	keys, err := kr.loadedKeys()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var current *signingKey
+	for i := range keys {
+		key := &keys[i]
+		if key.key.Algorithm != kr.Algorithm || key.key.ActivatesAt.After(now) || !key.key.RetiresAt.After(now) {
+			continue
+		}
+		if current == nil || key.key.ActivatesAt.After(current.key.ActivatesAt) {
+			current = key
+		}
+	}
+	if current == nil {
+		return nil, errors.New("no signing key is active")
+	}
+	return current, nil
+}
+
+// findKey is used to find a key by its id, loading the keys again if it was published since they were loaded
+func (kr *KeyRing) findKey(keyID string) (*signingKey, error) {
+	keys, err := kr.loadedKeys()
+	if err != nil {
+		return nil, err
+	}
+	for i := range keys {
+		if keys[i].key.KeyID == keyID {
+			return &keys[i], nil
+		}
+	}
+	kr.mux.RLock()
+	loadedAt := kr.loadedAt
+	kr.mux.RUnlock()
+	if time.Since(loadedAt) < keyRingReloadInterval {
+		return nil, errors.New("unknown signing key")
+	}
+	if err = kr.load(); err != nil {
+		return nil, err
+	}
+	keys, err = kr.loadedKeys()
+	if err != nil {
+		return nil, err
+	}
+	for i := range keys {
+		if keys[i].key.KeyID == keyID {
+			return &keys[i], nil
+		}
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+// loadedKeys is used to return the keys which haven't expired, loading them if they haven't been recently
+func (kr *KeyRing) loadedKeys() ([]signingKey, error) {
+	kr.mux.RLock()
+	keys, loadedAt := kr.keys, kr.loadedAt
+	kr.mux.RUnlock()
+	if time.Since(loadedAt) >= keyRingRefreshInterval {
+		if err := kr.load(); err != nil {
+			return nil, err
+		}
+		kr.mux.RLock()
+		keys = kr.keys
+		kr.mux.RUnlock()
+	}
+	var unexpired []signingKey
+	now := time.Now()
+	for _, v := range keys {
+		if v.key.ExpiresAt.After(now) {
+			unexpired = append(unexpired, v)
+		}
+	}
+	return unexpired, nil
+}
+
+// load is used to load the keys from the database
+func (kr *KeyRing) load() error {
+	skm := models.NewSigningKeyManager(kr.DB, kr.EncryptionKey)
+	keys, err := skm.FindVerificationKeys()
+	if err != nil {
+		return err
+	}
+	var loaded []signingKey
+	for _, v := range keys {
+		privatePEM, err := skm.DecryptPrivateKey(&v)
+		if err != nil {
+			return err
+		}
+		private, err := utils.ParseSigningKey(privatePEM)
+		if err != nil {
+			return err
+		}
+		public, err := utils.ParseVerificationKey(v.PublicKey)
+		if err != nil {
+			return err
+		}
+		loaded = append(loaded, signingKey{key: v, private: private, public: public})
+	}
+	kr.mux.Lock()
+	kr.keys = loaded
+	kr.loadedAt = time.Now()
+	kr.mux.Unlock()
+	return nil
+}
+
+// JWTMiddleware is used to authenticate requests with a JWT signed by one of our keys,
+// calling the authorizator of the jwt configuration like the middleware of gin-jwt
+func JWTMiddleware(authWare *jwt.GinJWTMiddleware, keys *KeyRing) gin.HandlerFunc {
+	if err := authWare.MiddlewareInit(); err != nil {
+		return func(c *gin.Context) {
+			unauthorized(c, authWare, http.StatusInternalServerError, err.Error())
+		}
+	}
+	return func(c *gin.Context) {
+		auth := c.GetHeader("Authorization")
+		if auth == "" {
+			unauthorized(c, authWare, http.StatusUnauthorized, "auth header is empty")
+			return
+		}
+		parts := strings.SplitN(auth, " ", 2)
+		if len(parts) != 2 || parts[0] != authWare.TokenHeadName {
+			unauthorized(c, authWare, http.StatusUnauthorized, "invalid auth header")
+			return
+		}
+		token, err := keys.ParseToken(parts[1])
+		if err != nil {
+			unauthorized(c, authWare, http.StatusUnauthorized, err.Error())
+			return
+		}
+		claims := token.Claims.(jwtgo.MapClaims)
+		id, ok := claims["id"].(string)
+		if !ok {
+			unauthorized(c, authWare, http.StatusUnauthorized, "token has no user")
+			return
+		}
+		c.Set("JWT_PAYLOAD", claims)
+		c.Set("userID", id)
+		if !authWare.Authorizator(id, c) {
+			unauthorized(c, authWare, http.StatusForbidden, "You don't have permission to access.")
+			return
+		}
+		c.Next()
+	}
+}
+
+func unauthorized(c *gin.Context, authWare *jwt.GinJWTMiddleware, code int, message string) {
+	c.Header("WWW-Authenticate", "JWT realm="+authWare.Realm)
+	c.Abort()
+	authWare.Unauthorized(c, code, message)
+}
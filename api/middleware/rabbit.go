@@ -4,14 +4,16 @@ package middleware
 	When running go routines inside middleware, we must copy the context
 
 */
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/RTradeLtd/Temporal/queue"
+	"github.com/gin-gonic/gin"
+)
 
-// RabbitMQMiddleware is used to load common paremeters
-// needed for connection, and using rabbitmq from within
-// any api calls
-func RabbitMQMiddleware(connectionURL string) gin.HandlerFunc {
+// RabbitMQMiddleware is used to load the shared publisher
+// needed for using rabbitmq from within any api calls
+func RabbitMQMiddleware(publisher queue.MessagePublisher) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Set("mq_conn_url", connectionURL)
+		c.Set("mq_publisher", publisher)
 		// only call this inside middleware
 		// it's purpose is to execute any pending handlers
 		c.Next()
@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/config"
+	"github.com/RTradeLtd/Temporal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+Requests are rate limited for each user, and class of route, with token buckets stored in the
+database so that every api instance shares them. Limits depend on the plan of the user, and may be
+overridden for individual users in the config
+*/
+
+const (
+	// RateLimitPlanFree is the plan of users without enterprise accounts
+	RateLimitPlanFree = "free"
+	// RateLimitPlanEnterprise is the plan of users with enterprise accounts
+	RateLimitPlanEnterprise = "enterprise"
+)
+
+// DefaultRateLimits are the limits of each plan, keyed by route class, before the config is applied
+var DefaultRateLimits = map[string]map[string]models.RateLimit{
+	RateLimitPlanFree: {
+		models.RateLimitClassUpload: {Rate: 10, Burst: 10},
+		models.RateLimitClassPin:    {Rate: 30, Burst: 30},
+		models.RateLimitClassIPNS:   {Rate: 5, Burst: 5},
+		models.RateLimitClassRead:   {Rate: 120, Burst: 60},
+	},
+	RateLimitPlanEnterprise: {
+		models.RateLimitClassUpload: {Rate: 60, Burst: 30},
+		models.RateLimitClassPin:    {Rate: 300, Burst: 100},
+		models.RateLimitClassIPNS:   {Rate: 30, Burst: 10},
+		models.RateLimitClassRead:   {Rate: 600, Burst: 300},
+	},
+}
+
+// RateLimiter holds the limits of each plan and user
+type RateLimiter struct {
+	DB    *gorm.DB
+	Plans map[string]map[string]models.RateLimit
+	Users map[string]map[string]models.RateLimit
+}
+
+// NewRateLimiter is used to generate our rate limiter, applying the limits in the config over the defaults
+func NewRateLimiter(db *gorm.DB, cfg *config.TemporalConfig) *RateLimiter {
+	rl := &RateLimiter{
+		DB:    db,
+		Plans: make(map[string]map[string]models.RateLimit),
+		Users: make(map[string]map[string]models.RateLimit),
+	}
+	for plan, limits := range DefaultRateLimits {
+		rl.Plans[plan] = make(map[string]models.RateLimit)
+		for class, limit := range limits {
+			rl.Plans[plan][class] = limit
+		}
+	}
+	for plan, limits := range cfg.API.RateLimits.Plans {
+		if rl.Plans[plan] == nil {
+			rl.Plans[plan] = make(map[string]models.RateLimit)
+		}
+		for class, limit := range limits {
+			rl.Plans[plan][class] = models.RateLimit{Rate: limit.Rate, Burst: limit.Burst}
+		}
+	}
+	for ethAddress, limits := range cfg.API.RateLimits.Users {
+		key := strings.ToLower(ethAddress)
+		rl.Users[key] = make(map[string]models.RateLimit)
+		for class, limit := range limits {
+			rl.Users[key][class] = models.RateLimit{Rate: limit.Rate, Burst: limit.Burst}
+		}
+	}
+	return rl
+}
+
+// Limit is used to find the limit of a user for a class of routes, preferring limits set for the user over their plan
+func (rl *RateLimiter) Limit(ethAddress, class string) (models.RateLimit, bool) {
+	if limit, ok := rl.Users[strings.ToLower(ethAddress)][class]; ok {
+		return limit, true
+	}
+	plan := RateLimitPlanFree
+	if user := models.NewUserManager(rl.DB).FindByAddress(ethAddress); user != nil && user.EnterpriseEnabled {
+		plan = RateLimitPlanEnterprise
+	}
+	limit, ok := rl.Plans[plan][class]
+	return limit, ok
+}
+
+// RateLimitMiddleware is used to limit the requests the authenticated user makes to a class of routes.
+// Requests are refused if the limit can't be checked, so that the limits can't be skipped by overwhelming the database
+func RateLimitMiddleware(rl *RateLimiter, class string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ethAddress := c.GetString("userID")
+		if ethAddress == "" {
+			c.Next()
+			return
+		}
+		limit, ok := rl.Limit(ethAddress, class)
+		if !ok {
+			c.Next()
+			return
+		}
+		result, err := models.NewRateLimitManager(rl.DB).TakeToken(models.RateLimitBucketKey(ethAddress, class), limit)
+		if err != nil {
+			fmt.Println("error checking rate limit ", err)
+			message := "failed to check rate limit, try again later"
+			abortWithError(c, http.StatusServiceUnavailable, message, gin.H{
+				"code":    http.StatusServiceUnavailable,
+				"message": message,
+			})
+			return
+		}
+		c.Header("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			message := fmt.Sprintf("rate limit exceeded for %s requests", class)
+			abortWithError(c, http.StatusTooManyRequests, message, gin.H{
+				"code":    http.StatusTooManyRequests,
+				"message": message,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
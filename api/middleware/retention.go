@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RetentionMiddleware is used to load the grace period given
+// to expired content before it is removed
+func RetentionMiddleware(gracePeriod time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("retention_grace_period", gracePeriod)
+		c.Next()
+	}
+}
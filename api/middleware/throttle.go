@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+Throttling of logins and registrations. Rather than sleeping, requests made before their delay has
+passed are refused, so that attackers can't tie up the connections the api allows at once
+*/
+
+// RegistrationThrottleMiddleware is used to throttle registrations from each ip address
+func RegistrationThrottleMiddleware(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := models.RegistrationThrottleKey(c.ClientIP())
+		ltm := models.NewLoginThrottleManager(db)
+		wait, locked, err := ltm.ReserveAttempt(key, models.RegistrationPolicy)
+		if err != nil {
+			fmt.Println("error reserving registration attempt ", err)
+		} else if wait > 0 {
+			AbortTooManyAttempts(c, wait, locked)
+			return
+		}
+		// every registration counts as a failure, as each one may send an email
+		if _, err = ltm.RecordFailure(key, models.RegistrationPolicy); err != nil {
+			fmt.Println("error recording registration attempt ", err)
+		}
+		c.Next()
+	}
+}
+
+// AbortTooManyAttempts is used to refuse a request made before the throttle of its account, or ip address, allows
+func AbortTooManyAttempts(c *gin.Context, wait time.Duration, locked bool) {
+	wait = wait.Round(time.Second)
+	if wait < time.Second {
+		wait = time.Second
+	}
+	message := fmt.Sprintf("too many attempts, try again in %s", wait)
+	if locked {
+		message = fmt.Sprintf("too many failed attempts, locked out for %s", wait)
+	}
+	c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())))
+	abortWithError(c, http.StatusTooManyRequests, message, gin.H{
+		"code":    http.StatusTooManyRequests,
+		"message": message,
+	})
+}
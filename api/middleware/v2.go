@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+Routes under /api/v2 report every error with the same envelope, carrying a machine readable code:
+{"error": {"code": "not_found", "message": "job not found"}}
+Middleware shared with v1 routes keeps responding to them as it always has
+*/
+
+const (
+	// ErrorCodeInvalidRequest is used when the request can't be parsed, such as malformed json
+	ErrorCodeInvalidRequest = "invalid_request"
+	// ErrorCodeValidationFailed is used when the request was parsed, but its fields are invalid
+	ErrorCodeValidationFailed = "validation_failed"
+	// ErrorCodeUnauthorized is used when the request is not authenticated
+	ErrorCodeUnauthorized = "unauthorized"
+	// ErrorCodeForbidden is used when the authenticated user can't make the request
+	ErrorCodeForbidden = "forbidden"
+	// ErrorCodeNotFound is used when the resource doesn't exist, or doesn't belong to the authenticated user
+	ErrorCodeNotFound = "not_found"
+	// ErrorCodeConflict is used when the request conflicts with the state of the resource
+	ErrorCodeConflict = "conflict"
+	// ErrorCodeRateLimited is used when too many requests have been made
+	ErrorCodeRateLimited = "rate_limited"
+	// ErrorCodeTwoFactorRequired is used when a login needs a two factor code, and none was given
+	ErrorCodeTwoFactorRequired = "two_factor_required"
+	// ErrorCodeInternal is used when the request failed because of an error on our side
+	ErrorCodeInternal = "internal_error"
+	// ErrorCodeUnavailable is used when a service the request needs, such as the queue, is unavailable
+	ErrorCodeUnavailable = "service_unavailable"
+)
+
+// APIError is the error envelope of v2 routes
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Details holds the invalid fields of requests which failed validation
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// FieldError is a field of a request which failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ErrorResponse is the body of every error response from v2 routes
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// V2Middleware is used to mark requests as being made to v2 routes, so middleware responds with the error envelope
+func V2Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("api_v2", true)
+		c.Next()
+	}
+}
+
+// IsV2 is used to check whether or not the request was made to a v2 route
+func IsV2(c *gin.Context) bool {
+	return c.GetBool("api_v2")
+}
+
+// AbortV2 is used to reject a request to a v2 route with the error envelope
+func AbortV2(c *gin.Context, status int, code, message string, details ...FieldError) {
+	c.AbortWithStatusJSON(status, ErrorResponse{Error: APIError{Code: code, Message: message, Details: details}})
+}
+
+// ErrorCodeForStatus is used to find the error code of a status, for errors with nothing more specific to report
+func ErrorCodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return ErrorCodeInvalidRequest
+	case http.StatusUnauthorized:
+		return ErrorCodeUnauthorized
+	case http.StatusForbidden:
+		return ErrorCodeForbidden
+	case http.StatusNotFound:
+		return ErrorCodeNotFound
+	case http.StatusConflict:
+		return ErrorCodeConflict
+	case http.StatusUnprocessableEntity:
+		return ErrorCodeValidationFailed
+	case http.StatusTooManyRequests:
+		return ErrorCodeRateLimited
+	case http.StatusServiceUnavailable:
+		return ErrorCodeUnavailable
+	}
+	return ErrorCodeInternal
+}
+
+// abortWithError is used to reject a request, with the error envelope on v2 routes, or the given body on v1 routes
+func abortWithError(c *gin.Context, status int, message string, v1Body gin.H) {
+	if IsV2(c) {
+		AbortV2(c, status, ErrorCodeForStatus(status), message)
+		return
+	}
+	c.AbortWithStatusJSON(status, v1Body)
+}
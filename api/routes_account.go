@@ -2,10 +2,12 @@ package api
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/RTradeLtd/Temporal/api/middleware"
 	"github.com/RTradeLtd/Temporal/models"
 	"github.com/RTradeLtd/Temporal/rtfs"
 	jwt "github.com/appleboy/gin-jwt"
@@ -50,14 +52,19 @@ func ChangeAccountPassword(c *gin.Context) {
 		})
 		return
 	}
+	// log out everywhere else, in case the old password was compromised
+	if err = models.NewSessionManager(db).RevokeAllSessions(ethAddress, c.GetString("session_id")); err != nil {
+		FailOnError(c, err)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"status": "password changed",
 	})
 }
 
-// RegisterUserAccount is used to sign up with temporal and gain web interface access
-// you will not be granted API access however, as that needs to be done manually
+// RegisterUserAccount is used to sign up with temporal and gain web interface access, once the
+// emailed verification token has been submitted. API access still needs to be granted manually
 func RegisterUserAccount(c *gin.Context) {
 	ethAddress, exists := c.GetPostForm("eth_address")
 	if !exists {
@@ -86,6 +93,10 @@ func RegisterUserAccount(c *gin.Context) {
 		FailOnError(c, err)
 		return
 	}
+	// the account is enabled once the email address is verified
+	if err = sendEmailVerification(c, db, ethAddress); err != nil {
+		fmt.Println("error sending verification email ", err)
+	}
 	userModel.HashedPassword = "scrubbed"
 	c.JSON(http.StatusCreated, gin.H{"user": userModel})
 	return
@@ -119,6 +130,10 @@ func RegisterEnterpriseUserAccount(c *gin.Context) {
 		FailOnError(c, err)
 		return
 	}
+	// the account is enabled once the email address is verified
+	if err = sendEmailVerification(c, db, ethAddress); err != nil {
+		fmt.Println("error sending verification email ", err)
+	}
 	userModel.HashedPassword = "scrubbed"
 	c.JSON(http.StatusCreated, gin.H{"user": userModel})
 	return
@@ -131,6 +146,20 @@ func GetAuthenticatedUserFromContext(c *gin.Context) string {
 	return claims["id"].(string)
 }
 
+// CheckPermissionForAuthUser is used to check whether or not the roles of the authenticated user grant the given permission.
+// Permissions are never granted to requests made with an api key
+func CheckPermissionForAuthUser(c *gin.Context, permission string) bool {
+	if _, ok := middleware.APIKeyFromContext(c); ok {
+		return false
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		return false
+	}
+	allowed, err := models.NewUserManager(db).CheckIfUserHasPermission(GetAuthenticatedUserFromContext(c), permission)
+	return err == nil && allowed
+}
+
 // CreateIPFSKey is used to create an IPFS key
 // TODO: encrypt key with provided password
 func CreateIPFSKey(c *gin.Context) {
@@ -217,9 +246,9 @@ func CreateIPFSKey(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status": "key created",
-		"id":     id.Pretty(),
+	c.JSON(http.StatusOK, IPFSKeyResponse{
+		Status: "key created",
+		ID:     id.Pretty(),
 	})
 }
 
@@ -238,8 +267,8 @@ func GetIPFSKeyNamesForAuthUser(c *gin.Context) {
 		FailOnError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"key_names": keys["key_names"],
-		"key_ids":   keys["key_ids"],
+	c.JSON(http.StatusOK, IPFSKeysResponse{
+		KeyNames: keys["key_names"],
+		KeyIDs:   keys["key_ids"],
 	})
 }
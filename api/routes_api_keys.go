@@ -0,0 +1,107 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+Routes used to manage api keys, which can be used instead of logging in
+*/
+
+// CreateAPIKey is used to create an api key for the authenticated user. An expiry
+// may be given as a duration, such as 720h, otherwise the key never expires.
+// The key is only ever returned by this call
+func CreateAPIKey(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	scopes, exists := c.GetPostFormArray("scopes")
+	if !exists {
+		FailNoExistPostForm(c, "scopes")
+		return
+	}
+	for _, v := range scopes {
+		if !models.IsValidAPIKeyScope(v) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid api key scope " + v,
+				"valid_scopes": []string{
+					models.APIKeyScopePin,
+					models.APIKeyScopeUpload,
+					models.APIKeyScopeIPNS,
+					models.APIKeyScopeReadOnly,
+					models.PrivateNetworkScope("<network name>"),
+				},
+			})
+			return
+		}
+	}
+	var expiresAt *time.Time
+	if expiresIn := c.PostForm("expires_in"); expiresIn != "" {
+		duration, err := time.ParseDuration(expiresIn)
+		if err != nil {
+			FailOnError(c, err)
+			return
+		}
+		expiry := time.Now().Add(duration)
+		expiresAt = &expiry
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	akm := models.NewAPIKeyManager(db)
+	key, token, err := akm.NewAPIKey(ethAddress, c.PostForm("name"), scopes, expiresAt)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"api_key": key,
+		"key":     token,
+	})
+}
+
+// GetAPIKeysForAuthUser is used to list the api keys of the authenticated user
+func GetAPIKeysForAuthUser(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	akm := models.NewAPIKeyManager(db)
+	keys, err := akm.FindAPIKeysByUser(ethAddress)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// RevokeAPIKey is used to revoke an api key belonging to the authenticated user
+func RevokeAPIKey(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	akm := models.NewAPIKeyManager(db)
+	if err = akm.RevokeAPIKey(ethAddress, uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "api key not found",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "api key revoked"})
+}
@@ -3,73 +3,49 @@ package api
 import (
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/RTradeLtd/Temporal/models"
+	"github.com/RTradeLtd/Temporal/queue"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 )
 
-var dev = false
+// GetRetentionReport is used to run a dry-run of our retention worker, reporting the
+// expired content that would be unpinned, and the bytes that would be reclaimed
+func GetRetentionReport(c *gin.Context) {
+	runRetention(c, true)
+}
 
-// RunTestGarbageCollection is used to run a test
-// of our garbage collector
-func RunTestGarbageCollection(c *gin.Context) {
-	if !dev {
-		FailOnError(c, errors.New("attempting to run test database garbage colelction in non dev mode"))
-		return
-	}
-	authenticatedUser := GetAuthenticatedUserFromContext(c)
-	if authenticatedUser != AdminAddress {
-		FailNotAuthorized(c, "unauthorized access to admin route")
-		return
-	}
+// RunDatabaseGarbageCollection is used to unpin all content whose hold time and grace period
+// have passed, from both the ipfs node of its network and the cluster, and then remove it from our database
+func RunDatabaseGarbageCollection(c *gin.Context) {
+	runRetention(c, false)
+}
+
+func runRetention(c *gin.Context, dryRun bool) {
 	db, ok := c.MustGet("db").(*gorm.DB)
 	if !ok {
 		FailedToLoadDatabase(c)
 		return
 	}
-	um := models.NewUploadManager(db)
-	deletedUploads, err := um.RunTestDatabaseGarbageCollection()
-	if err != nil {
-		FailOnError(c, err)
-		return
-	}
-	c.JSON(http.StatusOK, gin.H{"deleted": deletedUploads})
-}
-
-// RunDatabaseGarbageCollection is used to parse through our database
-// and remove them from the database. Note we do not remove from the cluster here
-// since that will be a pretty long call. We will have a script that parses through
-// the database once every 24 hours, looking for any deleted pins, and removing them
-// from the cluster
-func RunDatabaseGarbageCollection(c *gin.Context) {
-	authenticatedUser := GetAuthenticatedUserFromContext(c)
-	if authenticatedUser != AdminAddress {
-		FailNotAuthorized(c, "unauthorized access to admin route")
-		return
-	}
-	db, ok := c.MustGet("db").(*gorm.DB)
+	gracePeriod, ok := c.MustGet("retention_grace_period").(time.Duration)
 	if !ok {
-		FailedToLoadDatabase(c)
+		FailedToLoadMiddleware(c, "retention")
 		return
 	}
-	um := models.NewUploadManager(db)
-	deletedUploads, err := um.RunDatabaseGarbageCollection()
+	rw := queue.NewRetentionWorker(db, gracePeriod)
+	report, err := rw.Run(dryRun)
 	if err != nil {
 		FailOnError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"deleted_uploads": deletedUploads})
+	c.JSON(http.StatusOK, gin.H{"report": report})
 }
 
 // GetUploadsFromDatabase is used to read a list of uploads from our database
 // only usable by admin
 func GetUploadsFromDatabase(c *gin.Context) {
-	authenticatedUser := GetAuthenticatedUserFromContext(c)
-	if authenticatedUser != AdminAddress {
-		FailNotAuthorized(c, "unauthorized access to admin route")
-		return
-	}
 	db, ok := c.MustGet("db").(*gorm.DB)
 	if !ok {
 		FailedToLoadDatabase(c)
@@ -85,8 +61,8 @@ func GetUploadsFromDatabase(c *gin.Context) {
 	c.JSON(http.StatusFound, gin.H{"uploads": uploads})
 }
 
-// GetUploadsForAddress is used to read a list of uploads from a particular eth address
-// If not admin, will retrieve all uploads for the current context account
+// GetUploadsForAddress is used to read the uploads owned by a particular eth address, along with the retention of each
+// Without the view-uploads permission, will retrieve all uploads for the current context account
 func GetUploadsForAddress(c *gin.Context) {
 	var queryAddress string
 	db, ok := c.MustGet("db").(*gorm.DB)
@@ -97,7 +73,7 @@ func GetUploadsForAddress(c *gin.Context) {
 
 	um := models.NewUploadManager(db)
 	user := GetAuthenticatedUserFromContext(c)
-	if user == AdminAddress {
+	if CheckPermissionForAuthUser(c, models.PermissionViewUploads) {
 		queryAddress = c.Param("address")
 	} else {
 		queryAddress = user
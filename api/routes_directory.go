@@ -0,0 +1,330 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/RTradeLtd/Temporal/models"
+	"github.com/RTradeLtd/Temporal/queue"
+	"github.com/RTradeLtd/Temporal/rtfs"
+	"github.com/RTradeLtd/Temporal/rtfs_cluster"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+Directory uploads add many files to ipfs as a single directory, so that a static site or dataset keeps its
+structure. Files are written to a temporary directory first, which lets archives be extracted, and paths be
+checked, before anything is added to ipfs
+*/
+
+var (
+	// MaxDirectoryFiles is the most files a directory upload may contain
+	MaxDirectoryFiles = 10000
+	// MaxDirectoryBytes is the most bytes a directory upload may contain once extracted, which
+	// stops small archives from expanding to fill the disk
+	MaxDirectoryBytes int64 = 10 << 30
+)
+
+// directoryFile is a file written to the temporary directory of a directory upload
+type directoryFile struct {
+	path string
+	size int64
+}
+
+// directoryWriter writes the files of a directory upload to a temporary directory, enforcing the limits
+type directoryWriter struct {
+	dir   string
+	files []directoryFile
+	bytes int64
+}
+
+// AddDirectoryLocally is used to add many files to ipfs as a single directory, recorded as one upload. Files are
+// either sent as files form fields, whose relative paths are given by paths form fields in the same order or by
+// their file names, or as a tar, tar.gz or zip archive sent as the archive form field. Private networks are
+// uploaded to by giving network_name
+func AddDirectoryLocally(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	holdTimeInMonths, exists := c.GetPostForm("hold_time")
+	if !exists {
+		FailNoExistPostForm(c, "hold_time")
+		return
+	}
+	holdTimeInt, err := strconv.ParseInt(holdTimeInMonths, 10, 64)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	publisher, ok := c.MustGet("mq_publisher").(queue.MessagePublisher)
+	if !ok {
+		FailedToLoadMiddleware(c, "rabbit mq")
+		return
+	}
+	networkName := c.PostForm("network_name")
+	if networkName == "" {
+		networkName = "public"
+	}
+	apiURL := ""
+	if networkName != "public" {
+		if err = CheckAccessForPrivateNetwork(c, ethAddress, networkName, db); err != nil {
+			FailNotAuthorized(c, err.Error())
+			return
+		}
+		apiURL, err = models.NewHostedIPFSNetworkManager(db).GetAPIURLByName(networkName)
+		if err != nil {
+			FailOnError(c, err)
+			return
+		}
+	}
+	form, err := c.MultipartForm()
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	dir, err := ioutil.TempDir("", "temporal-directory-")
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	dw := &directoryWriter{dir: dir}
+	switch {
+	case len(form.File["archive"]) > 0:
+		err = dw.extractArchive(form.File["archive"][0])
+	case len(form.File["files"]) > 0:
+		err = dw.writeFormFiles(form.File["files"], form.Value["paths"])
+	default:
+		FailNoExistPostForm(c, "files")
+		return
+	}
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	if len(dw.files) == 0 {
+		FailOnError(c, errors.New("upload does not contain any files"))
+		return
+	}
+	manager, err := rtfs.Initialize("", apiURL)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	root, added, err := manager.AddDirectoryContents(dir)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	hashes := make(map[string]string)
+	for _, v := range added {
+		hashes[v.Name] = v.Hash
+	}
+	sort.Slice(dw.files, func(i, j int) bool { return dw.files[i].path < dw.files[j].path })
+	resp := DirectoryAddResponse{Hash: root, Files: []DirectoryFileResponse{}}
+	for _, v := range dw.files {
+		resp.Files = append(resp.Files, DirectoryFileResponse{Path: v.path, Hash: hashes[v.path], Size: v.size})
+	}
+	// only the public network is replicated with the cluster
+	if networkName == "public" {
+		clusterManager, err := rtfs_cluster.Initialize()
+		if err != nil {
+			FailOnError(c, err)
+			return
+		}
+		decodedHash, err := clusterManager.DecodeHashString(root)
+		if err != nil {
+			FailOnError(c, err)
+			return
+		}
+		go func() {
+			if err := clusterManager.Pin(decodedHash); err != nil {
+				fmt.Println("error encountered pinning to cluster ", err)
+			}
+		}()
+	}
+	dfa := queue.DatabaseFileAdd{
+		Hash:             root,
+		HoldTimeInMonths: holdTimeInt,
+		UploaderAddress:  ethAddress,
+		NetworkName:      networkName,
+		UploadType:       models.UploadTypeDirectory,
+	}
+	if err = publisher.PublishMessage(queue.DatabaseFileAddQueue, dfa); err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// writeFormFiles is used to write the files of a multipart upload, at the paths given for them
+func (dw *directoryWriter) writeFormFiles(headers []*multipart.FileHeader, paths []string) error {
+	if len(paths) > 0 && len(paths) != len(headers) {
+		return errors.New("a path must be given for every file")
+	}
+	for i, header := range headers {
+		name := header.Filename
+		if len(paths) > 0 {
+			name = paths[i]
+		}
+		file, err := header.Open()
+		if err != nil {
+			return err
+		}
+		err = dw.writeFile(name, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractArchive is used to extract a tar, tar.gz or zip archive, which is detected from its contents
+func (dw *directoryWriter) extractArchive(header *multipart.FileHeader) error {
+	file, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	magic, _ := reader.Peek(4)
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")) || bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		return dw.extractZip(file, header.Size)
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		return dw.extractTar(gz)
+	default:
+		return dw.extractTar(reader)
+	}
+}
+
+// extractTar is used to extract the regular files and directories of a tar archive. Links and other
+// special files are skipped, as they could point outside of the upload
+func (dw *directoryWriter) extractTar(reader io.Reader) error {
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %s", err)
+		}
+		switch header.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+			err = dw.writeFile(header.Name, tr)
+		case tar.TypeDir:
+			err = dw.makeDir(header.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// extractZip is used to extract the regular files and directories of a zip archive
+func (dw *directoryWriter) extractZip(file io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(file, size)
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %s", err)
+	}
+	for _, entry := range zr.File {
+		mode := entry.Mode()
+		switch {
+		case mode.IsDir():
+			err = dw.makeDir(entry.Name)
+		case mode.IsRegular():
+			var rc io.ReadCloser
+			if rc, err = entry.Open(); err != nil {
+				return err
+			}
+			err = dw.writeFile(entry.Name, rc)
+			rc.Close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile is used to write a file at a path relative to the directory
+func (dw *directoryWriter) writeFile(name string, reader io.Reader) error {
+	rel, err := cleanDirectoryPath(name)
+	if err != nil {
+		return err
+	}
+	if len(dw.files) >= MaxDirectoryFiles {
+		return fmt.Errorf("directory uploads may contain at most %v files", MaxDirectoryFiles)
+	}
+	target := filepath.Join(dw.dir, filepath.FromSlash(rel))
+	if err = os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return fmt.Errorf("failed to create directory for %s", rel)
+	}
+	// files may only be given once, rather than being silently replaced
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s, paths must be unique", rel)
+	}
+	defer file.Close()
+	remaining := MaxDirectoryBytes - dw.bytes
+	written, err := io.Copy(file, io.LimitReader(reader, remaining+1))
+	if err != nil {
+		return err
+	}
+	if written > remaining {
+		return fmt.Errorf("directory uploads may contain at most %v bytes", MaxDirectoryBytes)
+	}
+	dw.bytes += written
+	dw.files = append(dw.files, directoryFile{path: rel, size: written})
+	return nil
+}
+
+// makeDir is used to create a directory, so that empty directories are kept
+func (dw *directoryWriter) makeDir(name string) error {
+	// archives of the current directory include it as ./
+	if path.Clean(name) == "." {
+		return nil
+	}
+	rel, err := cleanDirectoryPath(name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(filepath.Join(dw.dir, filepath.FromSlash(rel)), 0700)
+}
+
+// cleanDirectoryPath is used to clean the relative path of a file in a directory upload,
+// rejecting paths which would be written outside of the directory
+func cleanDirectoryPath(name string) (string, error) {
+	cleaned := path.Clean(strings.Replace(name, "\\", "/", -1))
+	cleaned = strings.TrimPrefix(cleaned, "./")
+	if cleaned == "." || cleaned == "" || path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("invalid path %s, paths must be relative to the directory", name)
+	}
+	return cleaned, nil
+}
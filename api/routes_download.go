@@ -0,0 +1,253 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/models"
+	"github.com/RTradeLtd/Temporal/rtfs"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+Downloads stream content from ipfs to the users who uploaded, or pinned, it. Responses are written
+with http.ServeContent, which handles Range and If-None-Match requests, along with Content-Length
+*/
+
+// downloadHeaders are the response headers which may be set with the extra_headers parameter
+var downloadHeaders = map[string]bool{
+	"Cache-Control":    true,
+	"Content-Language": true,
+	"Expires":          true,
+}
+
+// DownloadContentHash is used to download content uploaded, or pinned, by the authenticated user. Content
+// on a private network is downloaded by giving network_name. Parameters are read from the query string,
+// or from the form for POST requests. The content type is detected from the name the file was uploaded with,
+// or the content itself, unless content_type is given
+func DownloadContentHash(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	contentHash := c.Param("hash")
+	networkName := downloadParam(c, "network_name")
+	if networkName == "" {
+		networkName = "public"
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	apiURL := ""
+	adminPermission := models.PermissionManageIPFS
+	if networkName != "public" {
+		if err := CheckAccessForPrivateNetwork(c, ethAddress, networkName, db); err != nil {
+			FailNotAuthorized(c, err.Error())
+			return
+		}
+		var err error
+		apiURL, err = models.NewHostedIPFSNetworkManager(db).GetAPIURLByName(networkName)
+		if err != nil {
+			FailOnError(c, err)
+			return
+		}
+		adminPermission = models.PermissionManagePrivateNetworks
+	}
+	fileName, err := downloadFileName(db, ethAddress, contentHash, networkName)
+	if err == errContentNotOwned {
+		// those managing the network can download any content on it
+		if !CheckPermissionForAuthUser(c, adminPermission) {
+			FailNotAuthorized(c, err.Error())
+			return
+		}
+	} else if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	headers, err := parseDownloadHeaders(c)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	contentType := downloadParam(c, "content_type")
+	if contentType != "" {
+		if _, _, err = mime.ParseMediaType(contentType); err != nil {
+			FailOnError(c, fmt.Errorf("invalid content_type %s", contentType))
+			return
+		}
+	}
+	manager, err := rtfs.Initialize("", apiURL)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	size, err := manager.FileSize(contentHash)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	for header, value := range headers {
+		c.Header(header, value)
+	}
+	// content is addressed by its hash, so the hash is a strong etag
+	c.Header("ETag", fmt.Sprintf("%q", contentHash))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Disposition", contentDisposition(fileName, downloadParam(c, "disposition") == "inline"))
+	if contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	content := &ipfsContent{manager: manager, hash: contentHash, size: size}
+	defer content.Close()
+	name := fileName
+	if name == "" {
+		name = contentHash
+	}
+	http.ServeContent(c.Writer, c.Request, name, time.Time{}, content)
+}
+
+// errContentNotOwned is returned when downloading content that the user hasn't uploaded, or pinned
+var errContentNotOwned = errors.New("content is not owned by this account")
+
+// downloadFileName is used to find the name content was uploaded with, checking that the user owns the content.
+// The name the user uploaded the content with is preferred, falling back to the name given by other owners
+func downloadFileName(db *gorm.DB, ethAddress, contentHash, networkName string) (string, error) {
+	um := models.NewUploadManager(db)
+	upload, err := um.FindUploadByHashAndNetwork(contentHash, networkName)
+	if err == gorm.ErrRecordNotFound {
+		return "", errContentNotOwned
+	}
+	if err != nil {
+		return "", err
+	}
+	owners, err := um.FindUploadOwners(upload.ID)
+	if err != nil {
+		return "", err
+	}
+	owned := false
+	fileName := ""
+	for _, v := range owners {
+		if v.EthAddress == ethAddress {
+			owned = true
+			if v.FileName != "" {
+				fileName = v.FileName
+				break
+			}
+		} else if fileName == "" {
+			fileName = v.FileName
+		}
+	}
+	if !owned {
+		return fileName, errContentNotOwned
+	}
+	return fileName, nil
+}
+
+// parseDownloadHeaders is used to parse the extra_headers parameter, given as pairs of header name and value.
+// Only the headers in downloadHeaders may be set, so that downloads can't be used to inject other headers
+func parseDownloadHeaders(c *gin.Context) (map[string]string, error) {
+	pairs, ok := c.GetQueryArray("extra_headers")
+	if !ok {
+		pairs = c.PostFormArray("extra_headers")
+	}
+	if len(pairs)%2 != 0 {
+		return nil, errors.New("extra_headers must be pairs of header name and value")
+	}
+	headers := make(map[string]string)
+	for i := 0; i < len(pairs); i += 2 {
+		header := http.CanonicalHeaderKey(pairs[i])
+		if !downloadHeaders[header] {
+			return nil, fmt.Errorf("header %s may not be set with extra_headers", pairs[i])
+		}
+		if strings.IndexFunc(pairs[i+1], func(r rune) bool { return r < ' ' || r == 0x7f }) != -1 {
+			return nil, fmt.Errorf("value of header %s contains control characters", header)
+		}
+		headers[header] = pairs[i+1]
+	}
+	return headers, nil
+}
+
+// downloadParam is used to read a parameter from the query string, falling back to the form
+func downloadParam(c *gin.Context, key string) string {
+	if value, ok := c.GetQuery(key); ok {
+		return value
+	}
+	return c.PostForm(key)
+}
+
+// contentDisposition is used to format the Content-Disposition header, naming the file when its name is known
+func contentDisposition(fileName string, inline bool) string {
+	disposition := "attachment"
+	if inline {
+		disposition = "inline"
+	}
+	if fileName == "" {
+		return disposition
+	}
+	// names which can't be formatted, such as those with non ascii characters on older versions of go, are left out
+	if value := mime.FormatMediaType(disposition, map[string]string{"filename": fileName}); value != "" {
+		return value
+	}
+	return disposition
+}
+
+// ipfsContent reads a file from ipfs as an io.ReadSeeker, as needed by http.ServeContent. Seeking
+// closes the current read of the file, and the next read starts a new one at the offset
+type ipfsContent struct {
+	manager *rtfs.IpfsManager
+	hash    string
+	size    int64
+	offset  int64
+	reader  io.ReadCloser
+}
+
+func (ic *ipfsContent) Read(p []byte) (int, error) {
+	if ic.offset >= ic.size {
+		return 0, io.EOF
+	}
+	if ic.reader == nil {
+		reader, err := ic.manager.CatRange(ic.hash, ic.offset, 0)
+		if err != nil {
+			return 0, err
+		}
+		ic.reader = reader
+	}
+	n, err := ic.reader.Read(p)
+	ic.offset += int64(n)
+	return n, err
+}
+
+func (ic *ipfsContent) Seek(offset int64, whence int) (int64, error) {
+	position := offset
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		position += ic.offset
+	case io.SeekEnd:
+		position += ic.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if position < 0 {
+		return 0, errors.New("negative position")
+	}
+	if position != ic.offset {
+		ic.Close()
+		ic.offset = position
+	}
+	return position, nil
+}
+
+// Close is used to close the current read of the file, if any
+func (ic *ipfsContent) Close() error {
+	if ic.reader == nil {
+		return nil
+	}
+	err := ic.reader.Close()
+	ic.reader = nil
+	return err
+}
@@ -45,8 +45,8 @@ func CalculatePinCost(c *gin.Context) {
 		FailOnError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"total_cost_usd": totalCost,
+	c.JSON(http.StatusOK, CostResponse{
+		TotalCostUSD: totalCost,
 	})
 }
 
@@ -67,8 +67,8 @@ func CalculateFileCost(c *gin.Context) {
 		return
 	}
 	cost := utils.CalculateFileCost(holdTimeInt, file.Size)
-	c.JSON(http.StatusOK, gin.H{
-		"total_cost_usd": cost,
+	c.JSON(http.StatusOK, CostResponse{
+		TotalCostUSD: cost,
 	})
 }
 
@@ -326,7 +326,7 @@ func SubmitPinPaymentConfirmation(c *gin.Context) {
 		FailOnError(c, err)
 		return
 	}
-	mqURL, ok := c.MustGet("mq_conn_url").(string)
+	publisher, ok := c.MustGet("mq_publisher").(queue.MessagePublisher)
 	if !ok {
 		FailedToLoadMiddleware(c, "rabbitmq")
 		return
@@ -337,13 +337,8 @@ func SubmitPinPaymentConfirmation(c *gin.Context) {
 		PaymentNumber: paymentNumber,
 		ContentHash:   pp.ContentHash,
 	}
-	qm, err := queue.Initialize(queue.PinPaymentConfirmationQueue, mqURL)
-	if err != nil {
-		FailOnError(c, err)
-		return
-	}
 	fmt.Println("publishing message")
-	err = qm.PublishMessage(ppc)
+	err = publisher.PublishMessage(queue.PinPaymentConfirmationQueue, ppc)
 	if err != nil {
 		FailOnError(c, err)
 		return
@@ -433,7 +428,7 @@ func SubmitPaymentToContract(c *gin.Context) {
 		FailedToLoadMiddleware(c, "eth account")
 		return
 	}
-	mqURL, ok := c.MustGet("mq_conn_url").(string)
+	publisher, ok := c.MustGet("mq_publisher").(queue.MessagePublisher)
 	if !ok {
 		FailedToLoadMiddleware(c, "rabbitmq")
 		return
@@ -497,13 +492,8 @@ func SubmitPaymentToContract(c *gin.Context) {
 		FailOnError(c, err)
 		return
 	}
-	qm, err := queue.Initialize(queue.PinPaymentSubmissionQueue, mqURL)
-	if err != nil {
-		FailOnError(c, err)
-		return
-	}
 
-	err = qm.PublishMessage(pps)
+	err = publisher.PublishMessage(queue.PinPaymentSubmissionQueue, pps)
 	if err != nil {
 		FailOnError(c, err)
 		return
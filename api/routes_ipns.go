@@ -54,7 +54,7 @@ func PublishToIPNSDetails(c *gin.Context) {
 		FailedToLoadDatabase(c)
 		return
 	}
-	mqURL, ok := c.MustGet("mq_conn_url").(string)
+	publisher, ok := c.MustGet("mq_publisher").(queue.MessagePublisher)
 	if !ok {
 		FailOnError(c, errors.New("failed to load rabbitmq"))
 		return
@@ -87,6 +87,10 @@ func PublishToIPNSDetails(c *gin.Context) {
 		FailOnError(c, err)
 		return
 	}
+	job, err := createJob(c, ethAddress, models.JobTypeIPNSEntry)
+	if err != nil {
+		return
+	}
 
 	ie := queue.IPNSEntry{
 		CID:         hash,
@@ -96,23 +100,20 @@ func PublishToIPNSDetails(c *gin.Context) {
 		Key:         key,
 		EthAddress:  ethAddress,
 		NetworkName: "public",
+		JobID:       job.JobID,
 	}
 
 	fmt.Printf("IPNS Entry struct %+v\n", ie)
 
-	qm, err := queue.Initialize(queue.IpnsEntryQueue, mqURL)
-	if err != nil {
-		FailOnError(c, err)
-		return
-	}
 	//TODO move to fanout exchange
-	err = qm.PublishMessage(ie)
+	err = publisher.PublishMessage(queue.IpnsEntryQueue, ie)
 	if err != nil {
-		FailOnError(c, err)
+		failUnpublishedJob(c, job, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"status": "ipns entry creation sent to backend",
+		"job_id": job.JobID,
 	})
 }
 
@@ -156,18 +157,13 @@ func PublishToIPNSDetails(c *gin.Context) {
 		FailedToLoadDatabase(c)
 		return
 	}
-	mqURL, ok := c.MustGet("mq_conn_url").(string)
+	publisher, ok := c.MustGet("mq_publisher").(queue.MessagePublisher)
 	if !ok {
 		FailOnError(c, errors.New("failed to load rabbitmq"))
 		return
 	}
 
 	um := models.NewUserManager(db)
-	qm, err := queue.Initialize(queue.IpnsUpdateQueue, mqURL)
-	if err != nil {
-		FailOnError(c, err)
-		return
-	}
 
 	ownsKey, err := um.CheckIfKeyOwnedByUser(ethAddress, key)
 	if err != nil {
@@ -232,7 +228,7 @@ func PublishToIPNSDetails(c *gin.Context) {
 		EthAddress:  ethAddress,
 		NetworkName: "public",
 	}
-	err = qm.PublishMessage(ipnsUpdate)
+	err = publisher.PublishMessage(queue.IpnsUpdateQueue, ipnsUpdate)
 	if err != nil {
 		FailOnError(c, err)
 		return
@@ -246,12 +242,6 @@ func PublishToIPNSDetails(c *gin.Context) {
 
 // GenerateDNSLinkEntry is used to generate a DNS link entry
 func GenerateDNSLinkEntry(c *gin.Context) {
-	authUser := GetAuthenticatedUserFromContext(c)
-	if authUser != AdminAddress {
-		FailNotAuthorized(c, "unauthorized access to admin route")
-		return
-	}
-
 	recordName, exists := c.GetPostForm("record_name")
 	if !exists {
 		FailNoExistPostForm(c, "record_name")
@@ -300,12 +290,12 @@ func GenerateDNSLinkEntry(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"record_name":  recordName,
-		"record_value": recordValue,
-		"zone_name":    awsZone,
-		"manager":      fmt.Sprintf("%+v", awsManager),
-		"region":       aws.USWest.Name,
-		"resp":         resp,
+	c.JSON(http.StatusOK, DNSLinkResponse{
+		RecordName:  recordName,
+		RecordValue: recordValue,
+		ZoneName:    awsZone,
+		Manager:     fmt.Sprintf("%+v", awsManager),
+		Region:      aws.USWest.Name,
+		Resp:        resp,
 	})
 }
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/RTradeLtd/Temporal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+Routes used to track the state of operations sent to the queue, served under /api/v2
+*/
+
+// JobResponse holds a job of the authenticated user
+type JobResponse struct {
+	Job *models.Job `json:"job"`
+}
+
+// JobsResponse lists the jobs of the authenticated user
+type JobsResponse struct {
+	Jobs []models.Job `json:"jobs"`
+}
+
+// GetJob is used to retrieve the state of a job belonging to the authenticated user
+func GetJob(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	db, ok := loadDatabaseV2(c)
+	if !ok {
+		return
+	}
+	jm := models.NewJobManager(db)
+	job, err := jm.FindByJobID(ethAddress, c.Param("id"))
+	if err != nil {
+		FailV2(c, http.StatusNotFound, "job not found")
+		return
+	}
+	c.JSON(http.StatusOK, JobResponse{Job: job})
+}
+
+// GetJobsForAuthUser is used to list all jobs belonging to the authenticated user
+func GetJobsForAuthUser(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	db, ok := loadDatabaseV2(c)
+	if !ok {
+		return
+	}
+	jm := models.NewJobManager(db)
+	jobs, err := jm.FindJobsByUser(ethAddress)
+	if err != nil {
+		FailV2(c, http.StatusInternalServerError, "failed to find jobs")
+		return
+	}
+	c.JSON(http.StatusOK, JobsResponse{Jobs: jobs})
+}
+
+// createJob is used to create a job for an operation that is about to be sent to the queue
+func createJob(c *gin.Context, ethAddress, jobType string) (*models.Job, error) {
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return nil, errDatabaseNotLoaded
+	}
+	jm := models.NewJobManager(db)
+	job, err := jm.NewJob(ethAddress, jobType)
+	if err != nil {
+		FailOnError(c, err)
+		return nil, err
+	}
+	return job, nil
+}
+
+// failUnpublishedJob is used to mark a job as failed when its message could not be sent to the queue,
+// as no consumer would ever update it, and respond with the error
+func failUnpublishedJob(c *gin.Context, job *models.Job, err error) {
+	if db, ok := c.MustGet("db").(*gorm.DB); ok {
+		if errOne := models.NewJobManager(db).UpdateJobState(job.JobID, models.JobStateFailed, err.Error()); errOne != nil {
+			fmt.Println("error failing job ", errOne)
+		}
+	}
+	FailOnError(c, err)
+}
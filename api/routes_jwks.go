@@ -0,0 +1,23 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/RTradeLtd/Temporal/api/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// GetJWKS is used to publish the public keys tokens are signed with as a JSON Web Key Set,
+// so that other services can verify our tokens. Keys are published before they sign tokens,
+// so caching the set for a few minutes is safe
+func GetJWKS(keys *middleware.KeyRing) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		jwks, err := keys.JWKS()
+		if err != nil {
+			FailOnError(c, err)
+			return
+		}
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, gin.H{"keys": jwks})
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/api/middleware"
+	"github.com/RTradeLtd/Temporal/models"
+	"github.com/RTradeLtd/Temporal/queue"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+Failed logins are tracked for each account, and each ip address. Once a few logins have failed,
+each attempt must wait longer than the last, and too many failures locks the account, or ip address, out
+*/
+
+// UnlockAccount is used by admins to unlock an account that was locked out by failed logins
+func UnlockAccount(c *gin.Context) {
+	ethAddress, exists := c.GetPostForm("eth_address")
+	if !exists {
+		FailNoExistPostForm(c, "eth_address")
+		return
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	if err := models.NewLoginThrottleManager(db).ResetThrottle(models.AccountThrottleKey(ethAddress)); err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "account unlocked"})
+}
+
+// reserveLoginAttempt is used to count a login against its account, and ip address, before its credentials are
+// checked, so that logins made at once can't all be checked before any of them are counted. Logins to an account,
+// or from an ip address, that must wait before trying again are refused
+func reserveLoginAttempt(c *gin.Context, db *gorm.DB, ethAddress string) bool {
+	ltm := models.NewLoginThrottleManager(db)
+	ipKey := models.IPThrottleKey(c.ClientIP())
+	wait, locked, err := ltm.ReserveAttempt(ipKey, models.IPLoginPolicy)
+	if err != nil {
+		fmt.Println("error reserving login attempt ", err)
+	} else if wait > 0 {
+		middleware.AbortTooManyAttempts(c, wait, locked)
+		return false
+	}
+	if ethAddress == "" {
+		return true
+	}
+	wait, locked, err = ltm.ReserveAttempt(models.AccountThrottleKey(ethAddress), models.AccountLoginPolicy)
+	if err != nil {
+		fmt.Println("error reserving login attempt ", err)
+	} else if wait > 0 {
+		// the login is never made, so it isn't counted against the ip address either
+		if err = ltm.ReleaseAttempt(ipKey); err != nil {
+			fmt.Println("error releasing login attempt ", err)
+		}
+		middleware.AbortTooManyAttempts(c, wait, locked)
+		return false
+	}
+	return true
+}
+
+// recordLoginFailure is used to record that a reserved login failed, emailing the user if it locked their account
+func recordLoginFailure(c *gin.Context, db *gorm.DB, ethAddress string) {
+	ltm := models.NewLoginThrottleManager(db)
+	if _, err := ltm.RecordFailure(models.IPThrottleKey(c.ClientIP()), models.IPLoginPolicy); err != nil {
+		fmt.Println("error recording failed login ", err)
+	}
+	if ethAddress == "" {
+		return
+	}
+	locked, err := ltm.RecordFailure(models.AccountThrottleKey(ethAddress), models.AccountLoginPolicy)
+	if err != nil {
+		fmt.Println("error recording failed login ", err)
+		return
+	}
+	if locked {
+		if err = sendLockoutEmail(c, db, ethAddress); err != nil {
+			fmt.Println("error sending lockout email ", err)
+		}
+	}
+}
+
+// recordLoginSuccess is used to forget the failed logins to an account once its user has logged in.
+// Failures from the ip address are kept, so logging in to one account can't be used to keep guessing others,
+// and only the reserved login is no longer counted against it
+func recordLoginSuccess(c *gin.Context, db *gorm.DB, ethAddress string) {
+	ltm := models.NewLoginThrottleManager(db)
+	if err := ltm.ResetThrottle(models.AccountThrottleKey(ethAddress)); err != nil {
+		fmt.Println("error resetting login throttle ", err)
+	}
+	if err := ltm.ReleaseAttempt(models.IPThrottleKey(c.ClientIP())); err != nil {
+		fmt.Println("error releasing login attempt ", err)
+	}
+}
+
+func sendLockoutEmail(c *gin.Context, db *gorm.DB, ethAddress string) error {
+	// failed logins are tracked for addresses without accounts too, but only users are emailed
+	if models.NewUserManager(db).FindByAddress(ethAddress) == nil {
+		return nil
+	}
+	publisher, ok := c.MustGet("mq_publisher").(queue.MessagePublisher)
+	if !ok {
+		return errors.New("failed to load rabbitmq middleware")
+	}
+	es := queue.EmailSend{
+		Subject:      queue.AccountLockedSubject,
+		Content:      fmt.Sprintf(queue.AccountLockedContent, time.Now().Add(models.AccountLoginPolicy.LockoutDuration).UTC().Format(time.RFC1123)),
+		ContentType:  "",
+		EthAddresses: []string{ethAddress},
+	}
+	return publisher.PublishMessage(queue.EmailSendQueue, es)
+}
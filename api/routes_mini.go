@@ -12,11 +12,6 @@ Used to handle interaction with our minio backend
 */
 
 func MakeBucket(c *gin.Context) {
-	ethAddress := GetAuthenticatedUserFromContext(c)
-	if ethAddress != AdminAddress {
-		FailNotAuthorized(c, "unauthorized access")
-		return
-	}
 	credentials, ok := c.MustGet("minio_credentials").(map[string]string)
 	if !ok {
 		FailedToLoadMiddleware(c, "minio credentials")
@@ -0,0 +1,311 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/models"
+	"github.com/RTradeLtd/Temporal/queue"
+	"github.com/RTradeLtd/Temporal/rtfs"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+Routes implementing the IPFS Pinning Service API
+https://ipfs.github.io/pinning-services-api-spec/
+*/
+
+const (
+	// DefaultPinningServiceHoldTime is the hold time used when a pin request
+	// does not specify one through the hold_time meta key
+	DefaultPinningServiceHoldTime = int64(1)
+	// maximum number of content hashes that can be filtered on at once
+	pinningServiceMaxCIDs = 10
+	// default and maximum number of results returned when listing pins
+	pinningServiceDefaultLimit = 10
+	pinningServiceMaxLimit     = 1000
+)
+
+// PinningServicePin is the pin object as defined by the pinning service api
+type PinningServicePin struct {
+	CID     string            `json:"cid" binding:"required"`
+	Name    string            `json:"name,omitempty"`
+	Origins []string          `json:"origins,omitempty"`
+	Meta    map[string]string `json:"meta,omitempty"`
+}
+
+// PinningServicePinStatus is the pin status object as defined by the pinning service api
+type PinningServicePinStatus struct {
+	RequestID string            `json:"requestid"`
+	Status    string            `json:"status"`
+	Created   string            `json:"created"`
+	Pin       PinningServicePin `json:"pin"`
+	Delegates []string          `json:"delegates"`
+	Info      map[string]string `json:"info,omitempty"`
+}
+
+// PinningServicePinResults is the result of listing pins as defined by the pinning service api
+type PinningServicePinResults struct {
+	Count   int                       `json:"count"`
+	Results []PinningServicePinStatus `json:"results"`
+}
+
+// failPinningService is used to return an error in the format defined by the pinning service api
+func failPinningService(c *gin.Context, code int, reason, details string) {
+	c.JSON(code, gin.H{
+		"error": gin.H{
+			"reason":  reason,
+			"details": details,
+		},
+	})
+}
+
+// ListPinRequests is used to list the pin requests of the authenticated user
+func ListPinRequests(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		failPinningService(c, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", "failed to load database")
+		return
+	}
+	filter, err := parsePinRequestFilter(c)
+	if err != nil {
+		failPinningService(c, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+	pm := models.NewPinRequestManager(db)
+	count, requests, err := pm.FindPinRequests(ethAddress, *filter)
+	if err != nil {
+		failPinningService(c, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", err.Error())
+		return
+	}
+	delegates := getPinningServiceDelegates()
+	results := PinningServicePinResults{Count: count, Results: []PinningServicePinStatus{}}
+	for k := range requests {
+		results.Results = append(results.Results, formatPinStatus(&requests[k], delegates))
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+// AddPinRequest is used to queue a new pin request
+func AddPinRequest(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	pin := PinningServicePin{}
+	if err := c.ShouldBindJSON(&pin); err != nil {
+		failPinningService(c, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+	pr, err := createPinRequest(c, ethAddress, pin)
+	if err != nil {
+		return
+	}
+	c.JSON(http.StatusAccepted, formatPinStatus(pr, getPinningServiceDelegates()))
+}
+
+// GetPinRequest is used to retrieve the status of a single pin request
+func GetPinRequest(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		failPinningService(c, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", "failed to load database")
+		return
+	}
+	pm := models.NewPinRequestManager(db)
+	pr, err := pm.FindByRequestID(ethAddress, c.Param("requestid"))
+	if err != nil {
+		failPinningService(c, http.StatusNotFound, "NOT_FOUND", "the specified pin request does not exist")
+		return
+	}
+	c.JSON(http.StatusOK, formatPinStatus(pr, getPinningServiceDelegates()))
+}
+
+// ReplacePinRequest is used to replace an existing pin request with a new one.
+// The existing pin request is removed once the new one has been queued
+func ReplacePinRequest(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		failPinningService(c, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", "failed to load database")
+		return
+	}
+	pm := models.NewPinRequestManager(db)
+	existing, err := pm.FindByRequestID(ethAddress, c.Param("requestid"))
+	if err != nil {
+		failPinningService(c, http.StatusNotFound, "NOT_FOUND", "the specified pin request does not exist")
+		return
+	}
+	pin := PinningServicePin{}
+	if err := c.ShouldBindJSON(&pin); err != nil {
+		failPinningService(c, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+	pr, err := createPinRequest(c, ethAddress, pin)
+	if err != nil {
+		return
+	}
+	if err = pm.DeletePinRequest(existing); err != nil {
+		failPinningService(c, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", err.Error())
+		return
+	}
+	c.JSON(http.StatusAccepted, formatPinStatus(pr, getPinningServiceDelegates()))
+}
+
+// RemovePinRequest is used to remove a pin request. Content is retained until
+// the hold time that was paid for has passed
+func RemovePinRequest(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		failPinningService(c, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", "failed to load database")
+		return
+	}
+	pm := models.NewPinRequestManager(db)
+	pr, err := pm.FindByRequestID(ethAddress, c.Param("requestid"))
+	if err != nil {
+		failPinningService(c, http.StatusNotFound, "NOT_FOUND", "the specified pin request does not exist")
+		return
+	}
+	if err = pm.DeletePinRequest(pr); err != nil {
+		failPinningService(c, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", err.Error())
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+// createPinRequest is used to validate a pin, store the pin request and send it to the pin queue.
+// Upon failure the error response has already been written
+func createPinRequest(c *gin.Context, ethAddress string, pin PinningServicePin) (*models.PinRequest, error) {
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		failPinningService(c, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", "failed to load database")
+		return nil, errors.New("failed to load database")
+	}
+	publisher, ok := c.MustGet("mq_publisher").(queue.MessagePublisher)
+	if !ok {
+		failPinningService(c, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", "failed to load rabbitmq")
+		return nil, errors.New("failed to load rabbitmq")
+	}
+	holdTimeInt := DefaultPinningServiceHoldTime
+	if holdTime, exists := pin.Meta["hold_time"]; exists {
+		parsed, err := strconv.ParseInt(holdTime, 10, 64)
+		if err != nil || parsed <= 0 {
+			failPinningService(c, http.StatusBadRequest, "BAD_REQUEST", "hold_time meta must be a positive integer")
+			return nil, errors.New("invalid hold_time meta")
+		}
+		holdTimeInt = parsed
+	}
+	networkName := "public"
+	if name, exists := pin.Meta["network_name"]; exists && name != "public" {
+		if err := CheckAccessForPrivateNetwork(c, ethAddress, name, db); err != nil {
+			failPinningService(c, http.StatusForbidden, "FORBIDDEN", err.Error())
+			return nil, err
+		}
+		networkName = name
+	}
+	pm := models.NewPinRequestManager(db)
+	pr, err := pm.NewPinRequest(ethAddress, pin.CID, pin.Name, networkName, pin.Origins, pin.Meta, holdTimeInt)
+	if err != nil {
+		failPinningService(c, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", err.Error())
+		return nil, err
+	}
+	ip := queue.IPFSPin{
+		CID:              pin.CID,
+		NetworkName:      networkName,
+		EthAddress:       ethAddress,
+		HoldTimeInMonths: holdTimeInt,
+	}
+	if err = publisher.PublishMessageWithExchange(ip, queue.PinExchange); err != nil {
+		// the request id is never returned, so the request would otherwise be left queued forever
+		if errOne := pm.DeletePinRequest(pr); errOne != nil {
+			fmt.Println("error deleting pin request ", errOne)
+		}
+		failPinningService(c, http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", err.Error())
+		return nil, err
+	}
+	return pr, nil
+}
+
+// parsePinRequestFilter is used to parse the query parameters of a pin listing
+func parsePinRequestFilter(c *gin.Context) (*models.PinRequestFilter, error) {
+	filter := models.PinRequestFilter{Limit: pinningServiceDefaultLimit}
+	if cids := c.Query("cid"); cids != "" {
+		filter.CIDs = strings.Split(cids, ",")
+		if len(filter.CIDs) > pinningServiceMaxCIDs {
+			return nil, errors.New("a maximum of 10 cids can be filtered on")
+		}
+	}
+	filter.Name = c.Query("name")
+	// the spec defaults to only returning pinned content
+	filter.Statuses = []string{models.PinStatusPinned}
+	if statuses := c.Query("status"); statuses != "" {
+		filter.Statuses = strings.Split(statuses, ",")
+		for _, v := range filter.Statuses {
+			switch v {
+			case models.PinStatusQueued, models.PinStatusPinning, models.PinStatusPinned, models.PinStatusFailed:
+			default:
+				return nil, errors.New("status must be one of queued, pinning, pinned, failed")
+			}
+		}
+	}
+	if before := c.Query("before"); before != "" {
+		parsed, err := time.Parse(time.RFC3339, before)
+		if err != nil {
+			return nil, err
+		}
+		filter.Before = parsed
+	}
+	if after := c.Query("after"); after != "" {
+		parsed, err := time.Parse(time.RFC3339, after)
+		if err != nil {
+			return nil, err
+		}
+		filter.After = parsed
+	}
+	if limit := c.Query("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil {
+			return nil, err
+		}
+		if parsed < 1 || parsed > pinningServiceMaxLimit {
+			return nil, errors.New("limit must be between 1 and 1000")
+		}
+		filter.Limit = parsed
+	}
+	return &filter, nil
+}
+
+// formatPinStatus is used to convert a pin request into a pin status
+func formatPinStatus(pr *models.PinRequest, delegates []string) PinningServicePinStatus {
+	return PinningServicePinStatus{
+		RequestID: pr.RequestID,
+		Status:    pr.Status,
+		Created:   pr.CreatedAt.UTC().Format(time.RFC3339),
+		Pin: PinningServicePin{
+			CID:     pr.CID,
+			Name:    pr.Name,
+			Origins: pr.Origins,
+			Meta:    pr.GetMeta(),
+		},
+		Delegates: delegates,
+		Info:      map[string]string{"network_name": pr.NetworkName},
+	}
+}
+
+// getPinningServiceDelegates is used to retrieve the multiaddrs of our ipfs node,
+// which clients may connect to in order to speed up content transfer
+func getPinningServiceDelegates() []string {
+	manager, err := rtfs.Initialize("", "")
+	if err != nil {
+		return []string{}
+	}
+	id, err := manager.Shell.ID()
+	if err != nil {
+		return []string{}
+	}
+	return id.Addresses
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/RTradeLtd/Temporal/queue"
+	"github.com/gin-gonic/gin"
+)
+
+/*
+Used by admins to manage messages that have been dead-lettered by the queue consumers
+*/
+
+// DefaultDeadLetterLimit is the number of dead-letters inspected or replayed when no limit is given
+const DefaultDeadLetterLimit = 100
+
+// InspectDeadLetters is used to view dead-lettered messages for a queue, without removing them
+func InspectDeadLetters(c *gin.Context) {
+	qm, limit, ok := loadDeadLetterQueue(c)
+	if !ok {
+		return
+	}
+	defer qm.Close()
+	deadLetters, err := qm.InspectDeadLetters(limit)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"dead_letters": deadLetters})
+}
+
+// ReplayDeadLetters is used to send dead-lettered messages back to their queue to be processed again
+func ReplayDeadLetters(c *gin.Context) {
+	qm, limit, ok := loadDeadLetterQueue(c)
+	if !ok {
+		return
+	}
+	defer qm.Close()
+	replayed, err := qm.ReplayDeadLetters(limit)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"replayed": replayed})
+}
+
+// PurgeDeadLetters is used to remove all dead-lettered messages for a queue
+func PurgeDeadLetters(c *gin.Context) {
+	qm, _, ok := loadDeadLetterQueue(c)
+	if !ok {
+		return
+	}
+	defer qm.Close()
+	purged, err := qm.PurgeDeadLetters()
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
+
+// loadDeadLetterQueue is used to connect to the queue named in the request
+func loadDeadLetterQueue(c *gin.Context) (*queue.QueueManager, int, bool) {
+	queueName := c.Param("queue")
+	if !queue.IsConsumerQueue(queueName) {
+		FailNoExist(c, "invalid queue name")
+		return nil, 0, false
+	}
+	limit := DefaultDeadLetterLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limitInt, err := strconv.Atoi(limitStr)
+		if err != nil {
+			FailOnError(c, err)
+			return nil, 0, false
+		}
+		limit = limitInt
+	}
+	// dead-letters are only kept in rabbitmq, and not by brokers such as the in-memory broker of the dev command
+	publisher, ok := c.MustGet("mq_publisher").(interface {
+		ConnectionURL() string
+	})
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "dead-letters can only be managed when using rabbitmq"})
+		return nil, 0, false
+	}
+	qm, err := queue.Initialize(queueName, publisher.ConnectionURL())
+	if err != nil {
+		FailOnError(c, err)
+		return nil, 0, false
+	}
+	return qm, limit, true
+}
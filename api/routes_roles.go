@@ -0,0 +1,94 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/RTradeLtd/Temporal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+Routes used to manage the roles of users, which grant access to admin routes
+*/
+
+// GetRoles is used to list the permissions of each role, along with the users who have been granted roles
+func GetRoles(c *gin.Context) {
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	users, err := models.NewUserManager(db).FindUsersWithRoles()
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	userRoles := make(map[string][]string)
+	for _, v := range users {
+		userRoles[v.EthAddress] = v.Roles
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"roles": models.RolePermissions,
+		"users": userRoles,
+	})
+}
+
+// GrantRole is used to grant a role to a user
+func GrantRole(c *gin.Context) {
+	ethAddress, role, ok := roleFromPostForm(c)
+	if !ok {
+		return
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	if err := models.NewUserManager(db).GrantRole(ethAddress, role); err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "role granted"})
+}
+
+// RevokeRole is used to revoke a role from a user. Users can't revoke their own roles,
+// so that an instance isn't left without anyone able to manage roles
+func RevokeRole(c *gin.Context) {
+	ethAddress, role, ok := roleFromPostForm(c)
+	if !ok {
+		return
+	}
+	if ethAddress == GetAuthenticatedUserFromContext(c) {
+		FailNotAuthorized(c, "you can't revoke your own roles")
+		return
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	if err := models.NewUserManager(db).RevokeRole(ethAddress, role); err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "role revoked"})
+}
+
+func roleFromPostForm(c *gin.Context) (string, string, bool) {
+	ethAddress, exists := c.GetPostForm("eth_address")
+	if !exists {
+		FailNoExistPostForm(c, "eth_address")
+		return "", "", false
+	}
+	role, exists := c.GetPostForm("role")
+	if !exists {
+		FailNoExistPostForm(c, "role")
+		return "", "", false
+	}
+	if !models.IsValidRole(role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid role " + role})
+		return "", "", false
+	}
+	return ethAddress, role, true
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 
 	"github.com/RTradeLtd/Temporal/mini"
+	"github.com/RTradeLtd/Temporal/models"
 	"github.com/RTradeLtd/Temporal/utils"
 	"github.com/minio/minio-go"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/RTradeLtd/Temporal/rtfs"
 	"github.com/RTradeLtd/Temporal/rtfs_cluster"
 	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
 )
 
 // PinHashLocally is used to pin a hash to the local ipfs node
@@ -36,29 +38,28 @@ func PinHashLocally(c *gin.Context) {
 		FailOnError(c, err)
 		return
 	}
+	job, err := createJob(c, uploadAddress, models.JobTypeIPFSPin)
+	if err != nil {
+		return
+	}
 
 	ip := queue.IPFSPin{
 		CID:              hash,
 		NetworkName:      "public",
 		EthAddress:       uploadAddress,
 		HoldTimeInMonths: holdTimeInt,
+		JobID:            job.JobID,
 	}
 
-	mqConnectionURL, ok := c.MustGet("mq_conn_url").(string)
+	publisher, ok := c.MustGet("mq_publisher").(queue.MessagePublisher)
 	if !ok {
 		FailOnError(c, errors.New("unable to load rabbitmq"))
 		return
 	}
 
-	qm, err := queue.Initialize(queue.IpfsPinQueue, mqConnectionURL)
+	err = publisher.PublishMessageWithExchange(ip, queue.PinExchange)
 	if err != nil {
-		FailOnError(c, err)
-		return
-	}
-
-	err = qm.PublishMessageWithExchange(ip, queue.PinExchange)
-	if err != nil {
-		FailOnError(c, err)
+		failUnpublishedJob(c, job, err)
 		return
 	}
 
@@ -69,20 +70,13 @@ func PinHashLocally(c *gin.Context) {
 		HoldTimeInMonths: holdTimeInt,
 		NetworkName:      "public",
 	}
-	// assert type assertion retrieving info from middleware
-	// initialize the queue
-	qm, err = queue.Initialize(queue.DatabasePinAddQueue, mqConnectionURL)
-	if err != nil {
-		FailOnError(c, err)
-		return
-	}
 	// publish the message, if there was an error finish processing
-	err = qm.PublishMessage(dpa)
+	err = publisher.PublishMessage(queue.DatabasePinAddQueue, dpa)
 	if err != nil {
 		FailOnError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"upload": dpa})
+	c.JSON(http.StatusOK, gin.H{"upload": dpa, "job_id": job.JobID})
 }
 
 // GetFileSizeInBytesForObject is used to retrieve the size of an object in bytes
@@ -137,7 +131,7 @@ func AddFileLocallyAdvanced(c *gin.Context) {
 		FailedToLoadMiddleware(c, "minio endpoint")
 		return
 	}
-	mqURL, ok := c.MustGet("mq_conn_url").(string)
+	publisher, ok := c.MustGet("mq_publisher").(queue.MessagePublisher)
 	if !ok {
 		FailedToLoadMiddleware(c, "rabbitmq")
 		return
@@ -178,27 +172,29 @@ func AddFileLocallyAdvanced(c *gin.Context) {
 		return
 	}
 	fmt.Println("file stored in minio")
+	job, err := createJob(c, ethAddress, models.JobTypeIPFSFile)
+	if err != nil {
+		return
+	}
 	ifp := queue.IPFSFile{
 		BucketName:       FilesUploadBucket,
 		ObjectName:       objectName,
 		EthAddress:       ethAddress,
 		NetworkName:      "public",
 		HoldTimeInMonths: holdTimeInMonths,
-	}
-	qm, err := queue.Initialize(queue.IpfsFileQueue, mqURL)
-	if err != nil {
-		FailOnError(c, err)
-		return
+		JobID:            job.JobID,
+		FileName:         uploadFileName(fileHandler.Filename),
 	}
 
-	err = qm.PublishMessage(ifp)
+	err = publisher.PublishMessage(queue.IpfsFileQueue, ifp)
 	if err != nil {
-		FailOnError(c, err)
+		failUnpublishedJob(c, job, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"eth_address": ethAddress,
-		"hold_time":   holdTimeInMonthsInt,
+	c.JSON(http.StatusOK, FileAddJobResponse{
+		EthAddress: ethAddress,
+		HoldTime:   holdTimeInMonthsInt,
+		JobID:      job.JobID,
 	})
 }
 
@@ -261,14 +257,9 @@ func AddFileLocally(c *gin.Context) {
 		HoldTimeInMonths: holdTimeinMonthsInt,
 		UploaderAddress:  uploaderAddress,
 		NetworkName:      "public",
+		FileName:         uploadFileName(fileHandler.Filename),
 	}
-	mqConnectionURL := c.MustGet("mq_conn_url").(string)
-	// initialize a connectino to rabbitmq
-	qm, err := queue.Initialize(queue.DatabaseFileAddQueue, mqConnectionURL)
-	if err != nil {
-		FailOnError(c, err)
-		return
-	}
+	publisher := c.MustGet("mq_publisher").(queue.MessagePublisher)
 	clusterManager, err := rtfs_cluster.Initialize()
 	if err != nil {
 		FailOnError(c, err)
@@ -288,21 +279,16 @@ func AddFileLocally(c *gin.Context) {
 		}
 	}()
 	// publish the database file add message
-	err = qm.PublishMessage(dfa)
+	err = publisher.PublishMessage(queue.DatabaseFileAddQueue, dfa)
 	if err != nil {
 		FailOnError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"response": resp})
+	c.JSON(http.StatusOK, FileAddResponse{Response: resp})
 }
 
 // IpfsPubSubPublish is used to publish a pubsub msg
 func IpfsPubSubPublish(c *gin.Context) {
-	ethAddress := GetAuthenticatedUserFromContext(c)
-	if ethAddress != AdminAddress {
-		FailNotAuthorized(c, "unauthorized access to admin route")
-		return
-	}
 	topic := c.Param("topic")
 	message, present := c.GetPostForm("message")
 	if !present {
@@ -327,11 +313,6 @@ func IpfsPubSubPublish(c *gin.Context) {
 
 // IpfsPubSubConsume is used to consume pubsub messages
 func IpfsPubSubConsume(c *gin.Context) {
-	ethAddress := GetAuthenticatedUserFromContext(c)
-	if ethAddress != AdminAddress {
-		FailNotAuthorized(c, "unauthorized access to admin route")
-		return
-	}
 	contextCopy := c.Copy()
 	topic := contextCopy.Param("topic")
 	manager, err := rtfs.Initialize("", "")
@@ -349,44 +330,75 @@ func IpfsPubSubConsume(c *gin.Context) {
 	})
 }
 
-// RemovePinFromLocalHost is used to remove a pin from the ipfs instance
-// TODO: fully implement
+// RemovePinFromLocalHost is used to release the authenticated user's pin of content on the public network.
+// The content is only unpinned once no other user holds an active retention of it
 func RemovePinFromLocalHost(c *gin.Context) {
-	// fetch hash param
 	hash := c.Param("hash")
 	ethAddress := GetAuthenticatedUserFromContext(c)
-	rm := queue.IPFSPinRemoval{
-		ContentHash: hash,
-		NetworkName: "public",
-		EthAddress:  ethAddress,
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
 	}
-	mqURL, ok := c.MustGet("mq_conn_url").(string)
+	publisher, ok := c.MustGet("mq_publisher").(queue.MessagePublisher)
 	if !ok {
 		FailedToLoadMiddleware(c, "rabbit mq")
 		return
 	}
-	qm, err := queue.Initialize(queue.IpfsPinRemovalQueue, mqURL)
-	if err != nil {
-		FailOnError(c, err)
+	job, err := queuePinRemoval(db, publisher, ethAddress, hash, "public")
+	if err == errPinNotOwned {
+		FailNotAuthorized(c, err.Error())
 		return
 	}
-	err = qm.PublishMessageWithExchange(rm, queue.PinRemovalExchange)
 	if err != nil {
 		FailOnError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"status": "pin removal sent to backend",
+	c.JSON(http.StatusOK, StatusJobResponse{
+		Status: "pin removal sent to backend",
+		JobID:  job.JobID,
 	})
 }
 
+// errPinNotOwned is returned when a user attempts to remove a pin of content they haven't pinned
+var errPinNotOwned = errors.New("content is not pinned by this account")
+
+// queuePinRemoval is used to create a job releasing a user's pin of content, and send it to the queue.
+// Ownership is checked before queueing, so users find out straight away when there is nothing to release
+func queuePinRemoval(db *gorm.DB, publisher queue.MessagePublisher, ethAddress, hash, networkName string) (*models.Job, error) {
+	um := models.NewUploadManager(db)
+	upload, err := um.FindUploadByHashAndNetwork(hash, networkName)
+	if err == nil {
+		_, err = um.FindUploadOwner(upload.ID, ethAddress)
+	}
+	if err == gorm.ErrRecordNotFound {
+		return nil, errPinNotOwned
+	}
+	if err != nil {
+		return nil, err
+	}
+	jm := models.NewJobManager(db)
+	job, err := jm.NewJob(ethAddress, models.JobTypeIPFSPinRemoval)
+	if err != nil {
+		return nil, err
+	}
+	rm := queue.IPFSPinRemoval{
+		ContentHash: hash,
+		NetworkName: networkName,
+		EthAddress:  ethAddress,
+		JobID:       job.JobID,
+	}
+	if err = publisher.PublishMessageWithExchange(rm, queue.PinRemovalExchange); err != nil {
+		if errOne := jm.UpdateJobState(job.JobID, models.JobStateFailed, err.Error()); errOne != nil {
+			fmt.Println("error failing job ", errOne)
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
 // GetLocalPins is used to get the pins tracked by the local ipfs node
 func GetLocalPins(c *gin.Context) {
-	ethAddress := GetAuthenticatedUserFromContext(c)
-	if ethAddress != AdminAddress {
-		FailNotAuthorized(c, "unauthorized access to admin route")
-		return
-	}
 	// initialize a connection toe the local ipfs node
 	manager, err := rtfs.Initialize("", "")
 	if err != nil {
@@ -437,78 +449,3 @@ func CheckLocalNodeForPin(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"present": present})
 }
-
-// DownloadContentHash is used to download a particular content hash from the network
-//
-func DownloadContentHash(c *gin.Context) {
-	_, exists := c.GetPostForm("use_private_network")
-	if exists {
-		DownloadContentHashForPrivateNetwork(c)
-		return
-	}
-	ethAddress := GetAuthenticatedUserFromContext(c)
-	// Admin locked for now
-	if ethAddress != AdminAddress {
-		FailNotAuthorized(c, "unauthorized access to admin route")
-		return
-	}
-	var contentType string
-	// fetch the specified content type from the user
-	contentType, exists = c.GetPostForm("content_type")
-	// if not specified, provide a default
-	if !exists {
-		contentType = "application/octet-stream"
-	}
-
-	// get any extra headers the user might want
-	exHeaders := c.PostFormArray("extra_headers")
-
-	// get the content hash that is to be downloaded
-	contentHash := c.Param("hash")
-	// initialize our connection to IPFS
-	manager, err := rtfs.Initialize("", "")
-	if err != nil {
-		FailOnError(c, err)
-		return
-	}
-	// read the contents of the file
-	reader, err := manager.Shell.Cat(contentHash)
-	if err != nil {
-		FailOnError(c, err)
-		return
-	}
-	// get the size of hte file in bytes
-	sizeInBytes, err := manager.GetObjectFileSizeInBytes(contentHash)
-	if err != nil {
-		FailOnError(c, err)
-		return
-	}
-	// parse extra headers if there are any
-	extraHeaders := make(map[string]string)
-	var header string
-	var value string
-	// only process if there is actual data to process
-	// this will always be admin locked
-	if len(exHeaders) > 0 && ethAddress == AdminAddress {
-		// the array must be of equal length, as a header has two parts
-		// the name of the header, and its value
-		// this expects the user to have properly formatted the headers
-		// we will need to restrict the headers that we process so we don't
-		// open ourselves up to being attacked
-		if len(exHeaders)%2 != 0 {
-			FailOnError(c, errors.New("extra_headers post form is not even in length"))
-			return
-		}
-		// parse through the available headers
-		for i := 1; i < len(exHeaders)-1; i += 2 {
-			// retrieve header name
-			header = exHeaders[i-1]
-			// retrieve header value
-			value = exHeaders[i]
-			// store data
-			extraHeaders[header] = value
-		}
-	}
-	// send them the file
-	c.DataFromReader(200, int64(sizeInBytes), contentType, reader, extraHeaders)
-}
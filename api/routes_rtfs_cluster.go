@@ -55,31 +55,20 @@ func PinHashToCluster(c *gin.Context) {
 		HoldTimeInMonths: holdTimeInt,
 	}
 	// assert type assertion retrieving info from middleware
-	mqConnectionURL := c.MustGet("mq_conn_url").(string)
+	publisher := c.MustGet("mq_publisher").(queue.MessagePublisher)
 	// initialize the queue
-	qm, err := queue.Initialize(queue.DatabasePinAddQueue, mqConnectionURL)
-	if err != nil {
-		FailOnError(c, err)
-		return
-	}
 	// publish the message, if there was an error finish processing
-	err = qm.PublishMessage(dpa)
+	err = publisher.PublishMessage(queue.DatabasePinAddQueue, dpa)
 	if err != nil {
 		FailOnError(c, err)
 		return
 	}
-	qm.Close()
 	c.JSON(http.StatusOK, gin.H{"upload": dpa})
 }
 
 // SyncClusterErrorsLocally is used to parse through the local cluster state
 // and sync any errors that are detected.
 func SyncClusterErrorsLocally(c *gin.Context) {
-	ethAddress := GetAuthenticatedUserFromContext(c)
-	if ethAddress != AdminAddress {
-		FailNotAuthorized(c, "unauthorized access to admin route")
-		return
-	}
 	// initialize a conection to the cluster
 	manager, err := rtfs_cluster.Initialize()
 	if err != nil {
@@ -95,31 +84,8 @@ func SyncClusterErrorsLocally(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"synced-cids": syncedCids})
 }
 
-// RemovePinFromCluster is used to remove a pin from the cluster global state
-// this will mean that all nodes in the cluster will no longer track the pin
-// TODO: fully implement, add in goroutines
-func RemovePinFromCluster(c *gin.Context) {
-	hash := c.Param("hash")
-	manager, err := rtfs_cluster.Initialize()
-	if err != nil {
-		FailOnError(c, err)
-		return
-	}
-	err = manager.RemovePinFromCluster(hash)
-	if err != nil {
-		FailOnError(c, err)
-		return
-	}
-	c.JSON(http.StatusOK, gin.H{"deleted": hash})
-}
-
 // GetLocalStatusForClusterPin is used to get teh localnode's cluster status for a particular pin
 func GetLocalStatusForClusterPin(c *gin.Context) {
-	ethAddress := GetAuthenticatedUserFromContext(c)
-	if ethAddress != AdminAddress {
-		FailNotAuthorized(c, "unauthorized access to admin route")
-		return
-	}
 	hash := c.Param("hash")
 	// initialize a connection to the cluster
 	manager, err := rtfs_cluster.Initialize()
@@ -158,11 +124,6 @@ func GetGlobalStatusForClusterPin(c *gin.Context) {
 // cluster state, and not the rest of the cluster
 // TODO: cleanup
 func FetchLocalClusterStatus(c *gin.Context) {
-	ethAddress := GetAuthenticatedUserFromContext(c)
-	if ethAddress != AdminAddress {
-		FailNotAuthorized(c, "unauthorized access to admin route")
-		return
-	}
 	// this will hold all the retrieved content hashes
 	var cids []*gocid.Cid
 	// this will hold all the statuses of the content hashes
@@ -24,6 +24,16 @@ func PinToHostedIPFSNetwork(c *gin.Context) {
 	networkName, exists := c.GetPostForm("network_name")
 	if !exists {
 		FailNoExistPostForm(c, "network_name")
+		return
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	if err := CheckAccessForPrivateNetwork(c, ethAddress, networkName, db); err != nil {
+		FailNotAuthorized(c, err.Error())
+		return
 	}
 	holdTimeInMonths, exists := c.GetPostForm("hold_time")
 	if !exists {
@@ -35,33 +45,33 @@ func PinToHostedIPFSNetwork(c *gin.Context) {
 		FailOnError(c, err)
 		return
 	}
+	job, err := createJob(c, ethAddress, models.JobTypeIPFSPin)
+	if err != nil {
+		return
+	}
 
 	ip := queue.IPFSPin{
 		CID:              hash,
 		NetworkName:      networkName,
 		EthAddress:       ethAddress,
 		HoldTimeInMonths: holdTimeInt,
+		JobID:            job.JobID,
 	}
 
-	mqConnectionURL, ok := c.MustGet("mq_conn_url").(string)
+	publisher, ok := c.MustGet("mq_publisher").(queue.MessagePublisher)
 	if !ok {
 		FailOnError(c, errors.New("unable to load rabbitmq"))
 		return
 	}
 
-	qm, err := queue.Initialize(queue.IpfsPinQueue, mqConnectionURL)
+	err = publisher.PublishMessageWithExchange(ip, queue.PinExchange)
 	if err != nil {
-		FailOnError(c, err)
+		failUnpublishedJob(c, job, err)
 		return
 	}
-
-	err = qm.PublishMessageWithExchange(ip, queue.PinExchange)
-	if err != nil {
-		FailOnError(c, err)
-		return
-	}
-	c.JSON(http.StatusOK, gin.H{
-		"status": "content pin request sent to backend",
+	c.JSON(http.StatusOK, StatusJobResponse{
+		Status: "content pin request sent to backend",
+		JobID:  job.JobID,
 	})
 }
 
@@ -77,9 +87,9 @@ func GetFileSizeInBytesForObjectForHostedIPFSNetwork(c *gin.Context) {
 		FailedToLoadDatabase(c)
 		return
 	}
-	err := CheckAccessForPrivateNetwork(ethAddress, networkName, db)
+	err := CheckAccessForPrivateNetwork(c, ethAddress, networkName, db)
 	if err != nil {
-		FailOnError(c, err)
+		FailNotAuthorized(c, err.Error())
 		return
 	}
 
@@ -125,14 +135,14 @@ func AddFileToHostedIPFSNetwork(c *gin.Context) {
 		FailedToLoadDatabase(c)
 		return
 	}
-	mqURL, ok := c.MustGet("mq_conn_url").(string)
+	publisher, ok := c.MustGet("mq_publisher").(queue.MessagePublisher)
 	if !ok {
 		FailOnError(c, errors.New("failed to load rabbitmq"))
 		return
 	}
-	err := CheckAccessForPrivateNetwork(ethAddress, networkName, db)
+	err := CheckAccessForPrivateNetwork(c, ethAddress, networkName, db)
 	if err != nil {
-		FailOnError(c, err)
+		FailNotAuthorized(c, err.Error())
 		return
 	}
 
@@ -158,11 +168,6 @@ func AddFileToHostedIPFSNetwork(c *gin.Context) {
 		FailOnError(c, err)
 		return
 	}
-	qm, err := queue.Initialize(queue.DatabaseFileAddQueue, mqURL)
-	if err != nil {
-		FailOnError(c, err)
-		return
-	}
 
 	fmt.Println("fetching file")
 	// fetch the file, and create a handler to interact with it
@@ -188,15 +193,16 @@ func AddFileToHostedIPFSNetwork(c *gin.Context) {
 		HoldTimeInMonths: holdTimeInt,
 		UploaderAddress:  ethAddress,
 		NetworkName:      networkName,
+		FileName:         uploadFileName(fileHandler.Filename),
 	}
 	fmt.Printf("+%v\n", dfa)
-	err = qm.PublishMessage(dfa)
+	err = publisher.PublishMessage(queue.DatabaseFileAddQueue, dfa)
 	if err != nil {
 		FailOnError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"status": resp,
+	c.JSON(http.StatusOK, PrivateFileAddResponse{
+		Status: resp,
 	})
 }
 
@@ -213,9 +219,10 @@ func IpfsPubSubPublishToHostedIPFSNetwork(c *gin.Context) {
 		return
 	}
 
-	err := CheckAccessForPrivateNetwork(ethAddress, networkName, db)
+	err := CheckAccessForPrivateNetwork(c, ethAddress, networkName, db)
 	if err != nil {
-		FailOnError(c, err)
+		FailNotAuthorized(c, err.Error())
+		return
 	}
 
 	im := models.NewHostedIPFSNetworkManager(db)
@@ -249,11 +256,6 @@ func IpfsPubSubPublishToHostedIPFSNetwork(c *gin.Context) {
 func IpfsPubSubConsumeForHostedIPFSNetwork(c *gin.Context) {
 	cC := c.Copy()
 
-	ethAddress := GetAuthenticatedUserFromContext(cC)
-	if ethAddress != AdminAddress {
-		FailNotAuthorized(c, "unauthorized access to admin route")
-		return
-	}
 	networkName, exists := cC.GetPostForm("network_name")
 	if !exists {
 		FailNoExistPostForm(c, "network_name")
@@ -286,41 +288,42 @@ func IpfsPubSubConsumeForHostedIPFSNetwork(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "consuming messages in background"})
 }
 
-// RemovePinFromLocalHostForHostedIPFSNetwork is used to remove a content hash from a private hosted ipfs network
+// RemovePinFromLocalHostForHostedIPFSNetwork is used to release the authenticated user's pin of content on a private
+// hosted ipfs network. As forms aren't parsed for DELETE requests, the network is given with the network_name query parameter
 func RemovePinFromLocalHostForHostedIPFSNetwork(c *gin.Context) {
 	hash := c.Param("hash")
 	ethAddress := GetAuthenticatedUserFromContext(c)
-	if ethAddress != AdminAddress {
-		FailNotAuthorized(c, "unauthorized access to admin route")
+	networkName, exists := c.GetQuery("network_name")
+	if !exists {
+		FailNoExist(c, "network_name query parameter not present")
 		return
 	}
-	networkName, exists := c.GetPostForm("network_name")
-	if !exists {
-		FailNoExistPostForm(c, "network_name")
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
 		return
 	}
-	rm := queue.IPFSPinRemoval{
-		ContentHash: hash,
-		NetworkName: networkName,
-		EthAddress:  ethAddress,
+	if err := CheckAccessForPrivateNetwork(c, ethAddress, networkName, db); err != nil {
+		FailNotAuthorized(c, err.Error())
+		return
 	}
-	// TODO:
-	// change to send a message to the cluster to depin
-	mqConnectionURL := c.MustGet("mq_conn_url").(string)
-	qm, err := queue.Initialize(queue.IpfsPinRemovalQueue, mqConnectionURL)
-	if err != nil {
-		FailOnError(c, err)
+	publisher, ok := c.MustGet("mq_publisher").(queue.MessagePublisher)
+	if !ok {
+		FailedToLoadMiddleware(c, "rabbit mq")
+		return
+	}
+	job, err := queuePinRemoval(db, publisher, ethAddress, hash, networkName)
+	if err == errPinNotOwned {
+		FailNotAuthorized(c, err.Error())
 		return
 	}
-	err = qm.PublishMessageWithExchange(rm, queue.PinRemovalExchange)
 	if err != nil {
 		FailOnError(c, err)
 		return
 	}
-	// TODO:
-	// add in appropriate rabbitmq processing to delete from database
-	c.JSON(http.StatusOK, gin.H{
-		"status": "pin removal sent to backend",
+	c.JSON(http.StatusOK, StatusJobResponse{
+		Status: "pin removal sent to backend",
+		JobID:  job.JobID,
 	})
 }
 
@@ -336,9 +339,9 @@ func GetLocalPinsForHostedIPFSNetwork(c *gin.Context) {
 		FailedToLoadDatabase(c)
 		return
 	}
-	err := CheckAccessForPrivateNetwork(ethAddress, networkName, db)
+	err := CheckAccessForPrivateNetwork(c, ethAddress, networkName, db)
 	if err != nil {
-		FailOnError(c, err)
+		FailNotAuthorized(c, err.Error())
 		return
 	}
 	im := models.NewHostedIPFSNetworkManager(db)
@@ -375,9 +378,9 @@ func GetObjectStatForIpfsForHostedIPFSNetwork(c *gin.Context) {
 		FailedToLoadDatabase(c)
 		return
 	}
-	err := CheckAccessForPrivateNetwork(ethAddress, networkName, db)
+	err := CheckAccessForPrivateNetwork(c, ethAddress, networkName, db)
 	if err != nil {
-		FailOnError(c, err)
+		FailNotAuthorized(c, err.Error())
 		return
 	}
 
@@ -413,9 +416,9 @@ func CheckLocalNodeForPinForHostedIPFSNetwork(c *gin.Context) {
 		FailedToLoadDatabase(c)
 		return
 	}
-	err := CheckAccessForPrivateNetwork(ethAddress, networkName, db)
+	err := CheckAccessForPrivateNetwork(c, ethAddress, networkName, db)
 	if err != nil {
-		FailOnError(c, err)
+		FailNotAuthorized(c, err.Error())
 		return
 	}
 	im := models.NewHostedIPFSNetworkManager(db)
@@ -453,23 +456,18 @@ func PublishDetailedIPNSToHostedIPFSNetwork(c *gin.Context) {
 		FailedToLoadDatabase(c)
 		return
 	}
-	mqURL, ok := c.MustGet("mq_conn_url").(string)
+	publisher, ok := c.MustGet("mq_publisher").(queue.MessagePublisher)
 	if !ok {
 		FailOnError(c, errors.New("failed to load rabbitmq"))
 		return
 	}
-	err := CheckAccessForPrivateNetwork(ethAddress, networkName, db)
+	err := CheckAccessForPrivateNetwork(c, ethAddress, networkName, db)
 	if err != nil {
-		FailOnError(c, err)
+		FailNotAuthorized(c, err.Error())
 		return
 	}
 
 	um := models.NewUserManager(db)
-	qm, err := queue.Initialize(queue.IpnsUpdateQueue, mqURL)
-	if err != nil {
-		FailOnError(c, err)
-		return
-	}
 	hash, present := c.GetPostForm("hash")
 	if !present {
 		FailNoExistPostForm(c, "hash")
@@ -570,7 +568,7 @@ func PublishDetailedIPNSToHostedIPFSNetwork(c *gin.Context) {
 		Resolve:     resolve,
 		NetworkName: networkName,
 	}
-	err = qm.PublishMessage(ipnsUpdate)
+	err = publisher.PublishMessage(queue.IpnsUpdateQueue, ipnsUpdate)
 	if err != nil {
 		FailOnError(c, err)
 		return
@@ -584,13 +582,8 @@ func PublishDetailedIPNSToHostedIPFSNetwork(c *gin.Context) {
 }
 
 func CreateHostedIPFSNetworkEntryInDatabase(c *gin.Context) {
-	// lock down as admin route for now
 	cC := c.Copy()
 	ethAddress := GetAuthenticatedUserFromContext(cC)
-	if ethAddress != AdminAddress {
-		FailNotAuthorized(c, "unauthorized access")
-		return
-	}
 
 	networkName, exists := cC.GetPostForm("network_name")
 	if !exists {
@@ -621,6 +614,10 @@ func CreateHostedIPFSNetworkEntryInDatabase(c *gin.Context) {
 		return
 	}
 	users := cC.PostFormArray("users")
+	// when no users are given, only the creator of the network has access to it
+	if len(users) == 0 {
+		users = []string{ethAddress}
+	}
 	var localNodeAddresses []string
 	var bootstrapPeerAddresses []string
 
@@ -678,34 +675,20 @@ func CreateHostedIPFSNetworkEntryInDatabase(c *gin.Context) {
 		return
 	}
 	um := models.NewUserManager(db)
-
-	if len(users) > 0 {
-		for _, v := range users {
-			err := um.AddIPFSNetworkForUser(v, networkName)
-			if err != nil {
-				FailOnError(c, err)
-				return
-			}
-		}
-	} else {
-		err := um.AddIPFSNetworkForUser(AdminAddress, networkName)
+	for _, v := range users {
+		err := um.AddIPFSNetworkForUser(v, networkName)
 		if err != nil {
 			FailOnError(c, err)
 			return
 		}
 	}
-	c.JSON(http.StatusCreated, gin.H{
-		"network": network,
+	c.JSON(http.StatusCreated, PrivateNetworkResponse{
+		Network: network,
 	})
 
 }
 
 func GetIPFSPrivateNetworkByName(c *gin.Context) {
-	ethAddress := GetAuthenticatedUserFromContext(c)
-	if ethAddress != AdminAddress {
-		FailNotAuthorized(c, "unauthorized access")
-		return
-	}
 	db, ok := c.MustGet("db").(*gorm.DB)
 	if !ok {
 		FailedToLoadDatabase(c)
@@ -723,8 +706,8 @@ func GetIPFSPrivateNetworkByName(c *gin.Context) {
 		FailOnError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"network": net,
+	c.JSON(http.StatusOK, PrivateNetworkResponse{
+		Network: net,
 	})
 }
 
@@ -742,8 +725,8 @@ func GetAuthorizedPrivateNetworks(c *gin.Context) {
 		FailOnError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"networks": networks,
+	c.JSON(http.StatusOK, PrivateNetworksResponse{
+		Networks: networks,
 	})
 }
 
@@ -762,9 +745,9 @@ func GetUploadsByNetworkName(c *gin.Context) {
 		return
 	}
 
-	err := CheckAccessForPrivateNetwork(ethAddress, networkName, db)
+	err := CheckAccessForPrivateNetwork(c, ethAddress, networkName, db)
 	if err != nil {
-		FailOnError(c, err)
+		FailNotAuthorized(c, err.Error())
 		return
 	}
 
@@ -774,96 +757,7 @@ func GetUploadsByNetworkName(c *gin.Context) {
 		FailOnError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"uploads": uploads,
+	c.JSON(http.StatusOK, PrivateNetworkUploadsResponse{
+		Uploads: uploads,
 	})
 }
-
-// DownloadContentHash is used to download a particular content hash from the network
-//
-func DownloadContentHashForPrivateNetwork(c *gin.Context) {
-	networkName, exists := c.GetPostForm("network_name")
-	if !exists {
-		FailNoExistPostForm(c, "network_name")
-		return
-	}
-
-	var contentType string
-	// fetch the specified content type from the user
-	contentType, exists = c.GetPostForm("content_type")
-	// if not specified, provide a default
-	if !exists {
-		contentType = "application/octet-stream"
-	}
-
-	// get any extra headers the user might want
-	exHeaders := c.PostFormArray("extra_headers")
-
-	ethAddress := GetAuthenticatedUserFromContext(c)
-
-	db, ok := c.MustGet("db").(*gorm.DB)
-	if !ok {
-		FailedToLoadDatabase(c)
-		return
-	}
-	err := CheckAccessForPrivateNetwork(ethAddress, networkName, db)
-	if err != nil {
-		FailOnError(c, err)
-		return
-	}
-	im := models.NewHostedIPFSNetworkManager(db)
-	apiURL, err := im.GetAPIURLByName(networkName)
-	if err != nil {
-		FailOnError(c, err)
-		return
-	}
-	// get the content hash that is to be downloaded
-	contentHash := c.Param("hash")
-
-	// initialize our connection to IPFS
-	manager, err := rtfs.Initialize("", apiURL)
-	if err != nil {
-		FailOnError(c, err)
-		return
-	}
-	// read the contents of the file
-	reader, err := manager.Shell.Cat(contentHash)
-	if err != nil {
-		FailOnError(c, err)
-		return
-	}
-	// get the size of hte file in bytes
-	sizeInBytes, err := manager.GetObjectFileSizeInBytes(contentHash)
-	if err != nil {
-		FailOnError(c, err)
-		return
-	}
-	// parse extra headers if there are any
-	extraHeaders := make(map[string]string)
-	var header string
-	var value string
-	// only process if there is actual data to process
-	// this will always be admin locked
-	if len(exHeaders) > 0 && ethAddress == AdminAddress {
-		// the array must be of equal length, as a header has two parts
-		// the name of the header, and its value
-		// this expects the user to have properly formatted the headers
-		// we will need to restrict the headers that we process so we don't
-		// open ourselves up to being attacked
-		if len(exHeaders)%2 != 0 {
-			FailOnError(c, errors.New("extra_headers post form is not even in length"))
-			return
-		}
-		// parse through the available headers
-		for i := 1; i < len(exHeaders)-1; i += 2 {
-			// retrieve header name
-			header = exHeaders[i-1]
-			// retrieve header value
-			value = exHeaders[i]
-			// store data
-			extraHeaders[header] = value
-		}
-	}
-	// send them the file
-	c.DataFromReader(200, int64(sizeInBytes), contentType, reader, extraHeaders)
-}
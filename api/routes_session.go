@@ -0,0 +1,219 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/api/middleware"
+	"github.com/RTradeLtd/Temporal/models"
+	jwt "github.com/appleboy/gin-jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+Routes used to log in, and manage the sessions of an account. Each login creates a session,
+whose JWTs are short lived and refreshed with a refresh token that is replaced on every use
+*/
+
+// LoginHandler is used to log in with a password, creating a session.
+// Payload needs to be json in the form of {"username": "ETH ADDRESS", "password": "PASSWORD"}
+func LoginHandler(authWare *jwt.GinJWTMiddleware, keys *middleware.KeyRing) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var login jwt.Login
+		if err := c.BindJSON(&login); err != nil {
+			authWare.Unauthorized(c, http.StatusBadRequest, "Missing Username or Password")
+			return
+		}
+		db, ok := c.MustGet("db").(*gorm.DB)
+		if !ok {
+			FailedToLoadDatabase(c)
+			return
+		}
+		userID, ok := passwordLogin(c, authWare, db, login.Username, login.Password)
+		if !ok {
+			return
+		}
+		issueSession(c, authWare, keys, userID)
+	}
+}
+
+// RefreshSessionHandler is used to exchange the refresh token of a session for a new JWT, and a new refresh token
+func RefreshSessionHandler(authWare *jwt.GinJWTMiddleware, keys *middleware.KeyRing) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, exists := c.GetPostForm("refresh_token")
+		if !exists {
+			FailNoExistPostForm(c, "refresh_token")
+			return
+		}
+		db, ok := c.MustGet("db").(*gorm.DB)
+		if !ok {
+			FailedToLoadDatabase(c)
+			return
+		}
+		session, refreshToken, ok := rotateRefreshToken(c, authWare, db, token)
+		if !ok {
+			return
+		}
+		respondWithSession(c, authWare, keys, session, refreshToken)
+	}
+}
+
+// GetSessionsForAuthUser is used to list the active sessions of the authenticated user
+func GetSessionsForAuthUser(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	sessions, err := models.NewSessionManager(db).FindActiveSessionsByUser(ethAddress)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"sessions":        sessions,
+		"current_session": c.GetString("session_id"),
+	})
+}
+
+// Logout is used to revoke the session the request was made with
+func Logout(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	sessionID := c.GetString("session_id")
+	if sessionID == "" {
+		FailOnError(c, errors.New("requests made with an api key have no session"))
+		return
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	if err := models.NewSessionManager(db).RevokeSession(ethAddress, sessionID); err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "logged out"})
+}
+
+// RevokeSession is used to revoke one of the sessions of the authenticated user
+func RevokeSession(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	if err := models.NewSessionManager(db).RevokeSession(ethAddress, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "session revoked"})
+}
+
+// RevokeAllSessions is used to revoke every session of the authenticated user, logging them out everywhere
+func RevokeAllSessions(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	if err := models.NewSessionManager(db).RevokeAllSessions(ethAddress, ""); err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "all sessions revoked"})
+}
+
+// SessionResponse holds the tokens of a session, returned by logins and refreshes
+type SessionResponse struct {
+	Token         string `json:"token"`
+	Expire        string `json:"expire"`
+	RefreshToken  string `json:"refresh_token"`
+	RefreshExpire string `json:"refresh_expire"`
+}
+
+// passwordLogin is used to check the password, and two factor code, of a login, responding
+// to the request if the login is throttled or fails
+func passwordLogin(c *gin.Context, authWare *jwt.GinJWTMiddleware, db *gorm.DB, username, password string) (string, bool) {
+	if !reserveLoginAttempt(c, db, username) {
+		return "", false
+	}
+	userID, ok := authWare.Authenticator(username, password, c)
+	if !ok {
+		recordLoginFailure(c, db, username)
+		authWare.Unauthorized(c, http.StatusUnauthorized, "Incorrect Username / Password")
+		return "", false
+	}
+	if userID == "" {
+		userID = username
+	}
+	recordLoginSuccess(c, db, userID)
+	return userID, true
+}
+
+// rotateRefreshToken is used to exchange a refresh token for a new one, responding to the request
+// if the token is invalid or the account has since been disabled
+func rotateRefreshToken(c *gin.Context, authWare *jwt.GinJWTMiddleware, db *gorm.DB, token string) (*models.Session, string, bool) {
+	session, refreshToken, err := models.NewSessionManager(db).RotateRefreshToken(token)
+	if err != nil {
+		authWare.Unauthorized(c, http.StatusUnauthorized, err.Error())
+		return nil, "", false
+	}
+	enabled, err := models.NewUserManager(db).CheckIfUserAccountEnabled(session.EthAddress, db)
+	if err != nil || !enabled {
+		authWare.Unauthorized(c, http.StatusUnauthorized, "account is disabled")
+		return nil, "", false
+	}
+	return session, refreshToken, true
+}
+
+// issueSession is used to create a session for a user who has just logged in, responding with its tokens
+func issueSession(c *gin.Context, authWare *jwt.GinJWTMiddleware, keys *middleware.KeyRing, ethAddress string) {
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	resp, err := createSession(c, authWare, keys, db, ethAddress)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func respondWithSession(c *gin.Context, authWare *jwt.GinJWTMiddleware, keys *middleware.KeyRing, session *models.Session, refreshToken string) {
+	resp, err := newSessionResponse(authWare, keys, session, refreshToken)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// createSession is used to create a session for a user who has just logged in, returning its tokens
+func createSession(c *gin.Context, authWare *jwt.GinJWTMiddleware, keys *middleware.KeyRing, db *gorm.DB, ethAddress string) (*SessionResponse, error) {
+	session, refreshToken, err := models.NewSessionManager(db).NewSession(ethAddress, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		return nil, err
+	}
+	return newSessionResponse(authWare, keys, session, refreshToken)
+}
+
+func newSessionResponse(authWare *jwt.GinJWTMiddleware, keys *middleware.KeyRing, session *models.Session, refreshToken string) (*SessionResponse, error) {
+	token, expire, err := middleware.GenerateJWT(authWare, keys, session.EthAddress, session.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionResponse{
+		Token:         token,
+		Expire:        expire.Format(time.RFC3339),
+		RefreshToken:  refreshToken,
+		RefreshExpire: session.RefreshExpiresAt.Format(time.RFC3339),
+	}, nil
+}
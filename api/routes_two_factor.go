@@ -0,0 +1,100 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/RTradeLtd/Temporal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+Routes used to manage the two factor authentication of an account. Once enabled,
+logins need a code from an authenticator app, or a recovery code, as well as a password
+*/
+
+// EnrollTwoFactor is used to start enabling two factor authentication, returning the secret
+// and otpauth uri to add to an authenticator app, the latter usually being shown as a QR code
+func EnrollTwoFactor(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	secret, uri, err := models.NewUserManager(db).EnrollTwoFactor(ethAddress)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": uri,
+	})
+}
+
+// ConfirmTwoFactor is used to enable two factor authentication with a code from the enrolled authenticator app.
+// The recovery codes of the account are returned, and can't be retrieved again
+func ConfirmTwoFactor(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	code, exists := c.GetPostForm("code")
+	if !exists {
+		FailNoExistPostForm(c, "code")
+		return
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	recoveryCodes, err := models.NewUserManager(db).ConfirmTwoFactor(ethAddress, code)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":         "two factor authentication enabled",
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+// DisableTwoFactor is used to disable two factor authentication, given a code or recovery code
+func DisableTwoFactor(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	code, exists := c.GetPostForm("code")
+	if !exists {
+		FailNoExistPostForm(c, "code")
+		return
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	if err := models.NewUserManager(db).DisableTwoFactor(ethAddress, code); err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "two factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes is used to replace the recovery codes of an account, given a code or recovery code
+func RegenerateRecoveryCodes(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	code, exists := c.GetPostForm("code")
+	if !exists {
+		FailNoExistPostForm(c, "code")
+		return
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	recoveryCodes, err := models.NewUserManager(db).RegenerateRecoveryCodes(ethAddress, code)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": recoveryCodes})
+}
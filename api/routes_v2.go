@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/RTradeLtd/Temporal/api/middleware"
+	"github.com/RTradeLtd/Temporal/queue"
+	jwt "github.com/appleboy/gin-jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+Routes under /api/v2 take json bodies, which are validated before anything is done with them,
+respond with typed bodies, and report every error with the envelope of the middleware package
+*/
+
+// v2Request is a json body of a v2 route, which reports the fields that failed validation
+type v2Request interface {
+	validate() []middleware.FieldError
+}
+
+// LoginRequest is the body of v2 logins. Accounts with two factor authentication enabled
+// must also give their code with the X-Two-Factor-Code header
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (r *LoginRequest) validate() []middleware.FieldError {
+	var errs []middleware.FieldError
+	if r.Username == "" {
+		errs = append(errs, middleware.FieldError{Field: "username", Message: "is required"})
+	}
+	if r.Password == "" {
+		errs = append(errs, middleware.FieldError{Field: "password", Message: "is required"})
+	}
+	return errs
+}
+
+// RefreshSessionRequest is the body used to refresh a session
+type RefreshSessionRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (r *RefreshSessionRequest) validate() []middleware.FieldError {
+	if r.RefreshToken == "" {
+		return []middleware.FieldError{{Field: "refresh_token", Message: "is required"}}
+	}
+	return nil
+}
+
+// LoginV2 is used to log in with a password, creating a session
+func LoginV2(authWare *jwt.GinJWTMiddleware, keys *middleware.KeyRing) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req LoginRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+		db, ok := loadDatabaseV2(c)
+		if !ok {
+			return
+		}
+		userID, ok := passwordLogin(c, authWare, db, req.Username, req.Password)
+		if !ok {
+			return
+		}
+		resp, err := createSession(c, authWare, keys, db, userID)
+		if err != nil {
+			FailV2(c, http.StatusInternalServerError, "failed to create session")
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// RefreshSessionV2 is used to exchange the refresh token of a session for a new JWT, and a new refresh token
+func RefreshSessionV2(authWare *jwt.GinJWTMiddleware, keys *middleware.KeyRing) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req RefreshSessionRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+		db, ok := loadDatabaseV2(c)
+		if !ok {
+			return
+		}
+		session, refreshToken, ok := rotateRefreshToken(c, authWare, db, req.RefreshToken)
+		if !ok {
+			return
+		}
+		resp, err := newSessionResponse(authWare, keys, session, refreshToken)
+		if err != nil {
+			FailV2(c, http.StatusInternalServerError, "failed to sign token")
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// FailV2 is used to reject a request to a v2 route, with the error code matching the status
+func FailV2(c *gin.Context, status int, message string) {
+	middleware.AbortV2(c, status, middleware.ErrorCodeForStatus(status), message)
+}
+
+// bindJSON is used to decode, and validate, the json body of a v2 request.
+// Malformed bodies are rejected with a 400, and invalid fields with a 422
+func bindJSON(c *gin.Context, req v2Request) bool {
+	if !strings.HasPrefix(c.ContentType(), "application/json") {
+		FailV2(c, http.StatusBadRequest, "request body must be json")
+		return false
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(req); err != nil {
+		FailV2(c, http.StatusBadRequest, "invalid json body: "+err.Error())
+		return false
+	}
+	if errs := req.validate(); len(errs) > 0 {
+		middleware.AbortV2(c, http.StatusUnprocessableEntity, middleware.ErrorCodeValidationFailed, "request failed validation", errs...)
+		return false
+	}
+	return true
+}
+
+func loadDatabaseV2(c *gin.Context) (*gorm.DB, bool) {
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailV2(c, http.StatusInternalServerError, "failed to load database")
+	}
+	return db, ok
+}
+
+func loadPublisherV2(c *gin.Context) (queue.MessagePublisher, bool) {
+	publisher, ok := c.MustGet("mq_publisher").(queue.MessagePublisher)
+	if !ok {
+		FailV2(c, http.StatusServiceUnavailable, "queue is unavailable")
+	}
+	return publisher, ok
+}
@@ -0,0 +1,284 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/api/middleware"
+	"github.com/RTradeLtd/Temporal/models"
+	"github.com/RTradeLtd/Temporal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+/*
+v2 routes used to manage the sessions, api keys, and webhooks of the authenticated user
+*/
+
+// SessionsResponse lists the active sessions of a user
+type SessionsResponse struct {
+	Sessions       []models.Session `json:"sessions"`
+	CurrentSession string           `json:"current_session"`
+}
+
+// CreateAPIKeyRequest is the body used to create an api key. ExpiresIn is a duration, such as 720h,
+// and keys without one never expire
+type CreateAPIKeyRequest struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	ExpiresIn string   `json:"expires_in"`
+}
+
+func (r *CreateAPIKeyRequest) validate() []middleware.FieldError {
+	var errs []middleware.FieldError
+	if len(r.Scopes) == 0 {
+		errs = append(errs, middleware.FieldError{Field: "scopes", Message: "at least one scope is required"})
+	}
+	for _, v := range r.Scopes {
+		if !models.IsValidAPIKeyScope(v) {
+			errs = append(errs, middleware.FieldError{Field: "scopes", Message: "invalid api key scope " + v})
+		}
+	}
+	if r.ExpiresIn != "" {
+		if duration, err := time.ParseDuration(r.ExpiresIn); err != nil || duration <= 0 {
+			errs = append(errs, middleware.FieldError{Field: "expires_in", Message: "must be a positive duration, such as 720h"})
+		}
+	}
+	return errs
+}
+
+// APIKeyResponse holds a newly created api key, along with the only copy of its token
+type APIKeyResponse struct {
+	APIKey *models.APIKey `json:"api_key"`
+	Key    string         `json:"key"`
+}
+
+// APIKeysResponse lists the api keys of a user
+type APIKeysResponse struct {
+	APIKeys []models.APIKey `json:"api_keys"`
+}
+
+// CreateWebhookRequest is the body used to register a webhook. If no secret is given, one is generated
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+func (r *CreateWebhookRequest) validate() []middleware.FieldError {
+	var errs []middleware.FieldError
+	if parsed, err := url.Parse(r.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		errs = append(errs, middleware.FieldError{Field: "url", Message: "must be an http or https url"})
+	} else if err = utils.ValidatePublicURL(r.URL); err != nil {
+		// webhooks are delivered by our workers, so they must not be able to reach internal services
+		errs = append(errs, middleware.FieldError{Field: "url", Message: err.Error()})
+	}
+	if len(r.Events) == 0 {
+		errs = append(errs, middleware.FieldError{Field: "events", Message: "at least one event is required"})
+	}
+	for _, v := range r.Events {
+		if !models.IsValidWebhookEvent(v) {
+			errs = append(errs, middleware.FieldError{Field: "events", Message: "invalid webhook event " + v})
+		}
+	}
+	return errs
+}
+
+// WebhookResponse holds a newly registered webhook, along with the only copy of its secret
+type WebhookResponse struct {
+	Webhook *models.Webhook `json:"webhook"`
+	Secret  string          `json:"secret"`
+}
+
+// WebhooksResponse lists the webhooks of a user
+type WebhooksResponse struct {
+	Webhooks []models.Webhook `json:"webhooks"`
+}
+
+// WebhookDeliveriesResponse lists the delivery log of a webhook
+type WebhookDeliveriesResponse struct {
+	Deliveries []models.WebhookDelivery `json:"deliveries"`
+}
+
+// GetSessionsV2 is used to list the active sessions of the authenticated user
+func GetSessionsV2(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	db, ok := loadDatabaseV2(c)
+	if !ok {
+		return
+	}
+	sessions, err := models.NewSessionManager(db).FindActiveSessionsByUser(ethAddress)
+	if err != nil {
+		FailV2(c, http.StatusInternalServerError, "failed to find sessions")
+		return
+	}
+	c.JSON(http.StatusOK, SessionsResponse{Sessions: sessions, CurrentSession: c.GetString("session_id")})
+}
+
+// CreateAPIKeyV2 is used to create an api key for the authenticated user
+func CreateAPIKeyV2(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	var req CreateAPIKeyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	var expiresAt *time.Time
+	if req.ExpiresIn != "" {
+		// validated by the request
+		duration, _ := time.ParseDuration(req.ExpiresIn)
+		expiry := time.Now().Add(duration)
+		expiresAt = &expiry
+	}
+	db, ok := loadDatabaseV2(c)
+	if !ok {
+		return
+	}
+	key, token, err := models.NewAPIKeyManager(db).NewAPIKey(ethAddress, req.Name, req.Scopes, expiresAt)
+	if err != nil {
+		FailV2(c, http.StatusInternalServerError, "failed to create api key")
+		return
+	}
+	c.JSON(http.StatusCreated, APIKeyResponse{APIKey: key, Key: token})
+}
+
+// GetAPIKeysV2 is used to list the api keys of the authenticated user
+func GetAPIKeysV2(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	db, ok := loadDatabaseV2(c)
+	if !ok {
+		return
+	}
+	keys, err := models.NewAPIKeyManager(db).FindAPIKeysByUser(ethAddress)
+	if err != nil {
+		FailV2(c, http.StatusInternalServerError, "failed to find api keys")
+		return
+	}
+	c.JSON(http.StatusOK, APIKeysResponse{APIKeys: keys})
+}
+
+// RevokeAPIKeyV2 is used to revoke an api key belonging to the authenticated user
+func RevokeAPIKeyV2(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		FailV2(c, http.StatusNotFound, "api key not found")
+		return
+	}
+	db, ok := loadDatabaseV2(c)
+	if !ok {
+		return
+	}
+	akm := models.NewAPIKeyManager(db)
+	key, err := akm.FindAPIKeyByID(ethAddress, uint(id))
+	if err != nil {
+		FailV2(c, http.StatusNotFound, "api key not found")
+		return
+	}
+	if key.Revoked {
+		FailV2(c, http.StatusConflict, "api key has already been revoked")
+		return
+	}
+	if err = akm.RevokeAPIKey(ethAddress, key.ID); err != nil {
+		FailV2(c, http.StatusInternalServerError, "failed to revoke api key")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// CreateWebhookV2 is used to register a webhook for the authenticated user
+func CreateWebhookV2(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	var req CreateWebhookRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	db, ok := loadDatabaseV2(c)
+	if !ok {
+		return
+	}
+	wm := models.NewWebhookManager(db)
+	hooks, err := wm.FindWebhooksByUser(ethAddress)
+	if err != nil {
+		FailV2(c, http.StatusInternalServerError, "failed to find webhooks")
+		return
+	}
+	for _, v := range hooks {
+		if v.URL == req.URL {
+			FailV2(c, http.StatusConflict, "a webhook is already registered for this url")
+			return
+		}
+	}
+	secret := req.Secret
+	if secret == "" {
+		if secret, err = utils.GenerateSecureToken(32); err != nil {
+			FailV2(c, http.StatusInternalServerError, "failed to generate webhook secret")
+			return
+		}
+	}
+	hook, err := wm.NewWebhook(ethAddress, req.URL, secret, req.Events)
+	if err != nil {
+		FailV2(c, http.StatusInternalServerError, "failed to register webhook")
+		return
+	}
+	c.JSON(http.StatusCreated, WebhookResponse{Webhook: hook, Secret: secret})
+}
+
+// GetWebhooksV2 is used to list the webhooks of the authenticated user
+func GetWebhooksV2(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	db, ok := loadDatabaseV2(c)
+	if !ok {
+		return
+	}
+	hooks, err := models.NewWebhookManager(db).FindWebhooksByUser(ethAddress)
+	if err != nil {
+		FailV2(c, http.StatusInternalServerError, "failed to find webhooks")
+		return
+	}
+	c.JSON(http.StatusOK, WebhooksResponse{Webhooks: hooks})
+}
+
+// DeleteWebhookV2 is used to remove a webhook belonging to the authenticated user
+func DeleteWebhookV2(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		FailV2(c, http.StatusNotFound, "webhook not found")
+		return
+	}
+	db, ok := loadDatabaseV2(c)
+	if !ok {
+		return
+	}
+	if err = models.NewWebhookManager(db).DeleteWebhook(ethAddress, uint(id)); err != nil {
+		FailV2(c, http.StatusNotFound, "webhook not found")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetWebhookDeliveriesV2 is used to retrieve the delivery log of a webhook belonging to the authenticated user
+func GetWebhookDeliveriesV2(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		FailV2(c, http.StatusNotFound, "webhook not found")
+		return
+	}
+	db, ok := loadDatabaseV2(c)
+	if !ok {
+		return
+	}
+	wm := models.NewWebhookManager(db)
+	if _, err = wm.FindWebhookByID(ethAddress, uint(id)); err != nil {
+		FailV2(c, http.StatusNotFound, "webhook not found")
+		return
+	}
+	deliveries, err := wm.FindDeliveriesByWebhook(ethAddress, uint(id))
+	if err != nil {
+		FailV2(c, http.StatusInternalServerError, "failed to find webhook deliveries")
+		return
+	}
+	c.JSON(http.StatusOK, WebhookDeliveriesResponse{Deliveries: deliveries})
+}
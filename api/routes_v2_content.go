@@ -0,0 +1,232 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/api/middleware"
+	"github.com/RTradeLtd/Temporal/models"
+	"github.com/RTradeLtd/Temporal/queue"
+	"github.com/gin-gonic/gin"
+	gocid "github.com/ipfs/go-cid"
+)
+
+/*
+v2 routes used to pin content, publish ipns records, and list uploads. Operations sent to the
+queue respond with a 202, and the id of the job tracking them under /api/v2/jobs
+*/
+
+// PinRequest is the body used to pin content to the public network
+type PinRequest struct {
+	Hash           string `json:"hash"`
+	HoldTimeMonths int64  `json:"hold_time_months"`
+}
+
+func (r *PinRequest) validate() []middleware.FieldError {
+	var errs []middleware.FieldError
+	if _, err := gocid.Decode(r.Hash); err != nil {
+		errs = append(errs, middleware.FieldError{Field: "hash", Message: "must be a valid cid"})
+	}
+	if r.HoldTimeMonths <= 0 {
+		errs = append(errs, middleware.FieldError{Field: "hold_time_months", Message: "must be at least 1"})
+	}
+	return errs
+}
+
+// IPNSRecordRequest is the body used to publish an ipns record, with a key owned by the user.
+// LifeTime and TTL are durations, such as 24h
+type IPNSRecordRequest struct {
+	Hash     string `json:"hash"`
+	LifeTime string `json:"life_time"`
+	TTL      string `json:"ttl"`
+	Key      string `json:"key"`
+	Resolve  bool   `json:"resolve"`
+}
+
+func (r *IPNSRecordRequest) validate() []middleware.FieldError {
+	var errs []middleware.FieldError
+	if _, err := gocid.Decode(r.Hash); err != nil {
+		errs = append(errs, middleware.FieldError{Field: "hash", Message: "must be a valid cid"})
+	}
+	if duration, err := time.ParseDuration(r.LifeTime); err != nil || duration <= 0 {
+		errs = append(errs, middleware.FieldError{Field: "life_time", Message: "must be a positive duration, such as 24h"})
+	}
+	if duration, err := time.ParseDuration(r.TTL); err != nil || duration <= 0 {
+		errs = append(errs, middleware.FieldError{Field: "ttl", Message: "must be a positive duration, such as 1h"})
+	}
+	if r.Key == "" {
+		errs = append(errs, middleware.FieldError{Field: "key", Message: "is required"})
+	}
+	return errs
+}
+
+// JobAcceptedResponse is returned by routes which send an operation to the queue
+type JobAcceptedResponse struct {
+	JobID  string `json:"job_id"`
+	Status string `json:"status"`
+}
+
+// UploadsResponse lists the uploads owned by a user
+type UploadsResponse struct {
+	Uploads []models.UploadOwner `json:"uploads"`
+}
+
+// PinV2 is used to pin content to the public network for the authenticated user
+func PinV2(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	var req PinRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	db, ok := loadDatabaseV2(c)
+	if !ok {
+		return
+	}
+	publisher, ok := loadPublisherV2(c)
+	if !ok {
+		return
+	}
+	jm := models.NewJobManager(db)
+	job, err := jm.NewJob(ethAddress, models.JobTypeIPFSPin)
+	if err != nil {
+		FailV2(c, http.StatusInternalServerError, "failed to create job")
+		return
+	}
+	ip := queue.IPFSPin{
+		CID:              req.Hash,
+		NetworkName:      "public",
+		EthAddress:       ethAddress,
+		HoldTimeInMonths: req.HoldTimeMonths,
+		JobID:            job.JobID,
+	}
+	if err = publisher.PublishMessageWithExchange(ip, queue.PinExchange); err != nil {
+		failQueuedJob(c, jm, job.JobID, err)
+		return
+	}
+	dpa := queue.DatabasePinAdd{
+		Hash:             req.Hash,
+		UploaderAddress:  ethAddress,
+		HoldTimeInMonths: req.HoldTimeMonths,
+		NetworkName:      "public",
+	}
+	if err = publisher.PublishMessage(queue.DatabasePinAddQueue, dpa); err != nil {
+		failQueuedJob(c, jm, job.JobID, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, JobAcceptedResponse{JobID: job.JobID, Status: job.State})
+}
+
+// UnpinV2 is used to release the authenticated user's pin of content on the public network. The job
+// result is released when other users still hold the content, or unpinned once it has been unpinned
+func UnpinV2(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	hash := c.Param("hash")
+	if _, err := gocid.Decode(hash); err != nil {
+		middleware.AbortV2(c, http.StatusUnprocessableEntity, middleware.ErrorCodeValidationFailed, "request failed validation",
+			middleware.FieldError{Field: "hash", Message: "must be a valid cid"})
+		return
+	}
+	db, ok := loadDatabaseV2(c)
+	if !ok {
+		return
+	}
+	publisher, ok := loadPublisherV2(c)
+	if !ok {
+		return
+	}
+	job, err := queuePinRemoval(db, publisher, ethAddress, hash, "public")
+	switch {
+	case err == errPinNotOwned:
+		FailV2(c, http.StatusForbidden, err.Error())
+		return
+	case err != nil:
+		FailV2(c, http.StatusServiceUnavailable, "failed to send to the queue, please try again")
+		return
+	}
+	c.JSON(http.StatusAccepted, JobAcceptedResponse{JobID: job.JobID, Status: job.State})
+}
+
+// PublishIPNSRecordV2 is used to publish an ipns record to the public network for the authenticated user
+func PublishIPNSRecordV2(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	var req IPNSRecordRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	db, ok := loadDatabaseV2(c)
+	if !ok {
+		return
+	}
+	ownsKey, err := models.NewUserManager(db).CheckIfKeyOwnedByUser(ethAddress, req.Key)
+	if err != nil {
+		FailV2(c, http.StatusInternalServerError, "failed to check key ownership")
+		return
+	}
+	if !ownsKey {
+		FailV2(c, http.StatusForbidden, "key is not owned by this account")
+		return
+	}
+	publisher, ok := loadPublisherV2(c)
+	if !ok {
+		return
+	}
+	jm := models.NewJobManager(db)
+	job, err := jm.NewJob(ethAddress, models.JobTypeIPNSEntry)
+	if err != nil {
+		FailV2(c, http.StatusInternalServerError, "failed to create job")
+		return
+	}
+	// validated by the request
+	lifetime, _ := time.ParseDuration(req.LifeTime)
+	ttl, _ := time.ParseDuration(req.TTL)
+	ie := queue.IPNSEntry{
+		CID:         req.Hash,
+		LifeTime:    lifetime,
+		TTL:         ttl,
+		Resolve:     req.Resolve,
+		Key:         req.Key,
+		EthAddress:  ethAddress,
+		NetworkName: "public",
+		JobID:       job.JobID,
+	}
+	if err = publisher.PublishMessage(queue.IpnsEntryQueue, ie); err != nil {
+		failQueuedJob(c, jm, job.JobID, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, JobAcceptedResponse{JobID: job.JobID, Status: job.State})
+}
+
+// GetUploadsV2 is used to list the uploads owned by the authenticated user
+func GetUploadsV2(c *gin.Context) {
+	listUploadsV2(c, GetAuthenticatedUserFromContext(c))
+}
+
+// GetUploadsForAddressV2 is used to list the uploads owned by any address, needing the view-uploads permission
+func GetUploadsForAddressV2(c *gin.Context) {
+	if !CheckPermissionForAuthUser(c, models.PermissionViewUploads) {
+		FailV2(c, http.StatusForbidden, "the view-uploads permission is required")
+		return
+	}
+	listUploadsV2(c, c.Param("address"))
+}
+
+func listUploadsV2(c *gin.Context, ethAddress string) {
+	db, ok := loadDatabaseV2(c)
+	if !ok {
+		return
+	}
+	resp := UploadsResponse{Uploads: []models.UploadOwner{}}
+	if uploads := models.NewUploadManager(db).GetUploadsForAddress(ethAddress); uploads != nil {
+		resp.Uploads = *uploads
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// failQueuedJob is used when an operation couldn't be sent to the queue, failing its job
+func failQueuedJob(c *gin.Context, jm *models.JobManager, jobID string, err error) {
+	if err = jm.UpdateJobState(jobID, models.JobStateFailed, err.Error()); err != nil {
+		fmt.Println("error failing job ", err)
+	}
+	FailV2(c, http.StatusServiceUnavailable, "failed to send to the queue, please try again")
+}
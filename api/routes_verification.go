@@ -0,0 +1,149 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/models"
+	"github.com/RTradeLtd/Temporal/queue"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+Routes used to verify the email address of a user, and to reset forgotten passwords.
+Tokens are emailed to the user through the email send queue, and can only be used once
+*/
+
+// VerifyEmailAddress is used to verify the email address of a user, enabling their account
+func VerifyEmailAddress(c *gin.Context) {
+	token, exists := c.GetPostForm("token")
+	if !exists {
+		FailNoExistPostForm(c, "token")
+		return
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	userToken, err := models.NewUserTokenManager(db).UseUserToken(token, models.TokenPurposeEmailVerification)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	if err = models.NewUserManager(db).VerifyEmailAddress(userToken.EthAddress); err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "email address verified"})
+}
+
+// ResendEmailVerification is used to send a new verification token to a user who has not yet verified their email address
+func ResendEmailVerification(c *gin.Context) {
+	ethAddress, exists := c.GetPostForm("eth_address")
+	if !exists {
+		FailNoExistPostForm(c, "eth_address")
+		return
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	// accounts disabled on purpose aren't awaiting verification, so they can't be enabled by verifying them
+	user := models.NewUserManager(db).FindByAddress(ethAddress)
+	if user == nil || user.EmailEnabled || !user.AwaitingVerification {
+		FailOnError(c, errors.New("no unverified account found for address"))
+		return
+	}
+	if err := sendEmailVerification(c, db, ethAddress); err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "verification email sent"})
+}
+
+// RequestPasswordReset is used to email a password reset token to a user.
+// The response is the same whether or not the account exists, so accounts can't be discovered with it
+func RequestPasswordReset(c *gin.Context) {
+	ethAddress, exists := c.GetPostForm("eth_address")
+	if !exists {
+		FailNoExistPostForm(c, "eth_address")
+		return
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	// only verified email addresses are trusted to receive reset tokens
+	user := models.NewUserManager(db).FindByAddress(ethAddress)
+	if user != nil && user.EmailEnabled {
+		if err := sendUserTokenEmail(c, db, ethAddress, models.TokenPurposePasswordReset, models.PasswordResetTimeout,
+			queue.PasswordResetSubject, queue.PasswordResetContent); err != nil {
+			fmt.Println("error sending password reset email ", err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "if the account exists, a password reset email has been sent"})
+}
+
+// ConfirmPasswordReset is used to set a new password for a user, given the token they were emailed
+func ConfirmPasswordReset(c *gin.Context) {
+	token, exists := c.GetPostForm("token")
+	if !exists {
+		FailNoExistPostForm(c, "token")
+		return
+	}
+	newPassword, exists := c.GetPostForm("new_password")
+	if !exists {
+		FailNoExistPostForm(c, "new_password")
+		return
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	userToken, err := models.NewUserTokenManager(db).UseUserToken(token, models.TokenPurposePasswordReset)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	if err = models.NewUserManager(db).ResetPassword(userToken.EthAddress, newPassword); err != nil {
+		FailOnError(c, err)
+		return
+	}
+	// whoever knew the old password is logged out
+	if err = models.NewSessionManager(db).RevokeAllSessions(userToken.EthAddress, ""); err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "password reset"})
+}
+
+// sendEmailVerification is used to email a verification token to a newly registered user
+func sendEmailVerification(c *gin.Context, db *gorm.DB, ethAddress string) error {
+	return sendUserTokenEmail(c, db, ethAddress, models.TokenPurposeEmailVerification, models.EmailVerificationTimeout,
+		queue.EmailVerificationSubject, queue.EmailVerificationContent)
+}
+
+func sendUserTokenEmail(c *gin.Context, db *gorm.DB, ethAddress, purpose string, timeout time.Duration, subject, content string) error {
+	publisher, ok := c.MustGet("mq_publisher").(queue.MessagePublisher)
+	if !ok {
+		return errors.New("failed to load rabbitmq middleware")
+	}
+	token, err := models.NewUserTokenManager(db).NewUserToken(ethAddress, purpose, timeout)
+	if err != nil {
+		return err
+	}
+	es := queue.EmailSend{
+		Subject:      subject,
+		Content:      fmt.Sprintf(content, token, time.Now().Add(timeout).UTC().Format(time.RFC1123)),
+		ContentType:  "",
+		EthAddresses: []string{ethAddress},
+	}
+	return publisher.PublishMessage(queue.EmailSendQueue, es)
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/RTradeLtd/Temporal/api/middleware"
+	"github.com/RTradeLtd/Temporal/models"
+	"github.com/RTradeLtd/Temporal/utils"
+	jwt "github.com/appleboy/gin-jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+Routes used to log in by signing a challenge with an ethereum wallet, instead of using a password.
+The message of the challenge is signed with personal_sign (EIP-191), and the signing address recovered
+*/
+
+// CreateLoginChallenge is used to create a challenge for the user to sign with their wallet
+func CreateLoginChallenge(c *gin.Context) {
+	ethAddress, exists := c.GetPostForm("eth_address")
+	if !exists {
+		FailNoExistPostForm(c, "eth_address")
+		return
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	lm := models.NewLoginChallengeManager(db)
+	if err := lm.RemoveExpiredLoginChallenges(); err != nil {
+		fmt.Println("error removing expired login challenges ", err)
+	}
+	challenge, err := lm.NewLoginChallenge(ethAddress)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"nonce":      challenge.Nonce,
+		"message":    challenge.Message,
+		"expires_at": challenge.ExpiresAt,
+	})
+}
+
+// WalletLoginHandler is used to create a session for a user who has signed their login challenge
+func WalletLoginHandler(authWare *jwt.GinJWTMiddleware, keys *middleware.KeyRing) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db, ok := c.MustGet("db").(*gorm.DB)
+		if !ok {
+			FailedToLoadDatabase(c)
+			return
+		}
+		if !reserveLoginAttempt(c, db, c.PostForm("eth_address")) {
+			return
+		}
+		ethAddress, ok := verifyLoginChallenge(c)
+		if !ok {
+			recordLoginFailure(c, db, c.PostForm("eth_address"))
+			return
+		}
+		user := models.NewUserManager(db).FindByWalletAddress(ethAddress)
+		if user == nil {
+			FailNotAuthorized(c, "user account does not exist")
+			return
+		}
+		if !user.AccountEnabled {
+			FailNotAuthorized(c, "account is marked as disabled")
+			return
+		}
+		// wallet logins need the same second factor as password logins
+		validCode, err := models.NewUserManager(db).ValidateTwoFactor(user.EthAddress, c.GetHeader(middleware.TwoFactorHeader))
+		if err != nil {
+			recordLoginFailure(c, db, user.EthAddress)
+			FailNotAuthorized(c, err.Error())
+			return
+		}
+		if !validCode {
+			recordLoginFailure(c, db, user.EthAddress)
+			FailNotAuthorized(c, models.ErrInvalidTwoFactorCode.Error())
+			return
+		}
+		recordLoginSuccess(c, db, user.EthAddress)
+		issueSession(c, authWare, keys, user.EthAddress)
+	}
+}
+
+// RegisterUserAccountWithWallet is used to register a user account which logs in with its wallet, and has no password
+func RegisterUserAccountWithWallet(c *gin.Context) {
+	email, exists := c.GetPostForm("email_address")
+	if !exists {
+		FailNoExistPostForm(c, "email_address")
+		return
+	}
+	ethAddress, ok := verifyLoginChallenge(c)
+	if !ok {
+		return
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	userModel, err := models.NewUserManager(db).NewWalletUserAccount(ethAddress, email)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	// the account is enabled once the email address is verified
+	if err = sendEmailVerification(c, db, ethAddress); err != nil {
+		fmt.Println("error sending verification email ", err)
+	}
+	c.JSON(http.StatusCreated, gin.H{"user": userModel})
+}
+
+// verifyLoginChallenge is used to check that the challenge in the request was signed by the address it was issued to
+func verifyLoginChallenge(c *gin.Context) (string, bool) {
+	ethAddress, exists := c.GetPostForm("eth_address")
+	if !exists {
+		FailNoExistPostForm(c, "eth_address")
+		return "", false
+	}
+	nonce, exists := c.GetPostForm("nonce")
+	if !exists {
+		FailNoExistPostForm(c, "nonce")
+		return "", false
+	}
+	signature, exists := c.GetPostForm("signature")
+	if !exists {
+		FailNoExistPostForm(c, "signature")
+		return "", false
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return "", false
+	}
+	challenge, err := models.NewLoginChallengeManager(db).UseLoginChallenge(ethAddress, nonce)
+	if err != nil {
+		FailNotAuthorized(c, err.Error())
+		return "", false
+	}
+	signer, err := utils.RecoverPersonalSignAddress(challenge.Message, signature)
+	if err != nil {
+		FailNotAuthorized(c, "invalid signature")
+		return "", false
+	}
+	// addresses may be given with or without their checksum casing, so the checksummed address of the signer is used
+	if !strings.EqualFold(signer.Hex(), ethAddress) {
+		FailNotAuthorized(c, "signature was not made by "+ethAddress)
+		return "", false
+	}
+	return signer.Hex(), true
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/RTradeLtd/Temporal/models"
+	"github.com/RTradeLtd/Temporal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+/*
+Routes used to manage webhooks, which receive HMAC signed event notifications from the queue
+*/
+
+// CreateWebhook is used to register a webhook for the authenticated user. If no secret
+// is provided, one will be generated. The secret is only ever returned by this call
+func CreateWebhook(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	hookURL, exists := c.GetPostForm("url")
+	if !exists {
+		FailNoExistPostForm(c, "url")
+		return
+	}
+	events, exists := c.GetPostFormArray("events")
+	if !exists {
+		FailNoExistPostForm(c, "events")
+		return
+	}
+	// webhooks are delivered by our workers, so they must not be able to reach internal services
+	if err := utils.ValidatePublicURL(hookURL); err != nil {
+		FailOnError(c, err)
+		return
+	}
+	for _, v := range events {
+		if !models.IsValidWebhookEvent(v) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":        "invalid webhook event " + v,
+				"valid_events": models.WebhookEvents,
+			})
+			return
+		}
+	}
+	var err error
+	secret := c.PostForm("secret")
+	if secret == "" {
+		secret, err = utils.GenerateSecureToken(32)
+		if err != nil {
+			FailOnError(c, err)
+			return
+		}
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	wm := models.NewWebhookManager(db)
+	hook, err := wm.NewWebhook(ethAddress, hookURL, secret, events)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"webhook": hook,
+		"secret":  secret,
+	})
+}
+
+// GetWebhooksForAuthUser is used to list the webhooks of the authenticated user
+func GetWebhooksForAuthUser(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	wm := models.NewWebhookManager(db)
+	hooks, err := wm.FindWebhooksByUser(ethAddress)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"webhooks": hooks})
+}
+
+// DeleteWebhook is used to remove a webhook belonging to the authenticated user
+func DeleteWebhook(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	wm := models.NewWebhookManager(db)
+	if err = wm.DeleteWebhook(ethAddress, uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "webhook not found",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "webhook removed"})
+}
+
+// GetWebhookDeliveries is used to retrieve the delivery log of a webhook belonging to the authenticated user
+func GetWebhookDeliveries(c *gin.Context) {
+	ethAddress := GetAuthenticatedUserFromContext(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	db, ok := c.MustGet("db").(*gorm.DB)
+	if !ok {
+		FailedToLoadDatabase(c)
+		return
+	}
+	wm := models.NewWebhookManager(db)
+	if _, err = wm.FindWebhookByID(ethAddress, uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "webhook not found",
+		})
+		return
+	}
+	deliveries, err := wm.FindDeliveriesByWebhook(ethAddress, uint(id))
+	if err != nil {
+		FailOnError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
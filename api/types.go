@@ -0,0 +1,89 @@
+package api
+
+import (
+	"github.com/RTradeLtd/Temporal/models"
+	"github.com/mitchellh/goamz/route53"
+)
+
+/*
+Responses of the v1 routes covered by the client package, so that the client decodes exactly what
+the handlers send. The json keys match what these routes have always returned
+*/
+
+// FileAddResponse is returned by simple file uploads, holding the hash of the added file
+type FileAddResponse struct {
+	Response string `json:"response"`
+}
+
+// FileAddJobResponse is returned by advanced file uploads, which are added to ipfs by the queue
+type FileAddJobResponse struct {
+	EthAddress string `json:"eth_address"`
+	HoldTime   int64  `json:"hold_time"`
+	JobID      string `json:"job_id"`
+}
+
+// DirectoryFileResponse is a file added as part of a directory, with its path relative to the directory
+type DirectoryFileResponse struct {
+	Path string `json:"path"`
+	Hash string `json:"hash"`
+	Size int64  `json:"size"`
+}
+
+// DirectoryAddResponse is returned by directory uploads, holding the hash of the directory and of each file within it
+type DirectoryAddResponse struct {
+	Hash  string                  `json:"hash"`
+	Files []DirectoryFileResponse `json:"files"`
+}
+
+// PrivateFileAddResponse is returned by file uploads to private networks, holding the hash of the added file
+type PrivateFileAddResponse struct {
+	Status string `json:"status"`
+}
+
+// StatusJobResponse is returned by routes which send an operation to the queue, tracked by a job
+type StatusJobResponse struct {
+	Status string `json:"status"`
+	JobID  string `json:"job_id"`
+}
+
+// CostResponse is returned by cost calculations
+type CostResponse struct {
+	TotalCostUSD float64 `json:"total_cost_usd"`
+}
+
+// DNSLinkResponse is returned when a dnslink entry is added to route53
+type DNSLinkResponse struct {
+	RecordName  string                                    `json:"record_name"`
+	RecordValue string                                    `json:"record_value"`
+	ZoneName    string                                    `json:"zone_name"`
+	Manager     string                                    `json:"manager"`
+	Region      string                                    `json:"region"`
+	Resp        *route53.ChangeResourceRecordSetsResponse `json:"resp"`
+}
+
+// IPFSKeyResponse is returned when an ipfs key is created, holding the peer id of the key
+type IPFSKeyResponse struct {
+	Status string `json:"status"`
+	ID     string `json:"id"`
+}
+
+// IPFSKeysResponse lists the names of the ipfs keys of a user, along with their peer ids
+type IPFSKeysResponse struct {
+	KeyNames []string `json:"key_names"`
+	KeyIDs   []string `json:"key_ids"`
+}
+
+// PrivateNetworkResponse holds a private network
+type PrivateNetworkResponse struct {
+	Network *models.HostedIPFSPrivateNetwork `json:"network"`
+}
+
+// PrivateNetworksResponse lists the names of the private networks a user has access to
+type PrivateNetworksResponse struct {
+	Networks []string `json:"networks"`
+}
+
+// PrivateNetworkUploadsResponse lists the uploads to a private network
+type PrivateNetworkUploadsResponse struct {
+	Uploads *[]models.Upload `json:"uploads"`
+}
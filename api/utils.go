@@ -4,7 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"path"
+	"strings"
+	"unicode/utf8"
 
+	"github.com/RTradeLtd/Temporal/api/middleware"
 	"github.com/RTradeLtd/Temporal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
@@ -12,6 +16,8 @@ import (
 
 const FilesUploadBucket = "filesuploadbucket"
 
+var errDatabaseNotLoaded = errors.New("failed to load database")
+
 func FailNoExist(c *gin.Context, message string) {
 	c.JSON(http.StatusBadRequest, gin.H{
 		"error": message,
@@ -47,7 +53,12 @@ func FailedToLoadMiddleware(c *gin.Context, middlewareName string) {
 	})
 }
 
-func CheckAccessForPrivateNetwork(ethAddress, networkName string, db *gorm.DB) error {
+// CheckAccessForPrivateNetwork is used to check whether or not the user may use the private network.
+// Requests made with an api key also need the key to have been granted the scope of the network
+func CheckAccessForPrivateNetwork(c *gin.Context, ethAddress, networkName string, db *gorm.DB) error {
+	if key, ok := middleware.APIKeyFromContext(c); ok && !key.HasScope(models.PrivateNetworkScope(networkName)) {
+		return fmt.Errorf("api key is missing the %s scope", models.PrivateNetworkScope(networkName))
+	}
 	um := models.NewUserManager(db)
 	canUpload, err := um.CheckIfUserHasAccessToNetwork(ethAddress, networkName)
 	if err != nil {
@@ -59,3 +70,17 @@ func CheckAccessForPrivateNetwork(ethAddress, networkName string, db *gorm.DB) e
 	}
 	return nil
 }
+
+// uploadFileName is used to clean the name a file was uploaded with, which some clients give as a path.
+// Names are kept to 255 bytes, dropping the start of the name so that the extension is kept
+func uploadFileName(name string) string {
+	name = path.Base(strings.Replace(name, "\\", "/", -1))
+	if name == "." || name == "/" {
+		return ""
+	}
+	for len(name) > 255 {
+		_, size := utf8.DecodeRuneInString(name)
+		name = name[size:]
+	}
+	return name
+}
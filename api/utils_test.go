@@ -0,0 +1,39 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/RTradeLtd/Temporal/api"
+	"github.com/RTradeLtd/Temporal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	jwtgo "gopkg.in/dgrijalva/jwt-go.v3"
+)
+
+func TestAPIKeyPrivateNetworkScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	// mimic a request authenticated with a key only granted the pin scope
+	router.Use(func(c *gin.Context) {
+		c.Set("JWT_PAYLOAD", jwtgo.MapClaims{"id": "0xabc"})
+		c.Set("api_key", &models.APIKey{EthAddress: "0xabc", Scopes: []string{models.APIKeyScopePin}})
+		c.Set("db", (*gorm.DB)(nil))
+	})
+	router.POST("/api/v1/ipfs/pin/:hash", api.PinHashLocally)
+
+	form := url.Values{"use_private_network": {"true"}, "network_name": {"myNetwork"}, "hold_time": {"5"}}
+	req := httptest.NewRequest("POST", "/api/v1/ipfs/pin/QmHash", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusForbidden, recorder.Code, recorder.Body.String())
+	}
+	if !strings.Contains(recorder.Body.String(), models.PrivateNetworkScope("myNetwork")) {
+		t.Fatalf("expected the missing scope in the error, got %s", recorder.Body.String())
+	}
+}
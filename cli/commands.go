@@ -0,0 +1,562 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/api"
+	"github.com/RTradeLtd/Temporal/client"
+	"github.com/RTradeLtd/Temporal/models"
+	"github.com/RTradeLtd/Temporal/queue"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// session holds what every command needs: a client for the api, the stored credentials, and the printer
+type session struct {
+	client    *client.Client
+	creds     *credentials
+	credsPath string
+	printer   *printer
+}
+
+// newSession is used to load the stored credentials, and create a client for the api they are for
+func newSession(c *cli.Context) (*session, error) {
+	credsPath := c.GlobalString("credentials")
+	creds, err := loadCredentials(credsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load credentials from %s: %s", credsPath, err)
+	}
+	// the api the credentials were stored for is used, unless another is asked for
+	apiURL := c.GlobalString("api")
+	if !c.GlobalIsSet("api") && creds.API != "" {
+		apiURL = creds.API
+	}
+	s := &session{
+		client:    client.New(apiURL),
+		creds:     creds,
+		credsPath: credsPath,
+		printer:   &printer{json: c.GlobalBool("json"), out: os.Stdout},
+	}
+	if creds.API == apiURL {
+		s.client.APIKey = creds.APIKey
+		if creds.Session != nil {
+			if err = s.client.SetSession(creds.Session); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// save is used to store the credentials, keeping the session the client refreshed during the command
+func (s *session) save() error {
+	current := s.client.Session()
+	if current == nil || s.client.APIKey != "" || (s.creds.Session != nil && s.creds.Session.Token == current.Token) {
+		return nil
+	}
+	s.creds.API = s.client.URL
+	s.creds.Session = current
+	return saveCredentials(s.credsPath, s.creds)
+}
+
+// withClient is used to run a command with a session, storing the session afterwards
+func withClient(fn func(s *session, c *cli.Context) error) func(*cli.Context) error {
+	return func(c *cli.Context) error {
+		s, err := newSession(c)
+		if err != nil {
+			return err
+		}
+		err = fn(s, c)
+		if saveErr := s.save(); saveErr != nil && err == nil {
+			err = saveErr
+		}
+		return err
+	}
+}
+
+func login(c *cli.Context) error {
+	s, err := newSession(c)
+	if err != nil {
+		return err
+	}
+	creds := &credentials{API: s.client.URL}
+	if key := c.String("api-key"); key != "" {
+		creds.APIKey = key
+	} else {
+		username := c.Args().First()
+		if username == "" {
+			return errors.New("usage: temporal-cli login <eth address>")
+		}
+		password, err := readPassword("password: ")
+		if err != nil {
+			return err
+		}
+		if creds.Session, err = s.client.Login(username, password, c.String("two-factor")); err != nil {
+			return err
+		}
+	}
+	if err = saveCredentials(s.credsPath, creds); err != nil {
+		return err
+	}
+	return s.printer.result(map[string]string{"status": "logged in", "api": creds.API}, func(w io.Writer) {
+		fmt.Fprintf(w, "logged in to %s, credentials stored in %s\n", creds.API, s.credsPath)
+	})
+}
+
+func logout(s *session, c *cli.Context) error {
+	if s.client.Session() != nil {
+		if err := s.client.Logout(); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(s.credsPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.creds = &credentials{}
+	return s.printer.result(map[string]string{"status": "logged out"}, func(w io.Writer) {
+		fmt.Fprintln(w, "logged out")
+	})
+}
+
+// uploadResult is the outcome of uploading one file
+type uploadResult struct {
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	Hash  string `json:"hash,omitempty"`
+	JobID string `json:"job_id,omitempty"`
+}
+
+func upload(s *session, c *cli.Context) error {
+	root := c.Args().First()
+	if root == "" {
+		return errors.New("usage: temporal-cli upload <path>")
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	if (c.Bool("wrap") || c.Bool("extract")) && c.Bool("advanced") {
+		return errors.New("--advanced can't be used with --wrap or --extract")
+	}
+	switch {
+	case c.Bool("extract"):
+		if info.IsDir() {
+			return errors.New("--extract needs an archive, not a directory")
+		}
+		return uploadArchive(s, c, root)
+	case c.Bool("wrap"):
+		return uploadDirectory(s, c, root, info)
+	}
+	var paths []string
+	if info.IsDir() {
+		err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.Mode().IsRegular() {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		paths = []string{root}
+	}
+	var results []uploadResult
+	for _, path := range paths {
+		result, err := uploadFile(s, c, path)
+		if err != nil {
+			return fmt.Errorf("failed to upload %s: %s", path, err)
+		}
+		results = append(results, *result)
+	}
+	return s.printer.result(results, func(w io.Writer) {
+		var rows [][]string
+		for _, v := range results {
+			rows = append(rows, []string{v.Path, byteSize(v.Size), v.Hash, v.JobID})
+		}
+		table(w, []string{"PATH", "SIZE", "HASH", "JOB"}, rows)
+	})
+}
+
+func uploadFile(s *session, c *cli.Context, path string) (*uploadResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	progress := newProgressReader(file, filepath.Base(path), info.Size(), c.Bool("quiet") || s.printer.json)
+	defer progress.done()
+	result := &uploadResult{Path: path, Size: info.Size()}
+	holdTime := c.Int64("hold-time")
+	switch {
+	case c.String("network") != "":
+		resp, err := s.client.UploadFileToNetwork(c.String("network"), filepath.Base(path), progress, holdTime)
+		if err != nil {
+			return nil, err
+		}
+		result.Hash = resp.Status
+	case c.Bool("advanced"):
+		resp, err := s.client.UploadFileAdvanced(filepath.Base(path), progress, holdTime)
+		if err != nil {
+			return nil, err
+		}
+		result.JobID = resp.JobID
+	default:
+		resp, err := s.client.UploadFile(filepath.Base(path), progress, holdTime)
+		if err != nil {
+			return nil, err
+		}
+		result.Hash = resp.Response
+	}
+	return result, nil
+}
+
+// uploadDirectory is used to upload a directory, or a file, as a single ipfs directory. Paths are
+// relative to the directory, or to the parent of the file
+func uploadDirectory(s *session, c *cli.Context, root string, info os.FileInfo) error {
+	base := root
+	if !info.IsDir() {
+		base = filepath.Dir(root)
+	}
+	progress := newProgressReader(nil, filepath.Base(root), 0, c.Bool("quiet") || s.printer.json)
+	var files []client.DirectoryFile
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		progress.total += info.Size()
+		files = append(files, client.DirectoryFile{
+			Path: filepath.ToSlash(rel),
+			Open: func() (io.ReadCloser, error) {
+				file, err := os.Open(path)
+				if err != nil {
+					return nil, err
+				}
+				// files are sent one after another, so they share a progress bar
+				progress.reader = file
+				return struct {
+					io.Reader
+					io.Closer
+				}{progress, file}, nil
+			},
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("%s does not contain any files", root)
+	}
+	resp, err := s.client.UploadDirectory(c.String("network"), files, c.Int64("hold-time"))
+	progress.done()
+	if err != nil {
+		return err
+	}
+	return printDirectory(s, resp)
+}
+
+// uploadArchive is used to upload a tar, tar.gz or zip archive, which the api extracts into a single ipfs directory
+func uploadArchive(s *session, c *cli.Context, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	progress := newProgressReader(file, filepath.Base(path), info.Size(), c.Bool("quiet") || s.printer.json)
+	resp, err := s.client.UploadArchive(c.String("network"), filepath.Base(path), progress, c.Int64("hold-time"))
+	progress.done()
+	if err != nil {
+		return err
+	}
+	return printDirectory(s, resp)
+}
+
+// printDirectory is used to print the cid of an uploaded directory, along with the cid of each of its files
+func printDirectory(s *session, resp *api.DirectoryAddResponse) error {
+	return s.printer.result(resp, func(w io.Writer) {
+		fmt.Fprintf(w, "directory %s\n", resp.Hash)
+		var rows [][]string
+		for _, v := range resp.Files {
+			rows = append(rows, []string{v.Path, byteSize(v.Size), v.Hash})
+		}
+		table(w, []string{"PATH", "SIZE", "HASH"}, rows)
+	})
+}
+
+func pin(s *session, c *cli.Context) error {
+	hash := c.Args().First()
+	if hash == "" {
+		return errors.New("usage: temporal-cli pin <cid>")
+	}
+	var jobID string
+	if network := c.String("network"); network != "" {
+		resp, err := s.client.PinToNetwork(network, hash, c.Int64("hold-time"))
+		if err != nil {
+			return err
+		}
+		jobID = resp.JobID
+	} else {
+		resp, err := s.client.Pin(hash, c.Int64("hold-time"))
+		if err != nil {
+			return err
+		}
+		jobID = resp.JobID
+	}
+	return s.printer.result(map[string]string{"hash": hash, "job_id": jobID}, func(w io.Writer) {
+		fmt.Fprintf(w, "pin of %s queued, job %s\n", hash, jobID)
+	})
+}
+
+func unpin(s *session, c *cli.Context) error {
+	hash := c.Args().First()
+	if hash == "" {
+		return errors.New("usage: temporal-cli unpin <cid>")
+	}
+	var jobID string
+	if network := c.String("network"); network != "" {
+		resp, err := s.client.UnpinFromNetwork(network, hash)
+		if err != nil {
+			return err
+		}
+		jobID = resp.JobID
+	} else {
+		resp, err := s.client.Unpin(hash)
+		if err != nil {
+			return err
+		}
+		jobID = resp.JobID
+	}
+	result := map[string]string{"hash": hash, "job_id": jobID}
+	if !c.Bool("wait") {
+		return s.printer.result(result, func(w io.Writer) {
+			fmt.Fprintf(w, "removal of %s queued, job %s\n", hash, jobID)
+		})
+	}
+	job, err := s.waitForJob(jobID, c.Duration("timeout"))
+	if err != nil {
+		return err
+	}
+	if job.State == models.JobStateFailed {
+		return fmt.Errorf("removal of %s failed: %s", hash, job.Error)
+	}
+	result["result"] = job.Result
+	return s.printer.result(result, func(w io.Writer) {
+		if job.Result == queue.PinRemovalReleased {
+			fmt.Fprintf(w, "released your pin of %s, which other users still hold\n", hash)
+			return
+		}
+		fmt.Fprintf(w, "unpinned %s\n", hash)
+	})
+}
+
+// waitForJob is used to poll a job until it has either succeeded or failed
+func (s *session) waitForJob(jobID string, timeout time.Duration) (*models.Job, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		job, err := s.client.Job(jobID)
+		if err != nil {
+			return nil, err
+		}
+		if job.State == models.JobStateSucceeded || job.State == models.JobStateFailed {
+			return job, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("job %s is still %s after %s", jobID, job.State, timeout)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func download(s *session, c *cli.Context) error {
+	hash := c.Args().First()
+	if hash == "" {
+		return errors.New("usage: temporal-cli download [--output <path>] <cid>")
+	}
+	output := c.String("output")
+	if output == "" {
+		output = hash
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	var offset int64
+	if c.Bool("resume") && output != "-" {
+		if info, err := os.Stat(output); err == nil {
+			offset = info.Size()
+			flags = os.O_WRONLY | os.O_APPEND
+		}
+	}
+	reader, err := s.client.Download(hash, c.String("network"), offset)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if output == "-" {
+		_, err = io.Copy(os.Stdout, reader)
+		return err
+	}
+	file, err := os.OpenFile(output, flags, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	written, err := io.Copy(file, reader)
+	if err != nil {
+		return err
+	}
+	size := offset + written
+	return s.printer.result(map[string]interface{}{"hash": hash, "path": output, "size": size}, func(w io.Writer) {
+		fmt.Fprintf(w, "downloaded %s to %s, %s\n", hash, output, byteSize(size))
+	})
+}
+
+func listUploads(s *session, c *cli.Context) error {
+	if network := c.String("network"); network != "" {
+		uploads, err := s.client.NetworkUploads(network)
+		if err != nil {
+			return err
+		}
+		return s.printer.result(uploads, func(w io.Writer) {
+			var rows [][]string
+			for _, v := range uploads {
+				rows = append(rows, []string{v.Hash, v.Type, strconv.FormatInt(v.HoldTimeInMonths, 10), formatDate(v.GarbageCollectDate)})
+			}
+			table(w, []string{"HASH", "TYPE", "HOLD TIME (MONTHS)", "EXPIRES"}, rows)
+		})
+	}
+	uploads, err := s.client.Uploads()
+	if address := c.String("address"); address != "" {
+		uploads, err = s.client.UploadsForAddress(address)
+	}
+	if err != nil {
+		return err
+	}
+	return s.printer.result(uploads, func(w io.Writer) {
+		var rows [][]string
+		for _, v := range uploads {
+			rows = append(rows, []string{v.Hash, v.NetworkName, strconv.FormatInt(v.HoldTimeInMonths, 10), formatDate(v.GarbageCollectDate)})
+		}
+		table(w, []string{"HASH", "NETWORK", "HOLD TIME (MONTHS)", "EXPIRES"}, rows)
+	})
+}
+
+func publishIPNS(s *session, c *cli.Context) error {
+	hash := c.Args().First()
+	if hash == "" || c.String("key") == "" {
+		return errors.New("usage: temporal-cli ipns publish --key <key name> <cid>")
+	}
+	resp, err := s.client.PublishIPNS(api.IPNSRecordRequest{
+		Hash:     hash,
+		LifeTime: c.String("lifetime"),
+		TTL:      c.String("ttl"),
+		Key:      c.String("key"),
+		Resolve:  c.Bool("resolve"),
+	})
+	if err != nil {
+		return err
+	}
+	return s.printer.result(resp, func(w io.Writer) {
+		fmt.Fprintf(w, "ipns record for %s queued, job %s\n", hash, resp.JobID)
+	})
+}
+
+func createKey(s *session, c *cli.Context) error {
+	name := c.Args().First()
+	if name == "" {
+		return errors.New("usage: temporal-cli keys create <name>")
+	}
+	resp, err := s.client.CreateIPFSKey(name, c.String("type"), c.Int("bits"))
+	if err != nil {
+		return err
+	}
+	return s.printer.result(resp, func(w io.Writer) {
+		fmt.Fprintf(w, "created key %s with id %s\n", name, resp.ID)
+	})
+}
+
+func listKeys(s *session, c *cli.Context) error {
+	resp, err := s.client.IPFSKeys()
+	if err != nil {
+		return err
+	}
+	return s.printer.result(resp, func(w io.Writer) {
+		var rows [][]string
+		for i, v := range resp.KeyNames {
+			id := ""
+			if i < len(resp.KeyIDs) {
+				id = resp.KeyIDs[i]
+			}
+			rows = append(rows, []string{v, id})
+		}
+		table(w, []string{"NAME", "ID"}, rows)
+	})
+}
+
+func listNetworks(s *session, c *cli.Context) error {
+	networks, err := s.client.Networks()
+	if err != nil {
+		return err
+	}
+	return s.printer.result(networks, func(w io.Writer) {
+		for _, v := range networks {
+			fmt.Fprintln(w, v)
+		}
+	})
+}
+
+func cost(s *session, c *cli.Context) error {
+	target := c.Args().First()
+	if target == "" {
+		return errors.New("usage: temporal-cli cost <path | cid>")
+	}
+	holdTime := c.Int64("hold-time")
+	var total float64
+	// local files are priced by their size, anything else is taken to be a cid
+	if info, err := os.Stat(target); err == nil && info.Mode().IsRegular() {
+		file, err := os.Open(target)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if total, err = s.client.FileCost(filepath.Base(target), file, holdTime); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if total, err = s.client.PinCost(target, holdTime); err != nil {
+			return err
+		}
+	}
+	return s.printer.result(api.CostResponse{TotalCostUSD: total}, func(w io.Writer) {
+		fmt.Fprintf(w, "$%.4f to hold %s for %d months\n", total, target, holdTime)
+	})
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02")
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/RTradeLtd/Temporal/api"
+)
+
+// credentials are stored between runs, readable only by the user who logged in
+type credentials struct {
+	API     string               `json:"api"`
+	APIKey  string               `json:"api_key,omitempty"`
+	Session *api.SessionResponse `json:"session,omitempty"`
+}
+
+// defaultCredentialsPath is used when neither the flag, nor TEMPORAL_CREDENTIALS, give a path
+func defaultCredentialsPath() string {
+	home := os.Getenv("HOME")
+	if home == "" {
+		return ".temporal-credentials.json"
+	}
+	return filepath.Join(home, ".temporal", "credentials.json")
+}
+
+func loadCredentials(path string) (*credentials, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &credentials{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	creds := &credentials{}
+	if err = json.Unmarshal(data, creds); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+func saveCredentials(path string, creds *credentials) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+	// written to a temporary file first, so an interrupted save can't lose the session
+	tmp := path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/client"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+/*
+temporal-cli is a command line client for the Temporal api, built with:
+go build -o temporal-cli ./cli
+*/
+
+var defaultAPI = "https://api.temporal.cloud"
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "temporal-cli"
+	app.Usage = "upload, pin and publish content with a Temporal api"
+	app.Version = "0.1.0"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{Name: "api", Value: defaultAPI, EnvVar: "TEMPORAL_API", Usage: "address of the api"},
+		cli.StringFlag{Name: "credentials", Value: defaultCredentialsPath(), EnvVar: "TEMPORAL_CREDENTIALS", Usage: "file the session, or api key, is stored in"},
+		cli.BoolFlag{Name: "json", Usage: "print results as json, for scripts"},
+	}
+	holdTime := cli.Int64Flag{Name: "hold-time", Value: 1, Usage: "number of months to hold the content for"}
+	network := cli.StringFlag{Name: "network", Usage: "private network to use, instead of the public network"}
+	app.Commands = []cli.Command{
+		{
+			Name:      "login",
+			Usage:     "log in, storing the session for later commands",
+			ArgsUsage: "<eth address>",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "two-factor", Usage: "two factor, or recovery, code"},
+				cli.StringFlag{Name: "api-key", Usage: "store an api key instead of logging in with a password"},
+			},
+			Action: login,
+		},
+		{
+			Name:   "logout",
+			Usage:  "end the stored session, and forget it",
+			Action: withClient(logout),
+		},
+		{
+			Name:      "upload",
+			Usage:     "upload a file, or every file in a directory",
+			ArgsUsage: "<path>",
+			Flags: []cli.Flag{
+				holdTime,
+				network,
+				cli.BoolFlag{Name: "advanced", Usage: "add files through the queue, which suits large files"},
+				cli.BoolFlag{Name: "wrap", Usage: "upload a directory as a single ipfs directory, rather than file by file"},
+				cli.BoolFlag{Name: "extract", Usage: "upload a tar, tar.gz or zip archive, extracted into a single ipfs directory"},
+				cli.BoolFlag{Name: "quiet", Usage: "don't draw progress bars"},
+			},
+			Action: withClient(upload),
+		},
+		{
+			Name:      "pin",
+			Usage:     "pin content that is already on ipfs",
+			ArgsUsage: "<cid>",
+			Flags:     []cli.Flag{holdTime, network},
+			Action:    withClient(pin),
+		},
+		{
+			Name:      "unpin",
+			Usage:     "release your pin of content, which is unpinned once no other user holds it",
+			ArgsUsage: "<cid>",
+			Flags: []cli.Flag{
+				network,
+				cli.BoolFlag{Name: "wait", Usage: "wait for the removal to finish, and report its outcome"},
+				cli.DurationFlag{Name: "timeout", Value: time.Minute, Usage: "how long to wait for the removal"},
+			},
+			Action: withClient(unpin),
+		},
+		{
+			Name:      "download",
+			Usage:     "download content you uploaded, or pinned",
+			ArgsUsage: "<cid>",
+			Flags: []cli.Flag{
+				network,
+				cli.StringFlag{Name: "output, o", Usage: "file to write to, - for stdout, defaulting to the cid"},
+				cli.BoolFlag{Name: "resume", Usage: "continue a partial download of the output file"},
+			},
+			Action: withClient(download),
+		},
+		{
+			Name:  "ls",
+			Usage: "list content",
+			Subcommands: []cli.Command{
+				{
+					Name:  "uploads",
+					Usage: "list your uploads",
+					Flags: []cli.Flag{
+						network,
+						cli.StringFlag{Name: "address", Usage: "list the uploads of another user, which needs the view-uploads permission"},
+					},
+					Action: withClient(listUploads),
+				},
+			},
+		},
+		{
+			Name:  "ipns",
+			Usage: "manage ipns records",
+			Subcommands: []cli.Command{
+				{
+					Name:      "publish",
+					Usage:     "publish an ipns record pointing to content",
+					ArgsUsage: "<cid>",
+					Flags: []cli.Flag{
+						cli.StringFlag{Name: "key", Usage: "name of the ipfs key to publish with, see keys list"},
+						cli.StringFlag{Name: "lifetime", Value: "24h", Usage: "how long the record is valid for"},
+						cli.StringFlag{Name: "ttl", Value: "1h", Usage: "how long the record may be cached for"},
+						cli.BoolFlag{Name: "resolve", Usage: "resolve the content before publishing"},
+					},
+					Action: withClient(publishIPNS),
+				},
+			},
+		},
+		{
+			Name:  "keys",
+			Usage: "manage the ipfs keys used to publish ipns records",
+			Subcommands: []cli.Command{
+				{
+					Name:      "create",
+					Usage:     "create an ipfs key",
+					ArgsUsage: "<name>",
+					Flags: []cli.Flag{
+						cli.StringFlag{Name: "type", Value: "ed25519", Usage: "rsa or ed25519"},
+						cli.IntFlag{Name: "bits", Value: 2048, Usage: "size of rsa keys, at most 4096"},
+					},
+					Action: withClient(createKey),
+				},
+				{
+					Name:   "list",
+					Usage:  "list your ipfs keys",
+					Action: withClient(listKeys),
+				},
+			},
+		},
+		{
+			Name:  "networks",
+			Usage: "manage private networks",
+			Subcommands: []cli.Command{
+				{
+					Name:   "list",
+					Usage:  "list the private networks you have access to",
+					Action: withClient(listNetworks),
+				},
+			},
+		},
+		{
+			Name:      "cost",
+			Usage:     "calculate the cost of uploading a file, or pinning content",
+			ArgsUsage: "<path | cid>",
+			Flags:     []cli.Flag{holdTime},
+			Action:    withClient(cost),
+		},
+	}
+	if err := app.Run(os.Args); err != nil {
+		printError(err, jsonOutput(os.Args))
+		os.Exit(1)
+	}
+}
+
+// printError is used to report the error of a command to stderr, as json when json output is on
+func printError(err error, asJSON bool) {
+	if !asJSON {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return
+	}
+	body := map[string]interface{}{"message": err.Error()}
+	if apiErr, ok := err.(*client.Error); ok {
+		body = map[string]interface{}{
+			"status":  apiErr.StatusCode,
+			"code":    apiErr.Code,
+			"message": apiErr.Message,
+			"details": apiErr.Details,
+		}
+	}
+	json.NewEncoder(os.Stderr).Encode(map[string]interface{}{"error": body})
+}
+
+// jsonOutput is used to check for the json flag once the app has exited, and its context is gone
+func jsonOutput(args []string) bool {
+	for _, v := range args {
+		if v == "--json" || v == "-json" {
+			return true
+		}
+	}
+	return false
+}
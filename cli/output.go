@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// printer writes the results of commands, either as json for scripts, or as text for people
+type printer struct {
+	json bool
+	out  io.Writer
+}
+
+// result is used to print a value, with the text printed when json output is off
+func (p *printer) result(value interface{}, text func(w io.Writer)) error {
+	if p.json {
+		encoder := json.NewEncoder(p.out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+	text(p.out)
+	return nil
+}
+
+// table is used to print rows of columns aligned for reading
+func table(w io.Writer, header []string, rows [][]string) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	tw.Flush()
+}
+
+// progressReader draws a progress bar to stderr as an upload is read
+type progressReader struct {
+	reader  io.Reader
+	name    string
+	total   int64
+	read    int64
+	drawn   time.Time
+	enabled bool
+}
+
+// newProgressReader is used to wrap an upload with a progress bar, which is only drawn to terminals
+func newProgressReader(reader io.Reader, name string, total int64, quiet bool) *progressReader {
+	return &progressReader{
+		reader:  reader,
+		name:    name,
+		total:   total,
+		enabled: !quiet && terminal.IsTerminal(int(os.Stderr.Fd())),
+	}
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.reader.Read(p)
+	pr.read += int64(n)
+	if pr.enabled && (err != nil || time.Since(pr.drawn) > 100*time.Millisecond) {
+		pr.draw()
+	}
+	return n, err
+}
+
+func (pr *progressReader) draw() {
+	pr.drawn = time.Now()
+	width := 30
+	filled := width
+	percent := 100.0
+	if pr.total > 0 {
+		percent = float64(pr.read) / float64(pr.total) * 100
+		filled = int(float64(width) * float64(pr.read) / float64(pr.total))
+	}
+	if filled > width {
+		filled = width
+	}
+	fmt.Fprintf(os.Stderr, "\r%-20.20s [%s%s] %5.1f%% %s/%s",
+		pr.name, strings.Repeat("=", filled), strings.Repeat(" ", width-filled), percent, byteSize(pr.read), byteSize(pr.total))
+}
+
+// done is used to finish the progress bar, once the api has responded
+func (pr *progressReader) done() {
+	if pr.enabled {
+		pr.draw()
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// byteSize is used to format a number of bytes for reading, such as 1.5MB
+func byteSize(bytes int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	size := float64(bytes)
+	unit := 0
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%dB", bytes)
+	}
+	return fmt.Sprintf("%.1f%s", size, units[unit])
+}
+
+// readPassword is used to prompt for a password without echoing it
+func readPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		var password string
+		_, err := fmt.Fscanln(os.Stdin, &password)
+		return password, err
+	}
+	password, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return string(password), err
+}
@@ -0,0 +1,296 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/api"
+	"github.com/RTradeLtd/Temporal/api/middleware"
+)
+
+/*
+Package client is a typed client for the Temporal api. Requests and responses use the types of
+the api package, so that the client always matches what the handlers send and receive
+*/
+
+// refreshBefore is how long before its token expires that a session is refreshed
+var refreshBefore = time.Minute
+
+// Client is used to make requests to a Temporal api, authenticated with either a session or an api key
+type Client struct {
+	// URL is the address of the api, such as https://api.temporal.cloud
+	URL  string
+	HTTP *http.Client
+	// APIKey is used instead of a session when set
+	APIKey string
+
+	mux     sync.Mutex
+	session *api.SessionResponse
+	expire  time.Time
+	// refresh tokens can only be used once, so only one request refreshes the session at a time
+	refreshMux sync.Mutex
+}
+
+// Error is returned for every response with an error status, parsed from the v2 error
+// envelope, or from the bodies of v1 routes
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Details    []middleware.FieldError
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("temporal api error (%d): %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("temporal api error (%d %s): %s", e.StatusCode, e.Code, e.Message)
+}
+
+// New is used to generate a client for the api at the given address
+func New(apiURL string) *Client {
+	return &Client{
+		URL:  strings.TrimSuffix(apiURL, "/"),
+		HTTP: &http.Client{Timeout: 10 * time.Minute},
+	}
+}
+
+// Session is used to retrieve the tokens of the current session, so that they can be stored between runs
+func (c *Client) Session() *api.SessionResponse {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.session
+}
+
+// SetSession is used to resume a session, such as one stored by a previous run
+func (c *Client) SetSession(session *api.SessionResponse) error {
+	expire, err := time.Parse(time.RFC3339, session.Expire)
+	if err != nil {
+		return err
+	}
+	c.mux.Lock()
+	c.session = session
+	c.expire = expire
+	c.mux.Unlock()
+	return nil
+}
+
+// Login is used to log in with a password, starting a session. The two factor code is only
+// needed for accounts with two factor authentication enabled
+func (c *Client) Login(username, password, twoFactorCode string) (*api.SessionResponse, error) {
+	headers := http.Header{}
+	if twoFactorCode != "" {
+		headers.Set(middleware.TwoFactorHeader, twoFactorCode)
+	}
+	var resp api.SessionResponse
+	req := api.LoginRequest{Username: username, Password: password}
+	if err := c.doJSON(http.MethodPost, "/api/v2/login", headers, req, &resp, false); err != nil {
+		return nil, err
+	}
+	return &resp, c.SetSession(&resp)
+}
+
+// Refresh is used to exchange the refresh token of the session for new tokens. Requests refresh
+// the session on their own when its token is about to expire
+func (c *Client) Refresh() (*api.SessionResponse, error) {
+	session := c.Session()
+	if session == nil {
+		return nil, errors.New("not logged in")
+	}
+	var resp api.SessionResponse
+	req := api.RefreshSessionRequest{RefreshToken: session.RefreshToken}
+	if err := c.doJSON(http.MethodPost, "/api/v2/login/refresh", nil, req, &resp, false); err != nil {
+		return nil, err
+	}
+	return &resp, c.SetSession(&resp)
+}
+
+// Logout is used to revoke the session of the client, and forget it
+func (c *Client) Logout() error {
+	if err := c.doJSON(http.MethodPost, "/api/v1/account/logout", nil, nil, nil, true); err != nil {
+		return err
+	}
+	c.mux.Lock()
+	c.session = nil
+	c.expire = time.Time{}
+	c.mux.Unlock()
+	return nil
+}
+
+// authorize is used to add the credentials of the client to a request, refreshing the session first if needed
+func (c *Client) authorize(req *http.Request) error {
+	if c.APIKey != "" {
+		req.Header.Set(middleware.APIKeyHeader, c.APIKey)
+		return nil
+	}
+	session, err := c.currentSession()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+session.Token)
+	return nil
+}
+
+// currentSession is used to retrieve a session whose token isn't about to expire
+func (c *Client) currentSession() (*api.SessionResponse, error) {
+	c.refreshMux.Lock()
+	defer c.refreshMux.Unlock()
+	c.mux.Lock()
+	session, expire := c.session, c.expire
+	c.mux.Unlock()
+	if session == nil {
+		return nil, errors.New("not logged in, call Login or set an api key")
+	}
+	if time.Now().Add(refreshBefore).Before(expire) {
+		return session, nil
+	}
+	return c.Refresh()
+}
+
+// doJSON is used to make a request with an optional json body
+func (c *Client) doJSON(method, path string, headers http.Header, body, out interface{}, auth bool) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.URL+path, reader)
+	if err != nil {
+		return err
+	}
+	for k, v := range headers {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.do(req, out, auth)
+}
+
+// doForm is used to make a request with a url encoded form body
+func (c *Client) doForm(method, path string, form url.Values, out interface{}) error {
+	req, err := http.NewRequest(method, c.URL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.do(req, out, true)
+}
+
+// multipartFile is a file of a multipart upload, opened only as it is written
+type multipartFile struct {
+	field string
+	name  string
+	open  func() (io.ReadCloser, error)
+}
+
+// doMultipart is used to upload a file, along with a form, streaming the file rather than buffering it
+func (c *Client) doMultipart(path string, form url.Values, fileName string, file io.Reader, out interface{}) error {
+	open := func() (io.ReadCloser, error) { return ioutil.NopCloser(file), nil }
+	return c.doMultipartFiles(path, form, []multipartFile{{field: "file", name: fileName, open: open}}, out)
+}
+
+// doMultipartFiles is used to upload many files, along with a form, streaming each file in turn
+func (c *Client) doMultipartFiles(path string, form url.Values, files []multipartFile, out interface{}) error {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	go func() {
+		err := func() error {
+			for k, values := range form {
+				for _, v := range values {
+					if err := writer.WriteField(k, v); err != nil {
+						return err
+					}
+				}
+			}
+			for _, v := range files {
+				part, err := writer.CreateFormFile(v.field, v.name)
+				if err != nil {
+					return err
+				}
+				file, err := v.open()
+				if err != nil {
+					return err
+				}
+				_, err = io.Copy(part, file)
+				file.Close()
+				if err != nil {
+					return err
+				}
+			}
+			return writer.Close()
+		}()
+		pw.CloseWithError(err)
+	}()
+	req, err := http.NewRequest(http.MethodPost, c.URL+path, pr)
+	if err != nil {
+		pr.Close()
+		return err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return c.do(req, out, true)
+}
+
+func (c *Client) do(req *http.Request, out interface{}, auth bool) error {
+	if auth {
+		if err := c.authorize(req); err != nil {
+			return err
+		}
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// a few v1 routes respond with a 302 rather than a 200
+	if resp.StatusCode >= 400 {
+		return parseError(resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// parseError is used to read the error of a response, from either the v2 envelope or a v1 body
+func parseError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil || len(data) == 0 {
+		return apiErr
+	}
+	var body struct {
+		Error   json.RawMessage `json:"error"`
+		Message string          `json:"message"`
+	}
+	if err = json.Unmarshal(data, &body); err != nil {
+		apiErr.Message = strings.TrimSpace(string(data))
+		return apiErr
+	}
+	var envelope middleware.APIError
+	var message string
+	switch {
+	case json.Unmarshal(body.Error, &envelope) == nil && envelope.Code != "":
+		apiErr.Code = envelope.Code
+		apiErr.Message = envelope.Message
+		apiErr.Details = envelope.Details
+	case json.Unmarshal(body.Error, &message) == nil && message != "":
+		apiErr.Message = message
+	case body.Message != "":
+		apiErr.Message = body.Message
+	}
+	return apiErr
+}
@@ -0,0 +1,170 @@
+package client_test
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/RTradeLtd/Temporal/api"
+	"github.com/RTradeLtd/Temporal/client"
+	"github.com/RTradeLtd/Temporal/config"
+	"github.com/RTradeLtd/Temporal/database"
+	"github.com/RTradeLtd/Temporal/models"
+)
+
+var defaultConfigFile = "/home/solidity/config.json"
+var testCID = "QmPY5iMFjNZKxRbUZZC85wXb9CFgNSyzAy1LxwL62D8VGr"
+var unownedCID = "QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"
+var testEthAddress = "0x5A1d4D5A7CbA4e3D5dE2a28ffAd3b3bE1a8D4A6c"
+var testPassword = "password123"
+
+// newTestClient is used to serve the api with httptest, returning a client logged in as the test user
+func newTestClient(t *testing.T) (*client.Client, func()) {
+	cfg, err := config.LoadConfig(defaultConfigFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := database.OpenDBConnection(cfg.Database.Password, cfg.Database.URL, cfg.Database.Username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(api.Setup(cfg))
+	um := models.NewUserManager(db)
+	if um.FindByAddress(testEthAddress) == nil {
+		if _, err = um.NewUserAccount(testEthAddress, testPassword, "client-test@example.com", false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = um.VerifyEmailAddress(testEthAddress); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Model(&models.User{}).Where("eth_address = ?", testEthAddress).Update("api_access", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	c := client.New(server.URL)
+	if _, err = c.Login(testEthAddress, testPassword, ""); err != nil {
+		t.Fatal(err)
+	}
+	return c, func() {
+		server.Close()
+		db.Close()
+	}
+}
+
+func TestClientLogin(t *testing.T) {
+	c, done := newTestClient(t)
+	defer done()
+
+	session := c.Session()
+	refreshed, err := c.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.RefreshToken == session.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+	// the old refresh token can't be used again
+	if err = c.SetSession(session); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Refresh(); err == nil {
+		t.Fatal("refresh token was reused")
+	}
+
+	_, err = client.New(c.URL).Login(testEthAddress, "wrong password", "")
+	apiErr, ok := err.(*client.Error)
+	if !ok {
+		t.Fatalf("expected an api error, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusUnauthorized || apiErr.Code != "unauthorized" {
+		t.Fatalf("unexpected login error %+v", apiErr)
+	}
+}
+
+func TestClientPin(t *testing.T) {
+	c, done := newTestClient(t)
+	defer done()
+
+	_, err := c.Pin("not a cid", 0)
+	apiErr, ok := err.(*client.Error)
+	if !ok {
+		t.Fatalf("expected an api error, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusUnprocessableEntity || apiErr.Code != "validation_failed" || len(apiErr.Details) != 2 {
+		t.Fatalf("unexpected validation error %+v", apiErr)
+	}
+
+	resp, err := c.Pin(testCID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := c.Job(resp.JobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Type != models.JobTypeIPFSPin {
+		t.Fatalf("unexpected job type %s", job.Type)
+	}
+	if _, err = c.Job("missing"); err == nil || err.(*client.Error).Code != "not_found" {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+
+	// only content pinned by the user can be unpinned
+	if _, err = c.Unpin("not a cid"); err == nil || err.(*client.Error).Code != "validation_failed" {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	if _, err = c.Unpin(unownedCID); err == nil || err.(*client.Error).Code != "forbidden" {
+		t.Fatalf("expected a forbidden error, got %v", err)
+	}
+}
+
+func TestClientUploadDirectory(t *testing.T) {
+	c, done := newTestClient(t)
+	defer done()
+
+	file := func(path, content string) client.DirectoryFile {
+		open := func() (io.ReadCloser, error) { return ioutil.NopCloser(strings.NewReader(content)), nil }
+		return client.DirectoryFile{Path: path, Open: open}
+	}
+	resp, err := c.UploadDirectory("", []client.DirectoryFile{
+		file("index.html", "<html></html>"),
+		file("css/site.css", "body {}"),
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Hash == "" || len(resp.Files) != 2 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	// files are listed by path
+	if resp.Files[0].Path != "css/site.css" || resp.Files[0].Size != 7 || resp.Files[0].Hash == "" {
+		t.Fatalf("unexpected file %+v", resp.Files[0])
+	}
+
+	// paths outside of the directory are rejected
+	if _, err = c.UploadDirectory("", []client.DirectoryFile{file("../escape", "x")}, 1); err == nil {
+		t.Fatal("expected an error for a path outside of the directory")
+	}
+}
+
+func TestClientAccount(t *testing.T) {
+	c, done := newTestClient(t)
+	defer done()
+
+	if _, err := c.Uploads(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Networks(); err != nil {
+		t.Fatal(err)
+	}
+	cost, err := c.FileCost("test.txt", strings.NewReader("hello temporal"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost <= 0 {
+		t.Fatalf("unexpected file cost %v", cost)
+	}
+}
@@ -0,0 +1,182 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+
+	"github.com/RTradeLtd/Temporal/api"
+	"github.com/RTradeLtd/Temporal/models"
+)
+
+// UploadFile is used to add a file to the public network, holding it for the given number of months.
+// The file is added before the call returns
+func (c *Client) UploadFile(fileName string, file io.Reader, holdTimeMonths int64) (*api.FileAddResponse, error) {
+	var resp api.FileAddResponse
+	form := url.Values{"hold_time": {strconv.FormatInt(holdTimeMonths, 10)}}
+	if err := c.doMultipart("/api/v1/ipfs/add-file", form, fileName, file, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// UploadFileAdvanced is used to add a file to the public network through the queue,
+// which suits large files. The job of the upload can be tracked with Job
+func (c *Client) UploadFileAdvanced(fileName string, file io.Reader, holdTimeMonths int64) (*api.FileAddJobResponse, error) {
+	var resp api.FileAddJobResponse
+	form := url.Values{"hold_time": {strconv.FormatInt(holdTimeMonths, 10)}}
+	if err := c.doMultipart("/api/v1/ipfs/add-file/advanced", form, fileName, file, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Pin is used to pin content to the public network, holding it for the given number of months
+func (c *Client) Pin(hash string, holdTimeMonths int64) (*api.JobAcceptedResponse, error) {
+	var resp api.JobAcceptedResponse
+	req := api.PinRequest{Hash: hash, HoldTimeMonths: holdTimeMonths}
+	if err := c.doJSON(http.MethodPost, "/api/v2/ipfs/pins", nil, req, &resp, true); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Unpin is used to release the authenticated user's pin of content on the public network. The content is only
+// unpinned once no other user holds it, so the result of the job is either queue.PinRemovalReleased or queue.PinRemovalUnpinned
+func (c *Client) Unpin(hash string) (*api.JobAcceptedResponse, error) {
+	var resp api.JobAcceptedResponse
+	if err := c.doJSON(http.MethodDelete, "/api/v2/ipfs/pins/"+url.PathEscape(hash), nil, nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Uploads is used to list the uploads of the authenticated user
+func (c *Client) Uploads() ([]models.UploadOwner, error) {
+	var resp api.UploadsResponse
+	if err := c.doJSON(http.MethodGet, "/api/v2/uploads", nil, nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return resp.Uploads, nil
+}
+
+// UploadsForAddress is used to list the uploads of any user, which needs the view-uploads permission
+func (c *Client) UploadsForAddress(ethAddress string) ([]models.UploadOwner, error) {
+	var resp api.UploadsResponse
+	if err := c.doJSON(http.MethodGet, "/api/v2/uploads/"+url.PathEscape(ethAddress), nil, nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return resp.Uploads, nil
+}
+
+// Job is used to retrieve the state of an operation sent to the queue
+func (c *Client) Job(jobID string) (*models.Job, error) {
+	var resp api.JobResponse
+	if err := c.doJSON(http.MethodGet, "/api/v2/jobs/"+url.PathEscape(jobID), nil, nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return resp.Job, nil
+}
+
+// PinCost is used to calculate the cost, in usd, of pinning content for the given number of months
+func (c *Client) PinCost(hash string, holdTimeMonths int64) (float64, error) {
+	var resp api.CostResponse
+	path := "/api/v1/frontend/cost/calculate/" + url.PathEscape(hash) + "/" + strconv.FormatInt(holdTimeMonths, 10)
+	if err := c.doJSON(http.MethodGet, path, nil, nil, &resp, true); err != nil {
+		return 0, err
+	}
+	return resp.TotalCostUSD, nil
+}
+
+// FileCost is used to calculate the cost, in usd, of uploading a file for the given number of months
+func (c *Client) FileCost(fileName string, file io.Reader, holdTimeMonths int64) (float64, error) {
+	var resp api.CostResponse
+	form := url.Values{"hold_time": {strconv.FormatInt(holdTimeMonths, 10)}}
+	if err := c.doMultipart("/api/v1/frontend/cost/calculate/file", form, fileName, file, &resp); err != nil {
+		return 0, err
+	}
+	return resp.TotalCostUSD, nil
+}
+
+// Download is used to stream content uploaded, or pinned, by the authenticated user, starting offset bytes
+// into it so that interrupted downloads can be resumed. An empty network name downloads from the public
+// network. Callers need to close the returned reader after usage
+func (c *Client) Download(hash, networkName string, offset int64) (io.ReadCloser, error) {
+	path := "/api/v1/ipfs/download/" + url.PathEscape(hash)
+	if networkName != "" {
+		path += "?" + url.Values{"network_name": {networkName}}.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, c.URL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+	if err = c.authorize(req); err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, parseError(resp)
+	}
+	// appending the whole of the content to a partial download would corrupt it
+	if offset > 0 && resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, errors.New("the api did not return the requested range")
+	}
+	return resp.Body, nil
+}
+
+// DirectoryFile is a file of a directory upload, at a slash separated path relative to the directory.
+// Files are opened only as they are sent, so that large directories don't hold every file open
+type DirectoryFile struct {
+	Path string
+	Open func() (io.ReadCloser, error)
+}
+
+// UploadDirectory is used to add files to ipfs as a single directory, holding it for the given number of months.
+// An empty network name uploads to the public network. The response holds the cid of the directory, along with
+// the cid of each file
+func (c *Client) UploadDirectory(networkName string, files []DirectoryFile, holdTimeMonths int64) (*api.DirectoryAddResponse, error) {
+	var resp api.DirectoryAddResponse
+	form := directoryForm(networkName, holdTimeMonths)
+	var parts []multipartFile
+	for _, v := range files {
+		form.Add("paths", v.Path)
+		parts = append(parts, multipartFile{field: "files", name: path.Base(v.Path), open: v.Open})
+	}
+	if err := c.doMultipartFiles("/api/v1/ipfs/add-directory", form, parts, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// UploadArchive is used to add the contents of a tar, tar.gz or zip archive to ipfs as a single directory,
+// with the archive extracted by the api. An empty network name uploads to the public network
+func (c *Client) UploadArchive(networkName, fileName string, archive io.Reader, holdTimeMonths int64) (*api.DirectoryAddResponse, error) {
+	var resp api.DirectoryAddResponse
+	open := func() (io.ReadCloser, error) { return ioutil.NopCloser(archive), nil }
+	parts := []multipartFile{{field: "archive", name: fileName, open: open}}
+	if err := c.doMultipartFiles("/api/v1/ipfs/add-directory", directoryForm(networkName, holdTimeMonths), parts, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// directoryForm is used to build the form of a directory upload
+func directoryForm(networkName string, holdTimeMonths int64) url.Values {
+	form := url.Values{"hold_time": {strconv.FormatInt(holdTimeMonths, 10)}}
+	if networkName != "" {
+		form.Set("network_name", networkName)
+	}
+	return form
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/RTradeLtd/Temporal/api"
+)
+
+// PublishIPNS is used to publish an ipns record to the public network, with a key owned by the authenticated user
+func (c *Client) PublishIPNS(req api.IPNSRecordRequest) (*api.JobAcceptedResponse, error) {
+	var resp api.JobAcceptedResponse
+	if err := c.doJSON(http.MethodPost, "/api/v2/ipns/records", nil, req, &resp, true); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// AddDNSLink is used to add a dnslink TXT record to a route53 zone, which needs the manage-dns permission
+func (c *Client) AddDNSLink(recordName, recordValue, awsZone, regionName string) (*api.DNSLinkResponse, error) {
+	var resp api.DNSLinkResponse
+	form := url.Values{
+		"record_name":  {recordName},
+		"record_value": {recordValue},
+		"aws_zone":     {awsZone},
+		"region_name":  {regionName},
+	}
+	if err := c.doForm(http.MethodPost, "/api/v1/ipns/dnslink/aws/add", form, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CreateIPFSKey is used to create an ipfs key for publishing ipns records. The key type is rsa,
+// which takes a number of bits up to 4096, or ed25519
+func (c *Client) CreateIPFSKey(name, keyType string, bits int) (*api.IPFSKeyResponse, error) {
+	var resp api.IPFSKeyResponse
+	form := url.Values{
+		"key_name": {name},
+		"key_type": {keyType},
+		"key_bits": {strconv.Itoa(bits)},
+	}
+	if err := c.doForm(http.MethodPost, "/api/v1/account/key/ipfs/new", form, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// IPFSKeys is used to list the ipfs keys of the authenticated user
+func (c *Client) IPFSKeys() (*api.IPFSKeysResponse, error) {
+	var resp api.IPFSKeysResponse
+	if err := c.doJSON(http.MethodGet, "/api/v1/account/key/ipfs/get", nil, nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
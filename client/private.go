@@ -0,0 +1,109 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/RTradeLtd/Temporal/api"
+	"github.com/RTradeLtd/Temporal/models"
+)
+
+// NetworkOptions are the settings of a new private network. BootstrapPeers and LocalNodeAddresses
+// are multiaddrs, paired by index. Without users, only the creator has access to the network
+type NetworkOptions struct {
+	APIURL             string
+	SwarmKey           string
+	BootstrapPeers     []string
+	LocalNodeAddresses []string
+	Users              []string
+}
+
+// Networks is used to list the names of the private networks the authenticated user has access to
+func (c *Client) Networks() ([]string, error) {
+	var resp api.PrivateNetworksResponse
+	if err := c.doJSON(http.MethodGet, "/api/v1/ipfs-private/networks", nil, nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return resp.Networks, nil
+}
+
+// Network is used to retrieve a private network, which needs the manage-private-networks permission
+func (c *Client) Network(name string) (*models.HostedIPFSPrivateNetwork, error) {
+	var resp api.PrivateNetworkResponse
+	form := url.Values{"network_name": {name}}
+	if err := c.doForm(http.MethodPost, "/api/v1/ipfs-private/network/name", form, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Network, nil
+}
+
+// CreateNetwork is used to create a private network, which needs the manage-private-networks permission
+func (c *Client) CreateNetwork(name string, opts NetworkOptions) (*models.HostedIPFSPrivateNetwork, error) {
+	var resp api.PrivateNetworkResponse
+	form := url.Values{
+		"network_name":         {name},
+		"api_url":              {opts.APIURL},
+		"swarm_key":            {opts.SwarmKey},
+		"bootstrap_peers":      opts.BootstrapPeers,
+		"local_node_addresses": opts.LocalNodeAddresses,
+		"users":                opts.Users,
+	}
+	if err := c.doForm(http.MethodPost, "/api/v1/ipfs-private/new/network", form, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Network, nil
+}
+
+// NetworkUploads is used to list the uploads to a private network
+func (c *Client) NetworkUploads(name string) ([]models.Upload, error) {
+	var resp api.PrivateNetworkUploadsResponse
+	form := url.Values{"network_name": {name}}
+	if err := c.doForm(http.MethodPost, "/api/v1/ipfs-private/uploads", form, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Uploads == nil {
+		return nil, nil
+	}
+	return *resp.Uploads, nil
+}
+
+// UploadFileToNetwork is used to add a file to a private network, holding it for the given number of months
+func (c *Client) UploadFileToNetwork(network, fileName string, file io.Reader, holdTimeMonths int64) (*api.PrivateFileAddResponse, error) {
+	var resp api.PrivateFileAddResponse
+	form := url.Values{
+		"use_private_network": {"true"},
+		"network_name":        {network},
+		"hold_time":           {strconv.FormatInt(holdTimeMonths, 10)},
+	}
+	if err := c.doMultipart("/api/v1/ipfs/add-file", form, fileName, file, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// PinToNetwork is used to pin content to a private network, holding it for the given number of months
+func (c *Client) PinToNetwork(network, hash string, holdTimeMonths int64) (*api.StatusJobResponse, error) {
+	var resp api.StatusJobResponse
+	form := url.Values{
+		"use_private_network": {"true"},
+		"network_name":        {network},
+		"hold_time":           {strconv.FormatInt(holdTimeMonths, 10)},
+	}
+	if err := c.doForm(http.MethodPost, "/api/v1/ipfs/pin/"+url.PathEscape(hash), form, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// UnpinFromNetwork is used to release the authenticated user's pin of content on a private network. Like Unpin,
+// the content is only unpinned once no other user holds it, with the outcome given by the result of the job
+func (c *Client) UnpinFromNetwork(network, hash string) (*api.StatusJobResponse, error) {
+	var resp api.StatusJobResponse
+	path := "/api/v1/ipfs-private/pin/remove/" + url.PathEscape(hash) + "?" + url.Values{"network_name": {network}}.Encode()
+	if err := c.doJSON(http.MethodDelete, path, nil, nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
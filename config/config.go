@@ -28,6 +28,28 @@ type TemporalConfig struct {
 		} `json:"sessions"`
 		RollbarToken string `json:"rollbar_token"`
 		JwtKey       string `json:"jwt_key"`
+		// SigningKeys configures the rotating asymmetric keys JWTs are signed with, instead of the jwt key
+		SigningKeys struct {
+			// Algorithm is RS256 or EdDSA, leaving it empty signs tokens with the jwt key using HS256
+			Algorithm string `json:"algorithm"`
+			// RotationInterval is how long each key signs tokens for, such as "720h"
+			RotationInterval string `json:"rotation_interval"`
+			// Overlap is how long each key is published before it signs tokens, and after it retires, such as "24h"
+			Overlap string `json:"overlap"`
+			// EncryptionKey is the secret private keys are encrypted with in the database, required with an algorithm
+			EncryptionKey string `json:"encryption_key"`
+		} `json:"signing_keys"`
+		// Admins are the eth addresses granted the admin role when the api starts
+		Admins []string `json:"admins"`
+		// RequireTwoFactorForEnterprise stops enterprise accounts using the api until they enable two factor authentication
+		RequireTwoFactorForEnterprise bool `json:"require_two_factor_for_enterprise"`
+		// RateLimits overrides the default limits of each plan, free or enterprise, and of individual users
+		RateLimits struct {
+			// Plans holds the limits of each plan, keyed by plan name then route class
+			Plans map[string]map[string]RateLimitConfig `json:"plans"`
+			// Users holds the limits of individual users, keyed by eth address then route class
+			Users map[string]map[string]RateLimitConfig `json:"users"`
+		} `json:"rate_limits"`
 	} `json:"api"`
 	Ethereum struct {
 		Account struct {
@@ -50,6 +72,11 @@ type TemporalConfig struct {
 	} `json:"ethereum"`
 	RabbitMQ struct {
 		URL string `json:"url"`
+		// ManagementURL is the url of the management api, used to check the dead-letter policies of queues.
+		// It defaults to port 15672 of the host of URL
+		ManagementURL string `json:"management_url"`
+		// Consumers holds the settings for the consumer of each queue, keyed by queue name
+		Consumers map[string]ConsumerConfig `json:"consumers"`
 	} `json:"rabbitmq"`
 	AWS struct {
 		KeyID  string `json:"key_id"`
@@ -68,6 +95,30 @@ type TemporalConfig struct {
 		EmailAddress string `json:"email_address"`
 		EmailName    string `json:"email_name"`
 	} `json:"sendgrid"`
+	Retention struct {
+		// Interval is how often expired content is removed, such as "24h"
+		Interval string `json:"interval"`
+		// GracePeriod is how long content is kept once its hold time has expired, such as "168h"
+		GracePeriod string `json:"grace_period"`
+		// DryRun is used to only report the content that would be removed
+		DryRun bool `json:"dry_run"`
+	} `json:"retention"`
+}
+
+// RateLimitConfig is used to configure the rate limit of a class of routes
+type RateLimitConfig struct {
+	// Rate is the number of requests allowed each minute
+	Rate float64 `json:"rate"`
+	// Burst is the number of requests that can be made at once
+	Burst int `json:"burst"`
+}
+
+// ConsumerConfig is used to configure how a queue consumer processes messages
+type ConsumerConfig struct {
+	// Prefetch is the number of unacknowledged messages rabbitmq will send the consumer
+	Prefetch int `json:"prefetch"`
+	// Concurrency is the number of messages the consumer will process in parallel
+	Concurrency int `json:"concurrency"`
 }
 
 func LoadConfig(configPath string) (*TemporalConfig, error) {
@@ -9,11 +9,23 @@ import (
 )
 
 var UploadObj *models.Upload
+var UploadOwnerObj *models.UploadOwner
 var UserObj *models.User
 var PinPaymentObj *models.PinPayment
 var FilePaymentObj *models.FilePayment
 var IpnsObj *models.IPNS
 var HostedIpfsNetObj *models.HostedIPFSPrivateNetwork
+var PinRequestObj *models.PinRequest
+var JobObj *models.Job
+var WebhookObj *models.Webhook
+var WebhookDeliveryObj *models.WebhookDelivery
+var APIKeyObj *models.APIKey
+var LoginChallengeObj *models.LoginChallenge
+var UserTokenObj *models.UserToken
+var SessionObj *models.Session
+var SigningKeyObj *models.SigningKey
+var LoginThrottleObj *models.LoginThrottle
+var RateLimitBucketObj *models.RateLimitBucket
 
 type DatabaseManager struct {
 	DB     *gorm.DB
@@ -33,6 +45,15 @@ func Initialize(dbPass, dbURL, dbUser string) (*DatabaseManager, error) {
 
 func (dbm *DatabaseManager) RunMigrations() {
 	dbm.DB.AutoMigrate(UploadObj)
+	dbm.DB.AutoMigrate(UploadOwnerObj)
+	// uploads made before ownership was tracked need owners for their retention to be kept
+	if err := models.NewUploadManager(dbm.DB).BackfillUploadOwners(); err != nil {
+		fmt.Println("failed to backfill upload owners ", err)
+	}
+	// consumers processing the same content at once would otherwise create duplicate uploads and owners
+	if err := models.NewUploadManager(dbm.DB).CreateUniqueIndexes(); err != nil {
+		fmt.Println("failed to create unique upload indexes ", err)
+	}
 	dbm.DB.AutoMigrate(UserObj)
 	dbm.DB.AutoMigrate(PinPaymentObj)
 	dbm.DB.AutoMigrate(FilePaymentObj)
@@ -40,6 +61,17 @@ func (dbm *DatabaseManager) RunMigrations() {
 	// so we will override with ipns
 	dbm.DB.AutoMigrate(IpnsObj)
 	dbm.DB.AutoMigrate(HostedIpfsNetObj)
+	dbm.DB.AutoMigrate(PinRequestObj)
+	dbm.DB.AutoMigrate(JobObj)
+	dbm.DB.AutoMigrate(WebhookObj)
+	dbm.DB.AutoMigrate(WebhookDeliveryObj)
+	dbm.DB.AutoMigrate(APIKeyObj)
+	dbm.DB.AutoMigrate(LoginChallengeObj)
+	dbm.DB.AutoMigrate(UserTokenObj)
+	dbm.DB.AutoMigrate(SessionObj)
+	dbm.DB.AutoMigrate(SigningKeyObj)
+	dbm.DB.AutoMigrate(LoginThrottleObj)
+	dbm.DB.AutoMigrate(RateLimitBucketObj)
 	//dbm.DB.Model(userObj).Related(uploadObj.Users)
 }
 
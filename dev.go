@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/RTradeLtd/Temporal/api"
+	"github.com/RTradeLtd/Temporal/config"
+	"github.com/RTradeLtd/Temporal/database"
+	"github.com/RTradeLtd/Temporal/queue"
+)
+
+// devConsumers are the consumers which can be run in dev mode, keyed by the command used to run them on their own
+var devConsumers = []struct {
+	command   string
+	queueName string
+}{
+	{"queue-dpa", queue.DatabasePinAddQueue},
+	{"queue-dfa", queue.DatabaseFileAddQueue},
+	{"ipfs-pin-queue", queue.IpfsPinQueue},
+	{"ipfs-file-queue", queue.IpfsFileQueue},
+	{"pin-payment-confirmation-queue", queue.PinPaymentConfirmationQueue},
+	{"pin-payment-submission-queue", queue.PinPaymentSubmissionQueue},
+	{"email-send-queue", queue.EmailSendQueue},
+	{"ipns-entry-queue", queue.IpnsEntryQueue},
+	{"ipfs-pin-removal-queue", queue.IpfsPinRemovalQueue},
+	{"webhook-send-queue", queue.WebhookSendQueue},
+}
+
+// runDev is used to run the api, along with every consumer, in a single process. Unless
+// told to use rabbitmq, messages are passed between them with an in-memory broker
+func runDev(cfg *config.TemporalConfig, args []string) {
+	flags := flag.NewFlagSet("dev", flag.ExitOnError)
+	apiEnabled := flags.Bool("api", true, "run the api")
+	useRabbitMQ := flags.Bool("rabbitmq", false, "use rabbitmq instead of the in-memory broker")
+	retentionEnabled := flags.Bool("retention", false, "run the retention worker, unpinning expired content")
+	enabled := make(map[string]*bool)
+	for _, v := range devConsumers {
+		enabled[v.command] = flags.Bool(v.command, true, fmt.Sprintf("run the %s consumer", v.queueName))
+	}
+	flags.Parse(args)
+
+	var broker queue.Broker
+	if *useRabbitMQ {
+		for _, v := range devConsumers {
+			if !*enabled[v.command] {
+				continue
+			}
+			if err := queue.CheckDeadLetterPolicy(cfg.RabbitMQ.URL, cfg.RabbitMQ.ManagementURL, v.queueName); err != nil {
+				log.Fatal(err)
+			}
+		}
+		amqpBroker, err := queue.NewAMQPBroker(cfg.RabbitMQ.URL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		broker = amqpBroker
+	} else {
+		fmt.Println("using in-memory broker, queued messages will be lost on exit")
+		broker = queue.NewMemoryBroker()
+	}
+	defer broker.Close()
+
+	if *apiEnabled {
+		router := api.SetupWithPublisher(cfg, broker)
+		go func() {
+			// dev mode is served without tls, so that certificates aren't needed
+			log.Fatal(router.Run(fmt.Sprintf("%s:6767", cfg.API.Connection.ListenAddress)))
+		}()
+	}
+
+	db, err := database.OpenDBConnection(cfg.Database.Password, cfg.Database.URL, cfg.Database.Username)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	consumers := 0
+	for _, v := range devConsumers {
+		if !*enabled[v.command] {
+			continue
+		}
+		fmt.Println("starting consumer for ", v.queueName)
+		consumers++
+		wg.Add(1)
+		go func(queueName string) {
+			defer wg.Done()
+			if err := queue.ConsumeMessages(broker, queueName, "", db, cfg); err != nil {
+				log.Fatal(err)
+			}
+		}(v.queueName)
+	}
+	if *retentionEnabled {
+		go func() {
+			log.Fatal(queue.StartRetentionWorker(db, cfg))
+		}()
+	}
+	if consumers == 0 && (*apiEnabled || *retentionEnabled) {
+		// with no consumers running, we only need to keep the api and retention worker running
+		select {}
+	}
+	// consumers return once they have drained their in-flight messages upon shutdown
+	wg.Wait()
+}
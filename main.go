@@ -18,14 +18,16 @@ var keyFile = "/home/solidity/certificates/api.key"
 var tCfg config.TemporalConfig
 
 func main() {
-	if len(os.Args) > 2 || len(os.Args) < 2 {
+	if (len(os.Args) > 2 && os.Args[1] != "dev") || len(os.Args) < 2 {
 		fmt.Println("incorrect invocation")
-		fmt.Println("./Temporal [api | swarm | queue-dpa | queue-dfa | ipfs-cluster-queue | migrate]")
+		fmt.Println("./Temporal [api | dev | swarm | queue-dpa | queue-dfa | ipfs-cluster-queue | retention | migrate]")
 		fmt.Println("api: run the api, used to interact with temporal")
+		fmt.Println("dev: run the api and every queue consumer in one process, see ./Temporal dev -h for flags")
 		fmt.Println("swarm: run the ethereum swarm mode of tempora")
 		fmt.Println("queue-dpa: listen to pin requests, and store them in the database")
 		fmt.Println("queue-dfa: listen to file add requests, and add to the database")
 		fmt.Println("ipfs-cluster-queue: listen to cluster pin pubsub topic")
+		fmt.Println("retention: unpin content whose hold time has expired, on the configured interval")
 		fmt.Println("migrate: migrate the database")
 		os.Exit(1)
 	}
@@ -49,6 +51,8 @@ func main() {
 	case "api":
 		router := api.Setup(tCfg)
 		router.RunTLS(fmt.Sprintf("%s:6767", listenAddress), certFilePath, keyFilePath)
+	case "dev":
+		runDev(tCfg, os.Args[2:])
 	case "swarm":
 		sm, err := rtswarm.NewSwarmManager()
 		if err != nil {
@@ -145,6 +149,25 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
+	case "webhook-send-queue":
+		mqConnectionURL := tCfg.RabbitMQ.URL
+		qm, err := queue.Initialize(queue.WebhookSendQueue, mqConnectionURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = qm.ConsumeMessage("", dbPass, dbURL, ethKeyFilePath, ethKeyPass, dbUser, tCfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "retention":
+		db, err := database.OpenDBConnection(dbPass, dbURL, dbUser)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = queue.StartRetentionWorker(db, tCfg)
+		if err != nil {
+			log.Fatal(err)
+		}
 	case "migrate":
 		dbm, err := database.Initialize(dbPass, dbURL, dbUser)
 		if err != nil {
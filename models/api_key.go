@@ -0,0 +1,167 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/utils"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+const (
+	// APIKeyPrefix is the prefix of every api key, letting them be told apart from JWTs
+	APIKeyPrefix = "temporal_"
+	// APIKeyScopePin allows content to be pinned
+	APIKeyScopePin = "pin"
+	// APIKeyScopeUpload allows files to be uploaded
+	APIKeyScopeUpload = "upload"
+	// APIKeyScopeIPNS allows ipns records to be published
+	APIKeyScopeIPNS = "ipns"
+	// APIKeyScopeReadOnly allows read requests
+	APIKeyScopeReadOnly = "read-only"
+	// APIKeyScopePrivateNetworkPrefix prefixes the name of a private network the key may use
+	APIKeyScopePrivateNetworkPrefix = "private-network:"
+)
+
+// APIKey is a long-lived credential a user can authenticate with instead of logging in.
+// Only the hash of the key is stored, the key itself is only returned when it is created
+type APIKey struct {
+	gorm.Model
+	EthAddress string         `gorm:"type:varchar(255);not null;index" json:"eth_address"`
+	Name       string         `gorm:"type:varchar(255)" json:"name"`
+	KeyHash    string         `gorm:"type:varchar(255);not null;unique_index" json:"-"`
+	KeyPrefix  string         `gorm:"type:varchar(255);not null" json:"key_prefix"`
+	Scopes     pq.StringArray `gorm:"type:text[];not null" json:"scopes"`
+	// ExpiresAt is nil for keys which never expire
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Revoked    bool       `json:"revoked"`
+}
+
+// APIKeyManager is used to manipulate api keys in the database
+type APIKeyManager struct {
+	DB *gorm.DB
+}
+
+// NewAPIKeyManager is used to generate our api key manager
+func NewAPIKeyManager(db *gorm.DB) *APIKeyManager {
+	return &APIKeyManager{DB: db}
+}
+
+// PrivateNetworkScope is used to get the scope allowing use of the given private network
+func PrivateNetworkScope(networkName string) string {
+	return APIKeyScopePrivateNetworkPrefix + networkName
+}
+
+// IsValidAPIKeyScope is used to check whether or not the given scope exists
+func IsValidAPIKeyScope(scope string) bool {
+	switch scope {
+	case APIKeyScopePin, APIKeyScopeUpload, APIKeyScopeIPNS, APIKeyScopeReadOnly:
+		return true
+	}
+	return strings.HasPrefix(scope, APIKeyScopePrivateNetworkPrefix) && len(scope) > len(APIKeyScopePrivateNetworkPrefix)
+}
+
+// NewAPIKey is used to create an api key for a user, returning the key which must be given to the user
+// as it can't be retrieved again. A nil expiresAt creates a key which never expires
+func (akm *APIKeyManager) NewAPIKey(ethAddress, name string, scopes []string, expiresAt *time.Time) (*APIKey, string, error) {
+	if len(scopes) == 0 {
+		return nil, "", errors.New("at least one scope is required")
+	}
+	for _, v := range scopes {
+		if !IsValidAPIKeyScope(v) {
+			return nil, "", errors.New("invalid api key scope " + v)
+		}
+	}
+	if expiresAt != nil && expiresAt.Before(time.Now()) {
+		return nil, "", errors.New("expiry must be in the future")
+	}
+	secret, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	token := APIKeyPrefix + secret
+	key := APIKey{
+		EthAddress: ethAddress,
+		Name:       name,
+		KeyHash:    hashToken(token),
+		KeyPrefix:  token[:len(APIKeyPrefix)+8],
+		Scopes:     scopes,
+		ExpiresAt:  expiresAt,
+	}
+	if check := akm.DB.Create(&key); check.Error != nil {
+		return nil, "", check.Error
+	}
+	return &key, token, nil
+}
+
+// FindAPIKeyByToken is used to find the api key matching the given token, as long as it is still valid
+func (akm *APIKeyManager) FindAPIKeyByToken(token string) (*APIKey, error) {
+	key := &APIKey{}
+	if check := akm.DB.Where("key_hash = ?", hashToken(token)).First(key); check.Error != nil {
+		return nil, check.Error
+	}
+	if key.Revoked {
+		return nil, errors.New("api key has been revoked")
+	}
+	if key.ExpiresAt != nil && key.ExpiresAt.Before(time.Now()) {
+		return nil, errors.New("api key has expired")
+	}
+	return key, nil
+}
+
+// FindAPIKeysByUser is used to find all the api keys of a user
+func (akm *APIKeyManager) FindAPIKeysByUser(ethAddress string) ([]APIKey, error) {
+	var keys []APIKey
+	if check := akm.DB.Where("eth_address = ?", ethAddress).Find(&keys); check.Error != nil {
+		return nil, check.Error
+	}
+	return keys, nil
+}
+
+// FindAPIKeyByID is used to find an api key belonging to a user, whether or not it is still valid
+func (akm *APIKeyManager) FindAPIKeyByID(ethAddress string, id uint) (*APIKey, error) {
+	key := &APIKey{}
+	if check := akm.DB.Where("id = ? AND eth_address = ?", id, ethAddress).First(key); check.Error != nil {
+		return nil, check.Error
+	}
+	return key, nil
+}
+
+// RevokeAPIKey is used to revoke an api key belonging to a user
+func (akm *APIKeyManager) RevokeAPIKey(ethAddress string, id uint) error {
+	key := &APIKey{}
+	if check := akm.DB.Where("id = ? AND eth_address = ?", id, ethAddress).First(key); check.Error != nil {
+		return check.Error
+	}
+	key.Revoked = true
+	return akm.DB.Save(key).Error
+}
+
+// UpdateLastUsed is used to record that an api key has just been used
+func (akm *APIKeyManager) UpdateLastUsed(key *APIKey) error {
+	now := time.Now()
+	key.LastUsedAt = &now
+	return akm.DB.Model(key).Update("last_used_at", now).Error
+}
+
+// HasScope is used to check whether or not the key was granted the given scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, v := range k.Scopes {
+		if v == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows is used to check whether or not the key may make a request needing the given scope.
+// Read requests are also allowed for keys granted the read-only scope
+func (k *APIKey) Allows(scope, method string) bool {
+	if k.HasScope(scope) {
+		return true
+	}
+	return (method == "GET" || method == "HEAD") && k.HasScope(APIKeyScopeReadOnly)
+}
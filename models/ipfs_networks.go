@@ -82,12 +82,11 @@ func (im *IPFSNetworkManager) CreateHostedPrivateNetwork(name, apiURL, swarmKey
 		}
 		pnet.LocalNodePeerIDs = append(pnet.LocalNodePeerIDs, parsedNPeerID)
 	}
-	if len(users) > 0 {
-		for _, v := range users {
-			pnet.Users = append(pnet.Users, v)
-		}
-	} else {
-		pnet.Users = append(pnet.Users, AdminAddress)
+	if len(users) == 0 {
+		return nil, errors.New("at least one user must be given access to the network")
+	}
+	for _, v := range users {
+		pnet.Users = append(pnet.Users, v)
 	}
 
 	pnet.Name = name
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"github.com/RTradeLtd/Temporal/utils"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// JobStateQueued indicates the job has been sent to the queue
+	JobStateQueued = "queued"
+	// JobStateRunning indicates a worker is processing the job
+	JobStateRunning = "running"
+	// JobStateSucceeded indicates the job completed successfully
+	JobStateSucceeded = "succeeded"
+	// JobStateFailed indicates the job will not be completed
+	JobStateFailed = "failed"
+)
+
+const (
+	// JobTypeIPFSPin is used for ipfs pin jobs
+	JobTypeIPFSPin = "ipfs-pin"
+	// JobTypeIPFSFile is used for ipfs file add jobs
+	JobTypeIPFSFile = "ipfs-file"
+	// JobTypeIPNSEntry is used for ipns publish jobs
+	JobTypeIPNSEntry = "ipns-entry"
+	// JobTypeIPFSPinRemoval is used for jobs releasing a user's pin of content
+	JobTypeIPFSPinRemoval = "ipfs-pin-removal"
+)
+
+// Job tracks the state of an operation that has been sent to the queue
+type Job struct {
+	gorm.Model
+	JobID      string `gorm:"type:varchar(255);unique;not null" json:"job_id"`
+	EthAddress string `gorm:"type:varchar(255);not null" json:"eth_address"`
+	Type       string `gorm:"type:varchar(255);not null" json:"type"`
+	State      string `gorm:"type:varchar(255);not null" json:"state"`
+	// Result holds the outcome of a successful job, such as the content hash of an added file
+	Result string `gorm:"type:text" json:"result"`
+	// Error holds the reason a job failed
+	Error string `gorm:"type:text" json:"error"`
+}
+
+// JobManager is used to manipulate jobs in the database
+type JobManager struct {
+	DB *gorm.DB
+}
+
+// NewJobManager is used to generate our job manager
+func NewJobManager(db *gorm.DB) *JobManager {
+	return &JobManager{DB: db}
+}
+
+// NewJob is used to create a job in the queued state
+func (jm *JobManager) NewJob(ethAddress, jobType string) (*Job, error) {
+	jobID, err := utils.GenerateSecureToken(16)
+	if err != nil {
+		return nil, err
+	}
+	job := Job{
+		JobID:      jobID,
+		EthAddress: ethAddress,
+		Type:       jobType,
+		State:      JobStateQueued,
+	}
+	if check := jm.DB.Create(&job); check.Error != nil {
+		return nil, check.Error
+	}
+	return &job, nil
+}
+
+// FindByJobID is used to find a job belonging to the given user
+func (jm *JobManager) FindByJobID(ethAddress, jobID string) (*Job, error) {
+	job := Job{}
+	if check := jm.DB.Where("job_id = ? AND eth_address = ?", jobID, ethAddress).First(&job); check.Error != nil {
+		return nil, check.Error
+	}
+	return &job, nil
+}
+
+// FindJobsByUser is used to list all jobs for a user, most recent first
+func (jm *JobManager) FindJobsByUser(ethAddress string) ([]Job, error) {
+	var jobs []Job
+	if check := jm.DB.Where("eth_address = ?", ethAddress).Order("created_at desc").Find(&jobs); check.Error != nil {
+		return nil, check.Error
+	}
+	return jobs, nil
+}
+
+// UpdateJobState is used to update the state of a job. The error message
+// is only stored when the job has failed, or is being retried
+func (jm *JobManager) UpdateJobState(jobID, state, errMsg string) error {
+	check := jm.DB.Model(&Job{}).Where("job_id = ?", jobID).Updates(map[string]interface{}{
+		"state": state,
+		"error": errMsg,
+	})
+	return check.Error
+}
+
+// CompleteJob is used to mark a job as succeeded, storing its result
+func (jm *JobManager) CompleteJob(jobID, result string) error {
+	check := jm.DB.Model(&Job{}).Where("job_id = ?", jobID).Updates(map[string]interface{}{
+		"state":  JobStateSucceeded,
+		"result": result,
+		"error":  "",
+	})
+	return check.Error
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/utils"
+	"github.com/jinzhu/gorm"
+)
+
+// LoginChallengeTimeout is how long a user has to sign a login challenge
+var LoginChallengeTimeout = time.Minute * 10
+
+// LoginChallenge is a message a user signs with their wallet to log in. Each challenge can only be used once
+type LoginChallenge struct {
+	gorm.Model
+	EthAddress string    `gorm:"type:varchar(255);not null;index" json:"eth_address"`
+	Nonce      string    `gorm:"type:varchar(255);not null;unique_index" json:"nonce"`
+	Message    string    `gorm:"type:text;not null" json:"message"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Used       bool      `json:"-"`
+}
+
+// LoginChallengeManager is used to manipulate login challenges in the database
+type LoginChallengeManager struct {
+	DB *gorm.DB
+}
+
+// NewLoginChallengeManager is used to generate our login challenge manager
+func NewLoginChallengeManager(db *gorm.DB) *LoginChallengeManager {
+	return &LoginChallengeManager{DB: db}
+}
+
+// NewLoginChallenge is used to create a challenge for the user to sign
+func (lm *LoginChallengeManager) NewLoginChallenge(ethAddress string) (*LoginChallenge, error) {
+	nonce, err := utils.GenerateSecureToken(16)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	challenge := LoginChallenge{
+		EthAddress: ethAddress,
+		Nonce:      nonce,
+		Message: fmt.Sprintf("Sign in to Temporal\n\nAddress: %s\nNonce: %s\nIssued At: %s",
+			ethAddress, nonce, now.UTC().Format(time.RFC3339)),
+		ExpiresAt: now.Add(LoginChallengeTimeout),
+	}
+	if check := lm.DB.Create(&challenge); check.Error != nil {
+		return nil, check.Error
+	}
+	return &challenge, nil
+}
+
+// UseLoginChallenge is used to find an unused, unexpired challenge, and mark it as used so it can't be replayed
+func (lm *LoginChallengeManager) UseLoginChallenge(ethAddress, nonce string) (*LoginChallenge, error) {
+	challenge := &LoginChallenge{}
+	if check := lm.DB.Where("eth_address = ? AND nonce = ?", ethAddress, nonce).First(challenge); check.Error != nil {
+		return nil, errors.New("login challenge not found")
+	}
+	if challenge.ExpiresAt.Before(time.Now()) {
+		return nil, errors.New("login challenge has expired")
+	}
+	// only one request can flip the challenge to used, so concurrent logins with the same signature fail
+	check := lm.DB.Model(challenge).Where("used = ?", false).Update("used", true)
+	if check.Error != nil {
+		return nil, check.Error
+	}
+	if check.RowsAffected == 0 {
+		return nil, errors.New("login challenge has already been used")
+	}
+	return challenge, nil
+}
+
+// RemoveExpiredLoginChallenges is used to remove challenges which can no longer be used
+func (lm *LoginChallengeManager) RemoveExpiredLoginChallenges() error {
+	return lm.DB.Unscoped().Where("expires_at < ? OR used = ?", time.Now(), true).Delete(&LoginChallenge{}).Error
+}
@@ -0,0 +1,171 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ThrottlePolicy is used to decide how failed attempts are throttled. Once the free attempts are used,
+// each attempt must wait twice as long as the last, up to the max delay, and reaching the lockout
+// threshold locks the key out. Failures are forgotten once there have been none for the reset period
+type ThrottlePolicy struct {
+	FreeAttempts     int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	ResetAfter       time.Duration
+}
+
+var (
+	// AccountLoginPolicy throttles failed logins to an account
+	AccountLoginPolicy = ThrottlePolicy{
+		FreeAttempts:     3,
+		BaseDelay:        time.Second,
+		MaxDelay:         time.Minute,
+		LockoutThreshold: 10,
+		LockoutDuration:  time.Minute * 30,
+		ResetAfter:       time.Hour,
+	}
+	// IPLoginPolicy throttles failed logins from an ip address, which may be trying many accounts
+	IPLoginPolicy = ThrottlePolicy{
+		FreeAttempts:     10,
+		BaseDelay:        time.Second,
+		MaxDelay:         time.Minute,
+		LockoutThreshold: 50,
+		LockoutDuration:  time.Minute * 30,
+		ResetAfter:       time.Hour,
+	}
+	// RegistrationPolicy throttles registrations from an ip address, every registration counting as an attempt
+	RegistrationPolicy = ThrottlePolicy{
+		FreeAttempts:     5,
+		BaseDelay:        time.Second * 10,
+		MaxDelay:         time.Minute * 10,
+		LockoutThreshold: 20,
+		LockoutDuration:  time.Hour * 24,
+		ResetAfter:       time.Hour * 24,
+	}
+)
+
+// LoginThrottle holds the failed attempts made against a key, such as an account or ip address
+type LoginThrottle struct {
+	gorm.Model
+	ThrottleKey   string    `gorm:"type:varchar(255);not null;unique_index" json:"throttle_key"`
+	Failures      int       `json:"failures"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+	LockedUntil   time.Time `json:"locked_until"`
+}
+
+// LoginThrottleManager is used to manipulate login throttles in the database
+type LoginThrottleManager struct {
+	DB *gorm.DB
+}
+
+// NewLoginThrottleManager is used to generate our login throttle manager
+func NewLoginThrottleManager(db *gorm.DB) *LoginThrottleManager {
+	return &LoginThrottleManager{DB: db}
+}
+
+// AccountThrottleKey is used to generate the throttle key of the logins to an account
+func AccountThrottleKey(ethAddress string) string {
+	return "account:" + strings.ToLower(ethAddress)
+}
+
+// IPThrottleKey is used to generate the throttle key of the logins from an ip address
+func IPThrottleKey(ipAddress string) string {
+	return "ip:" + ipAddress
+}
+
+// RegistrationThrottleKey is used to generate the throttle key of the registrations from an ip address
+func RegistrationThrottleKey(ipAddress string) string {
+	return "register:" + ipAddress
+}
+
+// ReserveAttempt is used to count an attempt against the key before it is made, returning how long must be waited
+// instead when the key is throttled, along with whether or not the key is locked out. The throttle is locked while
+// the attempt is counted, so concurrent attempts are counted one after the other, and wait on each other's delays
+func (ltm *LoginThrottleManager) ReserveAttempt(key string, policy ThrottlePolicy) (time.Duration, bool, error) {
+	check := ltm.DB.Where("throttle_key = ?", key).First(&LoginThrottle{})
+	if check.RecordNotFound() {
+		// another request may create the throttle first, in which case theirs is used
+		if check = ltm.DB.Create(&LoginThrottle{ThrottleKey: key}); check.Error != nil && !isUniqueViolation(check.Error) {
+			return 0, false, check.Error
+		}
+	} else if check.Error != nil {
+		return 0, false, check.Error
+	}
+	tx := ltm.DB.Begin()
+	throttle := &LoginThrottle{}
+	if check = tx.Set("gorm:query_option", "FOR UPDATE").Where("throttle_key = ?", key).First(throttle); check.Error != nil {
+		tx.Rollback()
+		return 0, false, check.Error
+	}
+	now := time.Now()
+	if throttle.LockedUntil.After(now) {
+		tx.Rollback()
+		return throttle.LockedUntil.Sub(now), true, nil
+	}
+	updates := map[string]interface{}{"failures": throttle.Failures + 1, "last_failure_at": now}
+	if throttle.stale(policy, now) {
+		updates["failures"] = 1
+		updates["locked_until"] = time.Time{}
+	} else if wait := throttle.LastFailureAt.Add(policy.delay(throttle.Failures)).Sub(now); wait > 0 {
+		tx.Rollback()
+		return wait, false, nil
+	}
+	if check = tx.Model(throttle).Updates(updates); check.Error != nil {
+		tx.Rollback()
+		return 0, false, check.Error
+	}
+	return 0, false, tx.Commit().Error
+}
+
+// RecordFailure is used to record that an attempt reserved against the key failed, locking the key out once the
+// lockout threshold is reached. Returns true when the failure locked the key out, which only one of any concurrent failures does
+func (ltm *LoginThrottleManager) RecordFailure(key string, policy ThrottlePolicy) (bool, error) {
+	now := time.Now()
+	check := ltm.DB.Model(&LoginThrottle{}).
+		Where("throttle_key = ? AND failures >= ? AND locked_until < ?", key, policy.LockoutThreshold, now).
+		Update("locked_until", now.Add(policy.LockoutDuration))
+	if check.Error != nil {
+		return false, check.Error
+	}
+	return check.RowsAffected > 0, nil
+}
+
+// ReleaseAttempt is used to stop counting an attempt reserved against the key, once it has succeeded
+func (ltm *LoginThrottleManager) ReleaseAttempt(key string) error {
+	return ltm.DB.Model(&LoginThrottle{}).Where("throttle_key = ? AND failures > 0", key).
+		Update("failures", gorm.Expr("failures - 1")).Error
+}
+
+// ResetThrottle is used to forget the failed attempts against a key, unlocking it
+func (ltm *LoginThrottleManager) ResetThrottle(key string) error {
+	return ltm.DB.Unscoped().Where("throttle_key = ?", key).Delete(LoginThrottle{}).Error
+}
+
+// stale is used to check whether the failures should be forgotten, either because there have been none
+// for the reset period, or because the key was locked out and the lockout has ended
+func (lt *LoginThrottle) stale(policy ThrottlePolicy, now time.Time) bool {
+	if now.Sub(lt.LastFailureAt) > policy.ResetAfter {
+		return true
+	}
+	return !lt.LockedUntil.IsZero() && !lt.LockedUntil.After(now)
+}
+
+// delay is used to calculate how long must be waited after the given number of failures
+func (policy ThrottlePolicy) delay(failures int) time.Duration {
+	if failures <= policy.FreeAttempts {
+		return 0
+	}
+	delay := policy.BaseDelay
+	for i := policy.FreeAttempts + 1; i < failures; i++ {
+		delay *= 2
+		if delay >= policy.MaxDelay {
+			return policy.MaxDelay
+		}
+	}
+	return delay
+}
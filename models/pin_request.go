@@ -0,0 +1,151 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/utils"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+const (
+	// PinStatusQueued indicates the pin request is waiting to be processed
+	PinStatusQueued = "queued"
+	// PinStatusPinning indicates the pin request is being processed
+	PinStatusPinning = "pinning"
+	// PinStatusPinned indicates the content has been pinned
+	PinStatusPinned = "pinned"
+	// PinStatusFailed indicates the pin request could not be processed
+	PinStatusFailed = "failed"
+)
+
+// PinRequest is a pin request submitted through the IPFS pinning service api.
+// It tracks the status of an individual pin, separately from the Upload model
+// which is shared between all users who have pinned the same content
+type PinRequest struct {
+	gorm.Model
+	RequestID        string         `gorm:"type:varchar(255);unique;not null" json:"request_id"`
+	EthAddress       string         `gorm:"type:varchar(255);not null" json:"eth_address"`
+	CID              string         `gorm:"type:varchar(255);not null;column:cid" json:"cid"`
+	Name             string         `gorm:"type:varchar(255)" json:"name"`
+	Origins          pq.StringArray `gorm:"type:text[]" json:"origins"`
+	Meta             string         `gorm:"type:text" json:"meta"`
+	Status           string         `gorm:"type:varchar(255);not null" json:"status"`
+	NetworkName      string         `gorm:"type:varchar(255)" json:"network_name"`
+	HoldTimeInMonths int64          `gorm:"type:integer;not null" json:"hold_time_in_months"`
+}
+
+// PinRequestFilter holds the optional filters used when listing pin requests
+type PinRequestFilter struct {
+	CIDs     []string
+	Name     string
+	Statuses []string
+	Before   time.Time
+	After    time.Time
+	Limit    int
+}
+
+// PinRequestManager is used to manipulate pin requests in the database
+type PinRequestManager struct {
+	DB *gorm.DB
+}
+
+// NewPinRequestManager is used to generate our pin request manager
+func NewPinRequestManager(db *gorm.DB) *PinRequestManager {
+	return &PinRequestManager{DB: db}
+}
+
+// NewPinRequest is used to store a new pin request in the queued state
+func (pm *PinRequestManager) NewPinRequest(ethAddress, cid, name, networkName string, origins []string, meta map[string]string, holdTimeInMonths int64) (*PinRequest, error) {
+	requestID, err := utils.GenerateSecureToken(16)
+	if err != nil {
+		return nil, err
+	}
+	metaMarshaled, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+	pr := PinRequest{
+		RequestID:        requestID,
+		EthAddress:       ethAddress,
+		CID:              cid,
+		Name:             name,
+		Origins:          origins,
+		Meta:             string(metaMarshaled),
+		Status:           PinStatusQueued,
+		NetworkName:      networkName,
+		HoldTimeInMonths: holdTimeInMonths,
+	}
+	if check := pm.DB.Create(&pr); check.Error != nil {
+		return nil, check.Error
+	}
+	return &pr, nil
+}
+
+// FindByRequestID is used to find a pin request owned by the given user
+func (pm *PinRequestManager) FindByRequestID(ethAddress, requestID string) (*PinRequest, error) {
+	pr := PinRequest{}
+	if check := pm.DB.Where("request_id = ? AND eth_address = ?", requestID, ethAddress).First(&pr); check.Error != nil {
+		return nil, check.Error
+	}
+	return &pr, nil
+}
+
+// FindPinRequests is used to list the pin requests of a user matching the given filter.
+// It returns the total number of matching requests, along with at most filter.Limit requests
+func (pm *PinRequestManager) FindPinRequests(ethAddress string, filter PinRequestFilter) (int, []PinRequest, error) {
+	query := pm.DB.Model(&PinRequest{}).Where("eth_address = ?", ethAddress)
+	if len(filter.CIDs) > 0 {
+		query = query.Where("cid IN (?)", filter.CIDs)
+	}
+	if filter.Name != "" {
+		query = query.Where("name = ?", filter.Name)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN (?)", filter.Statuses)
+	}
+	if filter.Before != utils.NilTime {
+		query = query.Where("created_at < ?", filter.Before)
+	}
+	if filter.After != utils.NilTime {
+		query = query.Where("created_at > ?", filter.After)
+	}
+	var count int
+	if check := query.Count(&count); check.Error != nil {
+		return 0, nil, check.Error
+	}
+	var requests []PinRequest
+	if check := query.Order("created_at desc").Limit(filter.Limit).Find(&requests); check.Error != nil {
+		return 0, nil, check.Error
+	}
+	return count, requests, nil
+}
+
+// UpdatePinRequestStatus is used to update the status of all outstanding pin requests
+// a user has made for a content hash on the given network
+func (pm *PinRequestManager) UpdatePinRequestStatus(ethAddress, cid, networkName, status string) error {
+	check := pm.DB.Model(&PinRequest{}).Where(
+		"eth_address = ? AND cid = ? AND network_name = ? AND status <> ?",
+		ethAddress, cid, networkName, PinStatusPinned,
+	).Update("status", status)
+	return check.Error
+}
+
+// DeletePinRequest is used to remove a pin request
+func (pm *PinRequestManager) DeletePinRequest(pr *PinRequest) error {
+	return pm.DB.Delete(pr).Error
+}
+
+// GetMeta is used to unmarshal the meta data attached to a pin request
+func (pr *PinRequest) GetMeta() map[string]string {
+	meta := make(map[string]string)
+	if pr.Meta == "" {
+		return meta
+	}
+	// a failure here means there is no usable meta data, so we return an empty map
+	if err := json.Unmarshal([]byte(pr.Meta), &meta); err != nil {
+		return make(map[string]string)
+	}
+	return meta
+}
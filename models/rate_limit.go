@@ -0,0 +1,112 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// RateLimitClassUpload is the rate limit class of routes adding files
+	RateLimitClassUpload = "upload"
+	// RateLimitClassPin is the rate limit class of routes pinning content
+	RateLimitClassPin = "pin"
+	// RateLimitClassIPNS is the rate limit class of routes publishing ipns records
+	RateLimitClassIPNS = "ipns"
+	// RateLimitClassRead is the rate limit class of every other route
+	RateLimitClassRead = "read"
+)
+
+// RateLimit is a token bucket, refilled at Rate tokens a minute and holding at most Burst tokens
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitResult is the state of a bucket once a token has been taken from it, or not
+type RateLimitResult struct {
+	Allowed bool
+	// Remaining is the number of whole tokens left in the bucket
+	Remaining int
+	// RetryAfter is how long until a token can be taken, when none could be
+	RetryAfter time.Duration
+	// ResetAfter is how long until the bucket is full again
+	ResetAfter time.Duration
+}
+
+// RateLimitBucket holds the tokens of a bucket, so that every api instance shares the same limits
+type RateLimitBucket struct {
+	gorm.Model
+	BucketKey  string    `gorm:"type:varchar(255);not null;unique_index" json:"bucket_key"`
+	Tokens     float64   `json:"tokens"`
+	RefilledAt time.Time `json:"refilled_at"`
+}
+
+// RateLimitManager is used to manipulate rate limit buckets in the database
+type RateLimitManager struct {
+	DB *gorm.DB
+}
+
+// NewRateLimitManager is used to generate our rate limit manager
+func NewRateLimitManager(db *gorm.DB) *RateLimitManager {
+	return &RateLimitManager{DB: db}
+}
+
+// RateLimitBucketKey is used to generate the key of the bucket of a user for a class of routes
+func RateLimitBucketKey(ethAddress, class string) string {
+	return "user:" + strings.ToLower(ethAddress) + ":" + class
+}
+
+// TakeToken is used to take a token from the bucket, refilling it for the time since it was last refilled.
+// The bucket is locked while the token is taken, so concurrent requests take their tokens one after the other
+func (rlm *RateLimitManager) TakeToken(key string, limit RateLimit) (*RateLimitResult, error) {
+	if limit.Rate <= 0 || limit.Burst <= 0 {
+		return nil, errors.New("rate limits must have a positive rate and burst")
+	}
+	perSecond := limit.Rate / 60
+	check := rlm.DB.Where("bucket_key = ?", key).First(&RateLimitBucket{})
+	if check.RecordNotFound() {
+		// another request may create the bucket first, in which case theirs is used
+		bucket := &RateLimitBucket{BucketKey: key, Tokens: float64(limit.Burst), RefilledAt: time.Now()}
+		if check = rlm.DB.Create(bucket); check.Error != nil && !isUniqueViolation(check.Error) {
+			return nil, check.Error
+		}
+	} else if check.Error != nil {
+		return nil, check.Error
+	}
+	tx := rlm.DB.Begin()
+	bucket := &RateLimitBucket{}
+	if check = tx.Set("gorm:query_option", "FOR UPDATE").Where("bucket_key = ?", key).First(bucket); check.Error != nil {
+		tx.Rollback()
+		return nil, check.Error
+	}
+	now := time.Now()
+	tokens := math.Min(float64(limit.Burst), bucket.Tokens+now.Sub(bucket.RefilledAt).Seconds()*perSecond)
+	if tokens < 1 {
+		tx.Rollback()
+		return limit.result(false, tokens, perSecond), nil
+	}
+	if check = tx.Model(bucket).Updates(map[string]interface{}{"tokens": tokens - 1, "refilled_at": now}); check.Error != nil {
+		tx.Rollback()
+		return nil, check.Error
+	}
+	if check = tx.Commit(); check.Error != nil {
+		return nil, check.Error
+	}
+	return limit.result(true, tokens-1, perSecond), nil
+}
+
+func (limit RateLimit) result(allowed bool, tokens, perSecond float64) *RateLimitResult {
+	result := &RateLimitResult{
+		Allowed:    allowed,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: time.Duration((float64(limit.Burst) - tokens) / perSecond * float64(time.Second)),
+	}
+	if !allowed {
+		result.RetryAfter = time.Duration((1 - tokens) / perSecond * float64(time.Second))
+	}
+	return result
+}
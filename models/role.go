@@ -0,0 +1,151 @@
+package models
+
+import (
+	"errors"
+)
+
+const (
+	// RoleAdmin is granted every permission
+	RoleAdmin = "admin"
+	// RoleOperator is used to run the nodes, networks and queues of an instance
+	RoleOperator = "operator"
+	// RoleSupport is used to look into the uploads of users, and the health of an instance
+	RoleSupport = "support"
+)
+
+const (
+	// PermissionManageIPFS allows use of the local ipfs node, such as listing its pins and using pubsub
+	PermissionManageIPFS = "manage-ipfs"
+	// PermissionManageCluster allows inspecting and syncing the ipfs cluster
+	PermissionManageCluster = "manage-cluster"
+	// PermissionManagePrivateNetworks allows creating, inspecting and removing pins from private networks
+	PermissionManagePrivateNetworks = "manage-private-networks"
+	// PermissionViewUploads allows viewing the uploads of every user
+	PermissionViewUploads = "view-uploads"
+	// PermissionManageRetention allows running the retention worker
+	PermissionManageRetention = "manage-retention"
+	// PermissionManageQueues allows inspecting, replaying and purging dead letter queues
+	PermissionManageQueues = "manage-queues"
+	// PermissionManageStorage allows creating minio buckets
+	PermissionManageStorage = "manage-storage"
+	// PermissionManageDNS allows creating dnslink entries
+	PermissionManageDNS = "manage-dns"
+	// PermissionViewStatistics allows viewing the request statistics of the api
+	PermissionViewStatistics = "view-statistics"
+	// PermissionManageRoles allows granting and revoking roles
+	PermissionManageRoles = "manage-roles"
+	// PermissionManageUsers allows unlocking accounts locked out by failed logins
+	PermissionManageUsers = "manage-users"
+)
+
+// RolePermissions is the permissions granted by each role
+var RolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermissionManageIPFS,
+		PermissionManageCluster,
+		PermissionManagePrivateNetworks,
+		PermissionViewUploads,
+		PermissionManageRetention,
+		PermissionManageQueues,
+		PermissionManageStorage,
+		PermissionManageDNS,
+		PermissionViewStatistics,
+		PermissionManageRoles,
+		PermissionManageUsers,
+	},
+	RoleOperator: {
+		PermissionManageIPFS,
+		PermissionManageCluster,
+		PermissionManagePrivateNetworks,
+		PermissionViewUploads,
+		PermissionManageRetention,
+		PermissionManageQueues,
+		PermissionViewStatistics,
+	},
+	RoleSupport: {
+		PermissionViewUploads,
+		PermissionViewStatistics,
+		PermissionManageUsers,
+	},
+}
+
+// IsValidRole is used to check whether or not the given role exists
+func IsValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// HasRole is used to check whether or not the user has been granted the given role
+func (u *User) HasRole(role string) bool {
+	for _, v := range u.Roles {
+		if v == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission is used to check whether or not any of the roles of the user grant the given permission
+func (u *User) HasPermission(permission string) bool {
+	for _, role := range u.Roles {
+		for _, v := range RolePermissions[role] {
+			if v == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CheckIfUserHasPermission is used to check whether or not a user has been granted the given permission
+func (um *UserManager) CheckIfUserHasPermission(ethAddress, permission string) (bool, error) {
+	u := &User{}
+	if check := um.DB.Where("eth_address = ?", ethAddress).First(u); check.Error != nil {
+		return false, check.Error
+	}
+	return u.HasPermission(permission), nil
+}
+
+// GrantRole is used to grant a role to a user
+func (um *UserManager) GrantRole(ethAddress, role string) error {
+	if !IsValidRole(role) {
+		return errors.New("invalid role " + role)
+	}
+	u := &User{}
+	if check := um.DB.Where("eth_address = ?", ethAddress).First(u); check.Error != nil {
+		return check.Error
+	}
+	if u.HasRole(role) {
+		return nil
+	}
+	u.Roles = append(u.Roles, role)
+	return um.DB.Model(u).Update("roles", u.Roles).Error
+}
+
+// RevokeRole is used to revoke a role from a user
+func (um *UserManager) RevokeRole(ethAddress, role string) error {
+	u := &User{}
+	if check := um.DB.Where("eth_address = ?", ethAddress).First(u); check.Error != nil {
+		return check.Error
+	}
+	if !u.HasRole(role) {
+		return errors.New("user does not have role " + role)
+	}
+	var roles []string
+	for _, v := range u.Roles {
+		if v != role {
+			roles = append(roles, v)
+		}
+	}
+	u.Roles = roles
+	return um.DB.Model(u).Update("roles", u.Roles).Error
+}
+
+// FindUsersWithRoles is used to find every user who has been granted at least one role
+func (um *UserManager) FindUsersWithRoles() ([]User, error) {
+	var users []User
+	if check := um.DB.Where("array_length(roles, 1) > 0").Find(&users); check.Error != nil {
+		return nil, check.Error
+	}
+	return users, nil
+}
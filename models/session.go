@@ -0,0 +1,179 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/utils"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// RefreshTokenTimeout is how long a refresh token can be used for, each refresh issuing a new one
+	RefreshTokenTimeout = time.Hour * 24 * 7
+	// SessionTimeout is the longest a session can be kept alive by refreshing, before the user must log in again
+	SessionTimeout = time.Hour * 24 * 30
+	// sessionLastUsedInterval limits how often the last use of a session is written to the database
+	sessionLastUsedInterval = time.Minute
+)
+
+// Session is created whenever a user logs in. The access tokens, and refresh token, of a session
+// stop working as soon as it is revoked. Only the hash of the refresh token is stored
+type Session struct {
+	gorm.Model
+	SessionID        string     `gorm:"type:varchar(255);not null;unique_index" json:"session_id"`
+	EthAddress       string     `gorm:"type:varchar(255);not null;index" json:"eth_address"`
+	RefreshTokenHash string     `gorm:"type:varchar(255);not null" json:"-"`
+	UserAgent        string     `gorm:"type:text" json:"user_agent"`
+	IPAddress        string     `gorm:"type:varchar(255)" json:"ip_address"`
+	LastUsedAt       time.Time  `json:"last_used_at"`
+	RefreshExpiresAt time.Time  `json:"refresh_expires_at"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	RevokedAt        *time.Time `json:"revoked_at"`
+}
+
+// SessionManager is used to manipulate sessions in the database
+type SessionManager struct {
+	DB *gorm.DB
+}
+
+// NewSessionManager is used to generate our session manager
+func NewSessionManager(db *gorm.DB) *SessionManager {
+	return &SessionManager{DB: db}
+}
+
+// Active is used to check whether or not the session can still be used
+func (s *Session) Active() bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(time.Now())
+}
+
+// NewSession is used to create a session for a user who has just logged in, returning its refresh token
+func (sm *SessionManager) NewSession(ethAddress, userAgent, ipAddress string) (*Session, string, error) {
+	sessionID, err := utils.GenerateSecureToken(16)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	session := Session{
+		SessionID:        sessionID,
+		EthAddress:       ethAddress,
+		RefreshTokenHash: hashToken(secret),
+		UserAgent:        userAgent,
+		IPAddress:        ipAddress,
+		LastUsedAt:       now,
+		RefreshExpiresAt: now.Add(RefreshTokenTimeout),
+		ExpiresAt:        now.Add(SessionTimeout),
+	}
+	if check := sm.DB.Create(&session); check.Error != nil {
+		return nil, "", check.Error
+	}
+	return &session, refreshToken(sessionID, secret), nil
+}
+
+// RotateRefreshToken is used to exchange a refresh token for a new one. Each refresh token can only be used once,
+// and using one which has already been exchanged revokes the session, as the token must have been stolen
+func (sm *SessionManager) RotateRefreshToken(token string) (*Session, string, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return nil, "", errors.New("invalid refresh token")
+	}
+	session, err := sm.FindActiveSession(parts[0])
+	if err != nil {
+		return nil, "", err
+	}
+	if session.RefreshTokenHash != hashToken(parts[1]) {
+		if err = sm.RevokeSession(session.EthAddress, session.SessionID); err != nil {
+			return nil, "", err
+		}
+		return nil, "", errors.New("refresh token has already been used, the session has been revoked")
+	}
+	if session.RefreshExpiresAt.Before(time.Now()) {
+		return nil, "", errors.New("refresh token has expired")
+	}
+	secret, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	refreshExpiresAt := now.Add(RefreshTokenTimeout)
+	if refreshExpiresAt.After(session.ExpiresAt) {
+		refreshExpiresAt = session.ExpiresAt
+	}
+	// only one request can exchange the current refresh token
+	check := sm.DB.Model(session).Where("refresh_token_hash = ?", session.RefreshTokenHash).Updates(map[string]interface{}{
+		"refresh_token_hash": hashToken(secret),
+		"refresh_expires_at": refreshExpiresAt,
+		"last_used_at":       now,
+	})
+	if check.Error != nil {
+		return nil, "", check.Error
+	}
+	if check.RowsAffected == 0 {
+		return nil, "", errors.New("refresh token has already been used")
+	}
+	session.RefreshTokenHash = hashToken(secret)
+	session.RefreshExpiresAt = refreshExpiresAt
+	session.LastUsedAt = now
+	return session, refreshToken(session.SessionID, secret), nil
+}
+
+// FindActiveSession is used to find a session which hasn't been revoked or expired
+func (sm *SessionManager) FindActiveSession(sessionID string) (*Session, error) {
+	session := &Session{}
+	if check := sm.DB.Where("session_id = ?", sessionID).First(session); check.Error != nil {
+		return nil, errors.New("session not found")
+	}
+	if !session.Active() {
+		return nil, errors.New("session has been revoked or has expired")
+	}
+	return session, nil
+}
+
+// FindActiveSessionsByUser is used to find the sessions of a user which haven't been revoked or expired
+func (sm *SessionManager) FindActiveSessionsByUser(ethAddress string) ([]Session, error) {
+	var sessions []Session
+	if check := sm.DB.Where("eth_address = ? AND revoked_at IS NULL AND expires_at > ?", ethAddress, time.Now()).
+		Order("last_used_at desc").Find(&sessions); check.Error != nil {
+		return nil, check.Error
+	}
+	return sessions, nil
+}
+
+// UpdateLastUsed is used to record that a session has been used, at most once per minute
+func (sm *SessionManager) UpdateLastUsed(session *Session) error {
+	now := time.Now()
+	if now.Sub(session.LastUsedAt) < sessionLastUsedInterval {
+		return nil
+	}
+	session.LastUsedAt = now
+	return sm.DB.Model(session).Update("last_used_at", now).Error
+}
+
+// RevokeSession is used to revoke a session belonging to a user
+func (sm *SessionManager) RevokeSession(ethAddress, sessionID string) error {
+	check := sm.DB.Model(&Session{}).Where("eth_address = ? AND session_id = ? AND revoked_at IS NULL", ethAddress, sessionID).
+		Update("revoked_at", time.Now())
+	if check.Error != nil {
+		return check.Error
+	}
+	if check.RowsAffected == 0 {
+		return errors.New("session not found")
+	}
+	return nil
+}
+
+// RevokeAllSessions is used to revoke every session of a user, other than the one given, which may be empty
+func (sm *SessionManager) RevokeAllSessions(ethAddress, exceptSessionID string) error {
+	return sm.DB.Model(&Session{}).Where("eth_address = ? AND session_id != ? AND revoked_at IS NULL", ethAddress, exceptSessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// refreshToken is used to join the id of a session to its secret, forming the refresh token given to the user
+func refreshToken(sessionID, secret string) string {
+	return sessionID + "." + secret
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/utils"
+	"github.com/jinzhu/gorm"
+)
+
+// SigningKey is an asymmetric key JWTs are signed with. Each key is published before it
+// activates, signs tokens until it retires, and verifies tokens until it expires, so that
+// services verifying our tokens have time to fetch the key, and tokens outlive the key that signed them.
+// The private key is stored encrypted, so that every api instance signs with the same keys
+type SigningKey struct {
+	gorm.Model
+	KeyID       string    `gorm:"type:varchar(255);not null;unique_index" json:"kid"`
+	Algorithm   string    `gorm:"type:varchar(255);not null" json:"algorithm"`
+	PrivateKey  string    `gorm:"type:text;not null" json:"-"`
+	PublicKey   string    `gorm:"type:text;not null" json:"public_key"`
+	ActivatesAt time.Time `json:"activates_at"`
+	RetiresAt   time.Time `json:"retires_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// signingKeyRotationLock is the postgres advisory lock held while rotating signing keys, so that
+// api instances rotating at the same time can't each publish a different replacement key
+const signingKeyRotationLock = 4729163
+
+// SigningKeyManager is used to manipulate signing keys in the database
+type SigningKeyManager struct {
+	DB *gorm.DB
+	// EncryptionKey is the secret private keys are encrypted with
+	EncryptionKey []byte
+}
+
+// NewSigningKeyManager is used to generate our signing key manager
+func NewSigningKeyManager(db *gorm.DB, encryptionKey []byte) *SigningKeyManager {
+	return &SigningKeyManager{DB: db, EncryptionKey: encryptionKey}
+}
+
+// RotateSigningKeys is used to make sure there is a key signing tokens with the given algorithm, and that its
+// replacement is published once the current key is within the overlap of retiring. Each key signs for the rotation
+// interval, and verifies tokens for the overlap after it retires. Expired keys are removed. Keys are rotated by
+// one api instance at a time, holding an advisory lock until the rotation is committed
+func (skm *SigningKeyManager) RotateSigningKeys(algorithm string, interval, overlap time.Duration) error {
+	if interval <= 0 || overlap <= 0 {
+		return errors.New("rotation interval and overlap must be positive")
+	}
+	tx := skm.DB.Begin()
+	if check := tx.Exec("SELECT pg_advisory_xact_lock(?)", signingKeyRotationLock); check.Error != nil {
+		tx.Rollback()
+		return check.Error
+	}
+	if err := skm.rotateSigningKeys(tx, algorithm, interval, overlap); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// rotateSigningKeys is used to rotate the signing keys within the transaction holding the rotation lock
+func (skm *SigningKeyManager) rotateSigningKeys(tx *gorm.DB, algorithm string, interval, overlap time.Duration) error {
+	now := time.Now()
+	if check := tx.Where("expires_at < ?", now).Delete(SigningKey{}); check.Error != nil {
+		return check.Error
+	}
+	if err := skm.encryptStoredKeys(tx); err != nil {
+		return err
+	}
+	latest := &SigningKey{}
+	check := tx.Where("algorithm = ? AND retires_at > ?", algorithm, now).Order("retires_at desc").First(latest)
+	if check.Error != nil && !check.RecordNotFound() {
+		return check.Error
+	}
+	if check.RecordNotFound() {
+		// nothing can sign tokens, so the first key activates immediately
+		return skm.newSigningKey(tx, algorithm, now, interval, overlap)
+	}
+	if latest.RetiresAt.Sub(now) > overlap {
+		return nil
+	}
+	return skm.newSigningKey(tx, algorithm, latest.RetiresAt, interval, overlap)
+}
+
+// DecryptPrivateKey is used to decrypt the PEM encoded private key of a signing key
+func (skm *SigningKeyManager) DecryptPrivateKey(key *SigningKey) (string, error) {
+	return utils.DecryptSigningKey(key.KeyID, key.PrivateKey, skm.EncryptionKey)
+}
+
+// FindSigningKey is used to find the key tokens are currently signed with
+func (skm *SigningKeyManager) FindSigningKey(algorithm string) (*SigningKey, error) {
+	key := &SigningKey{}
+	now := time.Now()
+	if check := skm.DB.Where("algorithm = ? AND activates_at <= ? AND retires_at > ?", algorithm, now, now).
+		Order("activates_at desc").First(key); check.Error != nil {
+		return nil, check.Error
+	}
+	return key, nil
+}
+
+// FindVerificationKeys is used to find every key tokens may be verified with, including those not yet signing tokens
+func (skm *SigningKeyManager) FindVerificationKeys() ([]SigningKey, error) {
+	var keys []SigningKey
+	if check := skm.DB.Where("expires_at > ?", time.Now()).Order("activates_at asc").Find(&keys); check.Error != nil {
+		return nil, check.Error
+	}
+	return keys, nil
+}
+
+// newSigningKey is used to generate a key which signs tokens from the given time
+func (skm *SigningKeyManager) newSigningKey(tx *gorm.DB, algorithm string, activatesAt time.Time, interval, overlap time.Duration) error {
+	keyID, err := utils.GenerateSecureToken(16)
+	if err != nil {
+		return err
+	}
+	privateKey, publicKey, err := utils.GenerateSigningKey(algorithm)
+	if err != nil {
+		return err
+	}
+	encryptedKey, err := utils.EncryptSigningKey(keyID, privateKey, skm.EncryptionKey)
+	if err != nil {
+		return err
+	}
+	key := SigningKey{
+		KeyID:       keyID,
+		Algorithm:   algorithm,
+		PrivateKey:  encryptedKey,
+		PublicKey:   publicKey,
+		ActivatesAt: activatesAt,
+		RetiresAt:   activatesAt.Add(interval),
+		ExpiresAt:   activatesAt.Add(interval + overlap),
+	}
+	return tx.Create(&key).Error
+}
+
+// encryptStoredKeys is used to encrypt private keys stored before they were encrypted
+func (skm *SigningKeyManager) encryptStoredKeys(tx *gorm.DB) error {
+	var keys []SigningKey
+	if check := tx.Where("private_key LIKE ?", "-----BEGIN%").Find(&keys); check.Error != nil {
+		return check.Error
+	}
+	for _, v := range keys {
+		encryptedKey, err := utils.EncryptSigningKey(v.KeyID, v.PrivateKey, skm.EncryptionKey)
+		if err != nil {
+			return err
+		}
+		if check := tx.Model(&v).Update("private_key", encryptedKey); check.Error != nil {
+			return check.Error
+		}
+	}
+	return nil
+}
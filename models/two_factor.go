@@ -0,0 +1,183 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/utils"
+	"github.com/lib/pq"
+)
+
+var (
+	// TwoFactorIssuer is the name authenticator apps show for our codes
+	TwoFactorIssuer = "Temporal"
+	// TwoFactorRecoveryCodeCount is the number of recovery codes given to a user
+	TwoFactorRecoveryCodeCount = 10
+	// ErrTwoFactorCodeRequired is returned when a user with two factor authentication enabled gives no code
+	ErrTwoFactorCodeRequired = errors.New("two factor code required")
+	// ErrInvalidTwoFactorCode is returned when a code is neither a valid totp code, nor an unused recovery code
+	ErrInvalidTwoFactorCode = errors.New("invalid two factor code")
+)
+
+// NeedsTwoFactorEnrollment is used to check whether or not the user must enable two factor authentication
+// before they can use their account, which is the case for enterprise accounts when it is required for them
+func (u *User) NeedsTwoFactorEnrollment(requireForEnterprise bool) bool {
+	return requireForEnterprise && u.EnterpriseEnabled && !u.TwoFactorEnabled
+}
+
+// EnrollTwoFactor is used to generate a new secret for a user, returning the secret and the uri to enroll an
+// authenticator app with. Two factor authentication is not enabled until a code is confirmed with ConfirmTwoFactor
+func (um *UserManager) EnrollTwoFactor(ethAddress string) (string, string, error) {
+	u := &User{}
+	if check := um.DB.Where("eth_address = ?", ethAddress).First(u); check.Error != nil {
+		return "", "", check.Error
+	}
+	if u.TwoFactorEnabled {
+		return "", "", errors.New("two factor authentication is already enabled")
+	}
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if check := um.DB.Model(u).Update("two_factor_secret", secret); check.Error != nil {
+		return "", "", check.Error
+	}
+	return secret, utils.TOTPURI(TwoFactorIssuer, ethAddress, secret), nil
+}
+
+// ConfirmTwoFactor is used to enable two factor authentication, once the user has shown their authenticator
+// app generates valid codes. The recovery codes of the user are returned, and can't be retrieved again
+func (um *UserManager) ConfirmTwoFactor(ethAddress, code string) ([]string, error) {
+	u := &User{}
+	if check := um.DB.Where("eth_address = ?", ethAddress).First(u); check.Error != nil {
+		return nil, check.Error
+	}
+	if u.TwoFactorEnabled {
+		return nil, errors.New("two factor authentication is already enabled")
+	}
+	if u.TwoFactorSecret == "" {
+		return nil, errors.New("two factor enrollment has not been started")
+	}
+	step, valid := utils.ValidateTOTPCode(u.TwoFactorSecret, code, time.Now(), 1)
+	if !valid {
+		return nil, ErrInvalidTwoFactorCode
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if check := um.DB.Model(u).Updates(map[string]interface{}{
+		"two_factor_enabled":        true,
+		"two_factor_last_step":      step,
+		"two_factor_recovery_codes": hashes,
+	}); check.Error != nil {
+		return nil, check.Error
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor is used to disable two factor authentication for a user, given a valid code
+func (um *UserManager) DisableTwoFactor(ethAddress, code string) error {
+	valid, err := um.ValidateTwoFactor(ethAddress, code)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return ErrInvalidTwoFactorCode
+	}
+	return um.DB.Model(&User{}).Where("eth_address = ?", ethAddress).Updates(map[string]interface{}{
+		"two_factor_enabled":        false,
+		"two_factor_secret":         "",
+		"two_factor_last_step":      0,
+		"two_factor_recovery_codes": pq.StringArray{},
+	}).Error
+}
+
+// RegenerateRecoveryCodes is used to replace the recovery codes of a user, given a valid code
+func (um *UserManager) RegenerateRecoveryCodes(ethAddress, code string) ([]string, error) {
+	u := &User{}
+	if check := um.DB.Where("eth_address = ?", ethAddress).First(u); check.Error != nil {
+		return nil, check.Error
+	}
+	if !u.TwoFactorEnabled {
+		return nil, errors.New("two factor authentication is not enabled")
+	}
+	valid, err := um.ValidateTwoFactor(ethAddress, code)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, ErrInvalidTwoFactorCode
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if check := um.DB.Model(u).Update("two_factor_recovery_codes", hashes); check.Error != nil {
+		return nil, check.Error
+	}
+	return codes, nil
+}
+
+// ValidateTwoFactor is used to check the second factor of a login, which is either a totp code or
+// an unused recovery code. Users without two factor authentication enabled are always valid.
+// Codes can only be used once, so a code seen by someone else can't be replayed
+func (um *UserManager) ValidateTwoFactor(ethAddress, code string) (bool, error) {
+	u := &User{}
+	if check := um.DB.Where("eth_address = ?", ethAddress).First(u); check.Error != nil {
+		return false, check.Error
+	}
+	if !u.TwoFactorEnabled {
+		return true, nil
+	}
+	if code == "" {
+		return false, ErrTwoFactorCodeRequired
+	}
+	if step, valid := utils.ValidateTOTPCode(u.TwoFactorSecret, code, time.Now(), 1); valid {
+		// only one request can move past the last used step, so each code works once
+		check := um.DB.Model(u).Where("two_factor_last_step < ?", step).Update("two_factor_last_step", step)
+		if check.Error != nil {
+			return false, check.Error
+		}
+		return check.RowsAffected == 1, nil
+	}
+	hash := hashToken(normalizeRecoveryCode(code))
+	var remaining []string
+	for _, v := range u.TwoFactorRecoveryCodes {
+		if v != hash {
+			remaining = append(remaining, v)
+		}
+	}
+	if len(remaining) == len(u.TwoFactorRecoveryCodes) {
+		return false, nil
+	}
+	// the recovery codes we read must be unchanged, so a recovery code can't be used by two logins at once
+	check := um.DB.Model(u).Where("two_factor_recovery_codes = ?", u.TwoFactorRecoveryCodes).
+		Update("two_factor_recovery_codes", pq.StringArray(remaining))
+	if check.Error != nil {
+		return false, check.Error
+	}
+	return check.RowsAffected == 1, nil
+}
+
+// generateRecoveryCodes is used to generate recovery codes, along with the hashes we store
+func generateRecoveryCodes() ([]string, pq.StringArray, error) {
+	var codes []string
+	var hashes pq.StringArray
+	for i := 0; i < TwoFactorRecoveryCodeCount; i++ {
+		token, err := utils.GenerateSecureToken(5)
+		if err != nil {
+			return nil, nil, err
+		}
+		code := fmt.Sprintf("%s-%s", token[:5], token[5:])
+		codes = append(codes, code)
+		hashes = append(hashes, hashToken(normalizeRecoveryCode(code)))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(code), "-", "", -1))
+}
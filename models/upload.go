@@ -11,19 +11,28 @@ import (
 	"github.com/lib/pq"
 )
 
+const (
+	// UploadTypeFile is used for uploads of a single file
+	UploadTypeFile = "file"
+	// UploadTypePin is used for uploads of content already on ipfs
+	UploadTypePin = "pin"
+	// UploadTypeDirectory is used for uploads of many files, added as a single directory
+	UploadTypeDirectory = "directory"
+)
+
 type Upload struct {
 	gorm.Model
 	Hash               string `gorm:"type:varchar(255);not null;"`
-	Type               string `gorm:"type:varchar(255);not null;"` //  file, pin
+	Type               string `gorm:"type:varchar(255);not null;"` //  file, pin, directory
 	NetworkName        string `gorm:"type:varchar(255)"`
 	HoldTimeInMonths   int64  `gorm:"type:integer;not null;"`
 	UploadAddress      string `gorm:"type:varchar(255);not null;"`
 	GarbageCollectDate time.Time
 	UploaderAddresses  pq.StringArray `gorm:"type:text[];not null;"`
+	// Releasing is set while the content is being unpinned, once its last owner released it
+	Releasing bool `gorm:"type:boolean"`
 }
 
-const dev = true
-
 type UploadManager struct {
 	DB *gorm.DB
 }
@@ -33,8 +42,9 @@ func NewUploadManager(db *gorm.DB) *UploadManager {
 	return &UploadManager{DB: db}
 }
 
-// NewUpload is used to create a new upload in the database
-func (um *UploadManager) NewUpload(contentHash, uploadType, networkName, ethAddress string, holdTimeInMonths int64) (*Upload, error) {
+// NewUpload is used to create a new upload in the database, owned by the uploader.
+// paymentNumber references the payment for the upload, and is empty for uploads not paid for with the payment contract
+func (um *UploadManager) NewUpload(contentHash, uploadType, networkName, ethAddress, paymentNumber string, holdTimeInMonths int64) (*Upload, error) {
 	_, err := um.FindUploadByHashAndNetwork(contentHash, networkName)
 	if err == nil {
 		// this means that there is already an upload in hte database matching this content hash and network name, so we will skip
@@ -53,20 +63,51 @@ func (um *UploadManager) NewUpload(contentHash, uploadType, networkName, ethAddr
 		GarbageCollectDate: utils.CalculateGarbageCollectDate(holdInt),
 		UploaderAddresses:  []string{ethAddress},
 	}
-	if check := um.DB.Create(&upload); check.Error != nil {
+	tx := um.DB.Begin()
+	if check := tx.Create(&upload); check.Error != nil {
+		tx.Rollback()
+		if isUniqueViolation(check.Error) {
+			// the upload was created by another consumer since we looked for it, so we add the uploader to it instead
+			return um.UpdateUpload(holdTimeInMonths, ethAddress, contentHash, networkName, paymentNumber)
+		}
+		return nil, check.Error
+	}
+	owner := UploadOwner{
+		UploadID:           upload.ID,
+		Hash:               contentHash,
+		NetworkName:        networkName,
+		EthAddress:         ethAddress,
+		HoldTimeInMonths:   holdTimeInMonths,
+		GarbageCollectDate: upload.GarbageCollectDate,
+		PaymentNumber:      paymentNumber,
+	}
+	if check := tx.Create(&owner); check.Error != nil {
+		tx.Rollback()
+		return nil, check.Error
+	}
+	if check := tx.Commit(); check.Error != nil {
 		return nil, check.Error
 	}
 	return &upload, nil
 }
 
-// UpdateUpload is used to upadte an already existing upload
-func (um *UploadManager) UpdateUpload(holdTimeInMonths int64, ethAddress, contentHash, networkName string) (*Upload, error) {
-	upload, err := um.FindUploadByHashAndNetwork(contentHash, networkName)
+// UpdateUpload is used to add, or extend, a user's ownership of an already existing upload.
+// An owner's retention is only ever extended, and the retention of the upload is recalculated
+// as the latest garbage collect date across its owners
+func (um *UploadManager) UpdateUpload(holdTimeInMonths int64, ethAddress, contentHash, networkName, paymentNumber string) (*Upload, error) {
+	holdInt, err := strconv.Atoi(fmt.Sprintf("%v", holdTimeInMonths))
 	if err != nil {
 		return nil, err
 	}
+	tx := um.DB.Begin()
+	upload := &Upload{}
+	// the upload stays locked until the ownership is saved, so that a pin removal can't unpin the content in the meantime
+	if check := tx.Set("gorm:query_option", "FOR UPDATE").
+		Where("hash = ? AND network_name = ?", contentHash, networkName).First(upload); check.Error != nil {
+		tx.Rollback()
+		return nil, check.Error
+	}
 	isUploader := false
-	upload.UploadAddress = ethAddress
 	for _, v := range upload.UploaderAddresses {
 		if ethAddress == v {
 			isUploader = true
@@ -76,62 +117,84 @@ func (um *UploadManager) UpdateUpload(holdTimeInMonths int64, ethAddress, conten
 	if !isUploader {
 		upload.UploaderAddresses = append(upload.UploaderAddresses, ethAddress)
 	}
-	holdInt, err := strconv.Atoi(fmt.Sprintf("%v", holdTimeInMonths))
-	if err != nil {
-		return nil, err
-	}
-	oldGcd := upload.GarbageCollectDate
+	// pinning content which is being released keeps it, with the release noticing once the content is unpinned
+	upload.Releasing = false
 	newGcd := utils.CalculateGarbageCollectDate(holdInt)
-	if newGcd.Unix() > oldGcd.Unix() {
-		upload.HoldTimeInMonths = holdTimeInMonths
-		upload.GarbageCollectDate = oldGcd
+	owner := &UploadOwner{}
+	check := tx.Where("upload_id = ? AND eth_address = ?", upload.ID, ethAddress).First(owner)
+	if check.Error != nil && !check.RecordNotFound() {
+		tx.Rollback()
+		return nil, check.Error
 	}
-	if check := um.DB.Save(upload); check.Error != nil {
+	if check.RecordNotFound() {
+		owner = &UploadOwner{
+			UploadID:    upload.ID,
+			Hash:        contentHash,
+			NetworkName: networkName,
+			EthAddress:  ethAddress,
+		}
+	}
+	if newGcd.Unix() > owner.GarbageCollectDate.Unix() {
+		owner.HoldTimeInMonths = holdTimeInMonths
+		owner.GarbageCollectDate = newGcd
+		if paymentNumber != "" {
+			owner.PaymentNumber = paymentNumber
+		}
+	}
+	if check = tx.Save(owner); check.Error != nil {
+		tx.Rollback()
+		if isUniqueViolation(check.Error) {
+			// the owner was created by another consumer since we looked for it, so it is loaded and extended instead
+			return um.UpdateUpload(holdTimeInMonths, ethAddress, contentHash, networkName, paymentNumber)
+		}
+		return nil, check.Error
+	}
+	if err = refreshRetention(tx, upload); err != nil {
+		tx.Rollback()
 		return nil, err
 	}
+	if check := tx.Commit(); check.Error != nil {
+		return nil, check.Error
+	}
 	return upload, nil
 }
 
-// RunDatabaseGarbageCollection is used to parse through the database
-// and delete all objects whose GCD has passed
-// TODO: Maybe move this to the database file?
-func (um *UploadManager) RunDatabaseGarbageCollection() (*[]Upload, error) {
+// FindExpiredUploads is used to find all uploads whose garbage collect date is before the given time
+func (um *UploadManager) FindExpiredUploads(expiredBefore time.Time) ([]Upload, error) {
 	var uploads []Upload
-	var deletedUploads []Upload
-
-	if check := um.DB.Find(&uploads); check.Error != nil {
+	if check := um.DB.Where("garbage_collect_date < ?", expiredBefore).Find(&uploads); check.Error != nil {
 		return nil, check.Error
 	}
-	for _, v := range uploads {
-		if time.Now().Unix() > v.GarbageCollectDate.Unix() {
-			if check := um.DB.Delete(&v); check.Error != nil {
-				return nil, check.Error
-			}
-			deletedUploads = append(deletedUploads, v)
-		}
-	}
-	return &deletedUploads, nil
+	return uploads, nil
 }
 
-// RunTestDatabaseGarbageCollection is used to run a test garbage collection run.
-// NOTE that this will delete literally every single object it detects.
-func (um *UploadManager) RunTestDatabaseGarbageCollection() (*[]Upload, error) {
-	var foundUploads []Upload
-	var deletedUploads []Upload
-	if !dev {
-		return nil, errors.New("not in dev mode")
-	}
-	// get all uploads
-	if check := um.DB.Find(&foundUploads); check.Error != nil {
-		return nil, check.Error
-	}
-	for _, v := range foundUploads {
-		if check := um.DB.Delete(v); check.Error != nil {
-			return nil, check.Error
-		}
-		deletedUploads = append(deletedUploads, v)
-	}
-	return &deletedUploads, nil
+// DeleteExpiredUpload is used to remove an upload, along with its owners, if it is still expired, with remove
+// called before the removal is committed. Expiry is checked again by the delete, and the upload stays locked until
+// remove returns, so content re-pinned or extended since it was found to be expired is kept. False is returned
+// when the upload is no longer expired, and the upload is kept when remove fails
+func (um *UploadManager) DeleteExpiredUpload(upload *Upload, expiredBefore time.Time, remove func() error) (bool, error) {
+	tx := um.DB.Begin()
+	check := tx.Where("id = ? AND garbage_collect_date < ?", upload.ID, expiredBefore).Delete(&Upload{})
+	if check.Error != nil {
+		tx.Rollback()
+		return false, check.Error
+	}
+	if check.RowsAffected == 0 {
+		tx.Rollback()
+		return false, nil
+	}
+	if check = tx.Where("upload_id = ?", upload.ID).Delete(&UploadOwner{}); check.Error != nil {
+		tx.Rollback()
+		return false, check.Error
+	}
+	if err := remove(); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if check = tx.Commit(); check.Error != nil {
+		return false, check.Error
+	}
+	return true, nil
 }
 
 func (um *UploadManager) FindUploadsByNetwork(networkName string) (*[]Upload, error) {
@@ -174,9 +237,9 @@ func (um *UploadManager) GetUploads() *[]Upload {
 	return &uploads
 }
 
-// GetUploadsForAddress is used to retrieve all uploads by an address
-func (um *UploadManager) GetUploadsForAddress(address string) *[]Upload {
-	var uploads []Upload
-	um.DB.Where("upload_address = ?", address).Find(&uploads)
-	return &uploads
+// GetUploadsForAddress is used to retrieve the uploads owned by an address, along with the retention of each
+func (um *UploadManager) GetUploadsForAddress(address string) *[]UploadOwner {
+	var owners []UploadOwner
+	um.DB.Where("eth_address = ?", address).Find(&owners)
+	return &owners
 }
@@ -0,0 +1,302 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// UploadOwner records a user's ownership of an upload, and how long they have paid for it to be kept.
+// The garbage collect date of an upload is the latest garbage collect date across its owners
+type UploadOwner struct {
+	gorm.Model
+	UploadID           uint   `gorm:"type:integer;not null;index"`
+	Hash               string `gorm:"type:varchar(255);not null;"`
+	NetworkName        string `gorm:"type:varchar(255)"`
+	EthAddress         string `gorm:"type:varchar(255);not null;index"`
+	HoldTimeInMonths   int64  `gorm:"type:integer;not null;"`
+	GarbageCollectDate time.Time
+	// PaymentNumber is the number of the pin payment made for this ownership, if any
+	PaymentNumber string `gorm:"type:varchar(255)"`
+	// FileName is the name the file was uploaded with, which is empty for pins
+	FileName string `gorm:"type:varchar(255)"`
+}
+
+// FindUploadOwner is used to find a user's ownership of an upload
+func (um *UploadManager) FindUploadOwner(uploadID uint, ethAddress string) (*UploadOwner, error) {
+	owner := &UploadOwner{}
+	if check := um.DB.Where("upload_id = ? AND eth_address = ?", uploadID, ethAddress).First(owner); check.Error != nil {
+		return nil, check.Error
+	}
+	return owner, nil
+}
+
+// SetUploadFileName is used to store the name a user uploaded a file with, which is used when downloading it
+func (um *UploadManager) SetUploadFileName(ethAddress, contentHash, networkName, fileName string) error {
+	upload, err := um.FindUploadByHashAndNetwork(contentHash, networkName)
+	if err != nil {
+		return err
+	}
+	owner, err := um.FindUploadOwner(upload.ID, ethAddress)
+	if err != nil {
+		return err
+	}
+	return um.DB.Model(owner).Update("file_name", fileName).Error
+}
+
+// FindUploadOwners is used to find all of the owners of an upload
+func (um *UploadManager) FindUploadOwners(uploadID uint) ([]UploadOwner, error) {
+	var owners []UploadOwner
+	if check := um.DB.Where("upload_id = ?", uploadID).Find(&owners); check.Error != nil {
+		return nil, check.Error
+	}
+	return owners, nil
+}
+
+// FindActiveUploadOwners is used to find the owners of an upload whose retention has not yet expired
+func (um *UploadManager) FindActiveUploadOwners(uploadID uint) ([]UploadOwner, error) {
+	var owners []UploadOwner
+	if check := um.DB.Where("upload_id = ? AND garbage_collect_date > ?", uploadID, time.Now()).Find(&owners); check.Error != nil {
+		return nil, check.Error
+	}
+	return owners, nil
+}
+
+// ReleaseUpload is used to remove a user's ownership of an upload. When no other user has an active ownership
+// of the upload, its owners are removed and the upload is marked as releasing, with true being returned so that the
+// content is unpinned by the caller, who then calls FinishRelease. Otherwise the retention of the upload is
+// recalculated from the remaining owners. Uploads already releasing return true, so that failed unpins can be retried
+func (um *UploadManager) ReleaseUpload(ethAddress, contentHash, networkName string) (bool, error) {
+	tx := um.DB.Begin()
+	upload := &Upload{}
+	if check := tx.Set("gorm:query_option", "FOR UPDATE").
+		Where("hash = ? AND network_name = ?", contentHash, networkName).First(upload); check.Error != nil {
+		tx.Rollback()
+		return false, check.Error
+	}
+	if upload.Releasing {
+		tx.Rollback()
+		return true, nil
+	}
+	owner := &UploadOwner{}
+	if check := tx.Where("upload_id = ? AND eth_address = ?", upload.ID, ethAddress).First(owner); check.Error != nil {
+		tx.Rollback()
+		return false, check.Error
+	}
+	var held int
+	if check := tx.Model(&UploadOwner{}).Where("upload_id = ? AND eth_address <> ? AND garbage_collect_date > ?",
+		upload.ID, ethAddress, time.Now()).Count(&held); check.Error != nil {
+		tx.Rollback()
+		return false, check.Error
+	}
+	if held == 0 {
+		if check := tx.Where("upload_id = ?", upload.ID).Delete(&UploadOwner{}); check.Error != nil {
+			tx.Rollback()
+			return false, check.Error
+		}
+		upload.UploaderAddresses = pq.StringArray{}
+		upload.Releasing = true
+		if check := tx.Save(upload); check.Error != nil {
+			tx.Rollback()
+			return false, check.Error
+		}
+		return true, tx.Commit().Error
+	}
+	// a nil array would be stored as null, rather than an empty array
+	uploaders := pq.StringArray{}
+	for _, v := range upload.UploaderAddresses {
+		if v != ethAddress {
+			uploaders = append(uploaders, v)
+		}
+	}
+	upload.UploaderAddresses = uploaders
+	if check := tx.Delete(owner); check.Error != nil {
+		tx.Rollback()
+		return false, check.Error
+	}
+	if err := refreshRetention(tx, upload); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	return false, tx.Commit().Error
+}
+
+// FinishRelease is used to remove an upload released by ReleaseUpload once its content has been unpinned.
+// False is returned when the content was pinned again while it was being unpinned, in which case the upload
+// is kept and the content needs to be pinned again by the caller
+func (um *UploadManager) FinishRelease(contentHash, networkName string) (bool, error) {
+	tx := um.DB.Begin()
+	upload := &Upload{}
+	check := tx.Set("gorm:query_option", "FOR UPDATE").
+		Where("hash = ? AND network_name = ?", contentHash, networkName).First(upload)
+	if check.RecordNotFound() {
+		// the upload was already removed, such as by the garbage collector
+		tx.Rollback()
+		return true, nil
+	}
+	if check.Error != nil {
+		tx.Rollback()
+		return false, check.Error
+	}
+	if !upload.Releasing {
+		tx.Rollback()
+		return false, nil
+	}
+	if check := tx.Delete(upload); check.Error != nil {
+		tx.Rollback()
+		return false, check.Error
+	}
+	return true, tx.Commit().Error
+}
+
+// BackfillUploadOwners is used to create owners for uploads made before ownership was tracked,
+// giving every uploader of an upload the retention of the upload
+func (um *UploadManager) BackfillUploadOwners() error {
+	var uploads []Upload
+	if check := um.DB.Where("id NOT IN (?)", um.DB.Table("upload_owners").Select("upload_id").QueryExpr()).Find(&uploads); check.Error != nil {
+		return check.Error
+	}
+	for _, upload := range uploads {
+		addresses := upload.UploaderAddresses
+		if len(addresses) == 0 {
+			addresses = []string{upload.UploadAddress}
+		}
+		seen := make(map[string]bool)
+		for _, address := range addresses {
+			if seen[address] {
+				continue
+			}
+			seen[address] = true
+			owner := UploadOwner{
+				UploadID:           upload.ID,
+				Hash:               upload.Hash,
+				NetworkName:        upload.NetworkName,
+				EthAddress:         address,
+				HoldTimeInMonths:   upload.HoldTimeInMonths,
+				GarbageCollectDate: upload.GarbageCollectDate,
+			}
+			if check := um.DB.Create(&owner); check.Error != nil {
+				return check.Error
+			}
+		}
+	}
+	return nil
+}
+
+// CreateUniqueIndexes is used to make sure there is only one upload of content on each network, with only one
+// ownership of it for each user. Duplicates created before the indexes existed are merged first, keeping the
+// oldest upload, and each user's ownership which keeps the content the longest. Deleted rows are left out
+// of the indexes, so that content can be uploaded again once it has been removed
+func (um *UploadManager) CreateUniqueIndexes() error {
+	if err := um.mergeDuplicateUploads(); err != nil {
+		return err
+	}
+	if err := um.removeDuplicateUploadOwners(); err != nil {
+		return err
+	}
+	if check := um.DB.Exec(
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_uploads_hash_network_name ON uploads (hash, network_name) WHERE deleted_at IS NULL",
+	); check.Error != nil {
+		return check.Error
+	}
+	return um.DB.Exec(
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_upload_owners_upload_id_eth_address ON upload_owners (upload_id, eth_address) WHERE deleted_at IS NULL",
+	).Error
+}
+
+// mergeDuplicateUploads is used to move the owners of duplicate uploads to the oldest upload of the content, removing the duplicates
+func (um *UploadManager) mergeDuplicateUploads() error {
+	var duplicates []struct {
+		Hash        string
+		NetworkName string
+	}
+	if check := um.DB.Model(&Upload{}).Select("hash, network_name").Where("network_name IS NOT NULL").
+		Group("hash, network_name").Having("COUNT(*) > 1").Scan(&duplicates); check.Error != nil {
+		return check.Error
+	}
+	for _, v := range duplicates {
+		var uploads []Upload
+		if check := um.DB.Where("hash = ? AND network_name = ?", v.Hash, v.NetworkName).Order("id").Find(&uploads); check.Error != nil {
+			return check.Error
+		}
+		kept := &uploads[0]
+		tx := um.DB.Begin()
+		for _, duplicate := range uploads[1:] {
+			if check := tx.Model(&UploadOwner{}).Where("upload_id = ?", duplicate.ID).Update("upload_id", kept.ID); check.Error != nil {
+				tx.Rollback()
+				return check.Error
+			}
+			for _, address := range duplicate.UploaderAddresses {
+				if !isUploader(kept, address) {
+					kept.UploaderAddresses = append(kept.UploaderAddresses, address)
+				}
+			}
+			if check := tx.Delete(&duplicate); check.Error != nil {
+				tx.Rollback()
+				return check.Error
+			}
+		}
+		if err := refreshRetention(tx, kept); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if check := tx.Commit(); check.Error != nil {
+			return check.Error
+		}
+	}
+	return nil
+}
+
+// removeDuplicateUploadOwners is used to remove all but the longest lasting ownership each user has of an upload
+func (um *UploadManager) removeDuplicateUploadOwners() error {
+	var duplicates []struct {
+		UploadID   uint
+		EthAddress string
+	}
+	if check := um.DB.Model(&UploadOwner{}).Select("upload_id, eth_address").
+		Group("upload_id, eth_address").Having("COUNT(*) > 1").Scan(&duplicates); check.Error != nil {
+		return check.Error
+	}
+	for _, v := range duplicates {
+		var owners []UploadOwner
+		if check := um.DB.Where("upload_id = ? AND eth_address = ?", v.UploadID, v.EthAddress).
+			Order("garbage_collect_date desc, id").Find(&owners); check.Error != nil {
+			return check.Error
+		}
+		for _, owner := range owners[1:] {
+			if check := um.DB.Delete(&owner); check.Error != nil {
+				return check.Error
+			}
+		}
+	}
+	return nil
+}
+
+// isUploader is used to check whether or not an address is one of the uploaders of an upload
+func isUploader(upload *Upload, ethAddress string) bool {
+	for _, v := range upload.UploaderAddresses {
+		if v == ethAddress {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshRetention is used to set the retention of an upload to that of the owner keeping it the longest.
+// Once every owner has been removed, the upload is due to be garbage collected immediately
+func refreshRetention(db *gorm.DB, upload *Upload) error {
+	var owners []UploadOwner
+	if check := db.Where("upload_id = ?", upload.ID).Find(&owners); check.Error != nil {
+		return check.Error
+	}
+	upload.GarbageCollectDate = time.Now()
+	upload.HoldTimeInMonths = 0
+	for i, v := range owners {
+		if i == 0 || v.GarbageCollectDate.After(upload.GarbageCollectDate) {
+			upload.GarbageCollectDate = v.GarbageCollectDate
+			upload.HoldTimeInMonths = v.HoldTimeInMonths
+		}
+	}
+	return db.Save(upload).Error
+}
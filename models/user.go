@@ -20,10 +20,19 @@ type User struct {
 	APIAccess         bool   `gorm:"type:boolean"`
 	EmailEnabled      bool   `gorm:"type:boolean"`
 	HashedPassword    string `gorm:"type:varchar(255)"`
+	// AwaitingVerification is set for accounts which are enabled once their email address is verified
+	AwaitingVerification bool `gorm:"type:boolean"`
 	// IPFSKeyNames is an array of IPFS keys this user has created
 	IPFSKeyNames     pq.StringArray `gorm:"type:text[];column:ipfs_key_names"`
 	IPFSKeyIDs       pq.StringArray `gorm:"type:text[];column:ipfs_key_ids"`
 	IPFSNetworkNames pq.StringArray `gorm:"type:text[];column:ipfs_network_names"`
+	// Roles are the roles this user has been granted, see RolePermissions
+	Roles pq.StringArray `gorm:"type:text[]"`
+	// TwoFactorEnabled is set once the user has confirmed a code generated from their TwoFactorSecret
+	TwoFactorEnabled       bool           `gorm:"type:boolean"`
+	TwoFactorSecret        string         `gorm:"type:varchar(255)" json:"-"`
+	TwoFactorLastStep      int64          `json:"-"`
+	TwoFactorRecoveryCodes pq.StringArray `gorm:"type:text[]" json:"-"`
 }
 
 type UserManager struct {
@@ -166,17 +175,47 @@ func (um *UserManager) ChangePassword(ethAddress, currentPassword, newPassword s
 	if err := bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(currentPassword)); err != nil {
 		return false, errors.New("invalid current password")
 	}
-	newHashedPass, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
-	if err != nil {
-		return false, err
-	}
-	check := um.DB.Model(&user).Update("hashed_password", string(newHashedPass))
-	if check.Error != nil {
+	if err := um.setPassword(&user, newPassword); err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
+// ResetPassword is used to set the password of a user who has forgotten their current password.
+// The user must have proven they own the account, such as with a password reset token
+func (um *UserManager) ResetPassword(ethAddress, newPassword string) error {
+	var user User
+	um.DB.Where("eth_address = ?", ethAddress).First(&user)
+	if user.CreatedAt == nilTime {
+		return errors.New("user account does not exist")
+	}
+	return um.setPassword(&user, newPassword)
+}
+
+// VerifyEmailAddress is used to mark the email address of a user as verified. Accounts awaiting the verification
+// are enabled, while accounts which were disabled on purpose are kept disabled
+func (um *UserManager) VerifyEmailAddress(ethAddress string) error {
+	var user User
+	um.DB.Where("eth_address = ?", ethAddress).First(&user)
+	if user.CreatedAt == nilTime {
+		return errors.New("user account does not exist")
+	}
+	// the account is enabled with the same update that ends the wait, so a disabled account is never enabled by it
+	return um.DB.Model(&user).Updates(map[string]interface{}{
+		"email_enabled":         true,
+		"account_enabled":       gorm.Expr("account_enabled OR awaiting_verification"),
+		"awaiting_verification": false,
+	}).Error
+}
+
+func (um *UserManager) setPassword(user *User, newPassword string) error {
+	newHashedPass, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return um.DB.Model(user).Update("hashed_password", string(newHashedPass)).Error
+}
+
 func (um *UserManager) NewUserAccount(ethAddress, password, email string, enterpriseEnabled bool) (*User, error) {
 	var user User
 	um.DB.Where("eth_address = ?", ethAddress).First(&user)
@@ -191,6 +230,25 @@ func (um *UserManager) NewUserAccount(ethAddress, password, email string, enterp
 	user.EnterpriseEnabled = enterpriseEnabled
 	user.HashedPassword = string(hashedPass)
 	user.EmailAddress = email
+	user.AwaitingVerification = true
+	if check := um.DB.Create(&user); check.Error != nil {
+		return nil, check.Error
+	}
+	return &user, nil
+}
+
+// NewWalletUserAccount is used to create an account for a user who logs in by signing with their wallet.
+// No password is set, so the account can only log in with its wallet. The address should be given with its
+// checksum casing, and is refused if an account exists for it with any casing
+func (um *UserManager) NewWalletUserAccount(ethAddress, email string) (*User, error) {
+	if um.FindByWalletAddress(ethAddress) != nil {
+		return nil, errors.New("user account already created")
+	}
+	user := User{
+		EthAddress:           ethAddress,
+		EmailAddress:         email,
+		AwaitingVerification: true,
+	}
 	if check := um.DB.Create(&user); check.Error != nil {
 		return nil, check.Error
 	}
@@ -241,6 +299,17 @@ func (um *UserManager) FindByAddress(address string) *User {
 	return &u
 }
 
+// FindByWalletAddress is used to find the user of a wallet address. Addresses are matched without their
+// casing, as accounts may have been created with or without the checksum casing of their address
+func (um *UserManager) FindByWalletAddress(address string) *User {
+	u := User{}
+	um.DB.Where("lower(eth_address) = lower(?)", address).Order("id asc").First(&u)
+	if u.CreatedAt == nilTime {
+		return nil
+	}
+	return &u
+}
+
 // FindEmailByAddress is used to find an email address by searching for the users eth address
 // the returned map contains their eth address as a key, and their email address as a value
 func (um *UserManager) FindEmailByAddress(ethAddress string) (map[string]string, error) {
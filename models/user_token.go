@@ -0,0 +1,92 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/utils"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// TokenPurposeEmailVerification is used for tokens verifying the email address of a user
+	TokenPurposeEmailVerification = "email-verification"
+	// TokenPurposePasswordReset is used for tokens allowing a user to reset their password
+	TokenPurposePasswordReset = "password-reset"
+)
+
+var (
+	// EmailVerificationTimeout is how long a user has to verify their email address
+	EmailVerificationTimeout = time.Hour * 48
+	// PasswordResetTimeout is how long a user has to reset their password
+	PasswordResetTimeout = time.Hour
+)
+
+// UserToken is a single use token emailed to a user, to prove they own their email address.
+// Only the hash of the token is stored
+type UserToken struct {
+	gorm.Model
+	EthAddress string `gorm:"type:varchar(255);not null;index"`
+	TokenHash  string `gorm:"type:varchar(255);not null;unique_index"`
+	Purpose    string `gorm:"type:varchar(255);not null"`
+	ExpiresAt  time.Time
+	Used       bool
+}
+
+// UserTokenManager is used to manipulate user tokens in the database
+type UserTokenManager struct {
+	DB *gorm.DB
+}
+
+// NewUserTokenManager is used to generate our user token manager
+func NewUserTokenManager(db *gorm.DB) *UserTokenManager {
+	return &UserTokenManager{DB: db}
+}
+
+// NewUserToken is used to create a token for the given purpose, returning the token to email to the user.
+// Any unused tokens the user has for the same purpose can no longer be used
+func (tm *UserTokenManager) NewUserToken(ethAddress, purpose string, timeout time.Duration) (string, error) {
+	token, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+	userToken := UserToken{
+		EthAddress: ethAddress,
+		TokenHash:  hashToken(token),
+		Purpose:    purpose,
+		ExpiresAt:  time.Now().Add(timeout),
+	}
+	tx := tm.DB.Begin()
+	if check := tx.Model(&UserToken{}).Where("eth_address = ? AND purpose = ? AND used = ?", ethAddress, purpose, false).
+		Update("used", true); check.Error != nil {
+		tx.Rollback()
+		return "", check.Error
+	}
+	if check := tx.Create(&userToken); check.Error != nil {
+		tx.Rollback()
+		return "", check.Error
+	}
+	if check := tx.Commit(); check.Error != nil {
+		return "", check.Error
+	}
+	return token, nil
+}
+
+// UseUserToken is used to find an unused, unexpired token for the given purpose, and mark it as used
+func (tm *UserTokenManager) UseUserToken(token, purpose string) (*UserToken, error) {
+	userToken := &UserToken{}
+	if check := tm.DB.Where("token_hash = ? AND purpose = ?", hashToken(token), purpose).First(userToken); check.Error != nil {
+		return nil, errors.New("invalid token")
+	}
+	if userToken.ExpiresAt.Before(time.Now()) {
+		return nil, errors.New("token has expired")
+	}
+	check := tm.DB.Model(userToken).Where("used = ?", false).Update("used", true)
+	if check.Error != nil {
+		return nil, check.Error
+	}
+	if check.RowsAffected == 0 {
+		return nil, errors.New("token has already been used")
+	}
+	return userToken, nil
+}
@@ -1,8 +1,33 @@
 package models
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
 
 var nilTime time.Time
 
-// AdminAddress is the eth address of the admin account
-var AdminAddress = "0xC6C35f43fDD71f86a2D8D4e3cA1Ce32564c38bd9"
+// hashToken is used to hash the tokens we give out, so that they can't be used if our database is leaked
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// isUniqueViolation is used to check whether or not an error was caused by a unique index, such as
+// when a row was created by someone else between us looking for it and creating it
+func isUniqueViolation(err error) bool {
+	if errs, ok := err.(gorm.Errors); ok {
+		for _, v := range errs {
+			if isUniqueViolation(v) {
+				return true
+			}
+		}
+		return false
+	}
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code.Name() == "unique_violation"
+}
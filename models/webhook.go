@@ -0,0 +1,182 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+const (
+	// WebhookEventPinCompleted is sent when content has been pinned
+	WebhookEventPinCompleted = "pin.completed"
+	// WebhookEventPinFailed is sent when content could not be pinned
+	WebhookEventPinFailed = "pin.failed"
+	// WebhookEventFileAdded is sent when a file has been added to ipfs
+	WebhookEventFileAdded = "file.added"
+	// WebhookEventIPNSPublished is sent when an ipns record has been published
+	WebhookEventIPNSPublished = "ipns.published"
+	// WebhookEventPaymentConfirmed is sent when a payment has been confirmed
+	WebhookEventPaymentConfirmed = "payment.confirmed"
+)
+
+// WebhookEvents are all the events a webhook may subscribe to
+var WebhookEvents = []string{
+	WebhookEventPinCompleted,
+	WebhookEventPinFailed,
+	WebhookEventFileAdded,
+	WebhookEventIPNSPublished,
+	WebhookEventPaymentConfirmed,
+}
+
+// Webhook is an endpoint registered by a user to receive signed event notifications
+type Webhook struct {
+	gorm.Model
+	EthAddress string         `gorm:"type:varchar(255);not null" json:"eth_address"`
+	URL        string         `gorm:"type:varchar(255);not null" json:"url"`
+	Secret     string         `gorm:"type:varchar(255);not null" json:"-"`
+	Events     pq.StringArray `gorm:"type:text[]" json:"events"`
+}
+
+// WebhookDelivery records an attempt to deliver an event to a webhook
+type WebhookDelivery struct {
+	gorm.Model
+	WebhookID  uint   `json:"webhook_id"`
+	EthAddress string `gorm:"type:varchar(255);not null" json:"eth_address"`
+	Event      string `gorm:"type:varchar(255);not null" json:"event"`
+	Payload    string `gorm:"type:text" json:"payload"`
+	Attempts   int    `json:"attempts"`
+	StatusCode int    `json:"status_code"`
+	Delivered  bool   `json:"delivered"`
+	// Error holds the reason the last attempt failed
+	Error         string    `gorm:"type:text" json:"error"`
+	LastAttemptAt time.Time `json:"last_attempt_at"`
+}
+
+// WebhookManager is used to manipulate webhooks and their deliveries in the database
+type WebhookManager struct {
+	DB *gorm.DB
+}
+
+// NewWebhookManager is used to generate our webhook manager
+func NewWebhookManager(db *gorm.DB) *WebhookManager {
+	return &WebhookManager{DB: db}
+}
+
+// NewWebhook is used to register a webhook for a user
+func (wm *WebhookManager) NewWebhook(ethAddress, url, secret string, events []string) (*Webhook, error) {
+	for _, v := range events {
+		if !IsValidWebhookEvent(v) {
+			return nil, errors.New("invalid webhook event")
+		}
+	}
+	hook := Webhook{
+		EthAddress: ethAddress,
+		URL:        url,
+		Secret:     secret,
+		Events:     events,
+	}
+	if check := wm.DB.Create(&hook); check.Error != nil {
+		return nil, check.Error
+	}
+	return &hook, nil
+}
+
+// FindWebhookByID is used to find a webhook belonging to the given user
+func (wm *WebhookManager) FindWebhookByID(ethAddress string, id uint) (*Webhook, error) {
+	hook := Webhook{}
+	if check := wm.DB.Where("id = ? AND eth_address = ?", id, ethAddress).First(&hook); check.Error != nil {
+		return nil, check.Error
+	}
+	return &hook, nil
+}
+
+// FindWebhooksByUser is used to list all webhooks for a user
+func (wm *WebhookManager) FindWebhooksByUser(ethAddress string) ([]Webhook, error) {
+	var hooks []Webhook
+	if check := wm.DB.Where("eth_address = ?", ethAddress).Find(&hooks); check.Error != nil {
+		return nil, check.Error
+	}
+	return hooks, nil
+}
+
+// FindWebhooksForEvent is used to find all webhooks of a user subscribed to the given event
+func (wm *WebhookManager) FindWebhooksForEvent(ethAddress, event string) ([]Webhook, error) {
+	var hooks []Webhook
+	if check := wm.DB.Where("eth_address = ? AND ? = ANY(events)", ethAddress, event).Find(&hooks); check.Error != nil {
+		return nil, check.Error
+	}
+	return hooks, nil
+}
+
+// DeleteWebhook is used to remove a webhook belonging to the given user
+func (wm *WebhookManager) DeleteWebhook(ethAddress string, id uint) error {
+	hook, err := wm.FindWebhookByID(ethAddress, id)
+	if err != nil {
+		return err
+	}
+	return wm.DB.Delete(hook).Error
+}
+
+// NewDelivery is used to record a pending delivery of an event to a webhook
+func (wm *WebhookManager) NewDelivery(hook *Webhook, event, payload string) (*WebhookDelivery, error) {
+	delivery := WebhookDelivery{
+		WebhookID:  hook.ID,
+		EthAddress: hook.EthAddress,
+		Event:      event,
+		Payload:    payload,
+	}
+	if check := wm.DB.Create(&delivery); check.Error != nil {
+		return nil, check.Error
+	}
+	return &delivery, nil
+}
+
+// FindDeliveryByID is used to find a delivery by its id
+func (wm *WebhookManager) FindDeliveryByID(id uint) (*WebhookDelivery, error) {
+	delivery := WebhookDelivery{}
+	if check := wm.DB.Where("id = ?", id).First(&delivery); check.Error != nil {
+		return nil, check.Error
+	}
+	return &delivery, nil
+}
+
+// FindDeliveryForEvent is used to find the delivery of an event to a webhook. Events are identified by
+// their payload, which includes the time the event happened
+func (wm *WebhookManager) FindDeliveryForEvent(webhookID uint, event, payload string) (*WebhookDelivery, error) {
+	delivery := WebhookDelivery{}
+	if check := wm.DB.Where("webhook_id = ? AND event = ? AND payload = ?", webhookID, event, payload).First(&delivery); check.Error != nil {
+		return nil, check.Error
+	}
+	return &delivery, nil
+}
+
+// DeleteDelivery is used to remove a delivery which was never sent
+func (wm *WebhookManager) DeleteDelivery(delivery *WebhookDelivery) error {
+	return wm.DB.Delete(delivery).Error
+}
+
+// UpdateDelivery is used to save the outcome of a delivery attempt
+func (wm *WebhookManager) UpdateDelivery(delivery *WebhookDelivery) error {
+	return wm.DB.Save(delivery).Error
+}
+
+// FindDeliveriesByWebhook is used to list the deliveries made to a webhook of the given user, most recent first
+func (wm *WebhookManager) FindDeliveriesByWebhook(ethAddress string, webhookID uint) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	if check := wm.DB.Where("eth_address = ? AND webhook_id = ?", ethAddress, webhookID).Order("created_at desc").Find(&deliveries); check.Error != nil {
+		return nil, check.Error
+	}
+	return deliveries, nil
+}
+
+// IsValidWebhookEvent is used to check whether or not a webhook may subscribe to the given event
+func IsValidWebhookEvent(event string) bool {
+	for _, v := range WebhookEvents {
+		if v == event {
+			return true
+		}
+	}
+	return false
+}
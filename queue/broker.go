@@ -0,0 +1,62 @@
+package queue
+
+import (
+	"time"
+)
+
+/*
+The queue workers are written against the Broker interface rather than rabbitmq directly.
+AMQPBroker is used in production, while MemoryBroker runs everything in-process which
+is useful for tests, and for running temporal without a rabbitmq server.
+*/
+
+// MessagePublisher is used to publish messages to a queue, or exchange
+type MessagePublisher interface {
+	PublishMessage(queueName string, body interface{}) error
+	PublishMessageWithExchange(body interface{}, exchangeName string) error
+}
+
+// Broker is used to publish and consume messages
+type Broker interface {
+	MessagePublisher
+	// Consume is used to start receiving messages from a queue, with at most prefetch
+	// messages unacknowledged at once. The channel is closed once the consumer is cancelled,
+	// or the broker loses its connection
+	Consume(queueName, consumer string, prefetch int) (<-chan Delivery, error)
+	// Cancel is used to stop a consumer from receiving any new messages
+	Cancel(consumer string) error
+	// RetryMessage is used to retry a message that failed due to a possibly temporary error.
+	// Once a message has exhausted its retries it is dead-lettered, in which case false is returned
+	RetryMessage(d Delivery, reason error) bool
+	// DeadLetterMessage is used to move a message that can't be processed to the dead-letter queue
+	DeadLetterMessage(d Delivery, reason error)
+	// Close is used to close the broker, along with any of its consumers
+	Close()
+}
+
+// Acknowledger is used to acknowledge, or reject, a delivery with the broker it came from
+type Acknowledger interface {
+	Ack(tag uint64, multiple bool) error
+	Nack(tag uint64, multiple, requeue bool) error
+}
+
+// Delivery is a message received from a broker
+type Delivery struct {
+	Acknowledger Acknowledger
+	DeliveryTag  uint64
+	Queue        string
+	ContentType  string
+	Headers      map[string]interface{}
+	Timestamp    time.Time
+	Body         []byte
+}
+
+// Ack is used to acknowledge that the message has been processed
+func (d Delivery) Ack(multiple bool) error {
+	return d.Acknowledger.Ack(d.DeliveryTag, multiple)
+}
+
+// Nack is used to reject the message, requeueing it if requeue is true
+func (d Delivery) Nack(multiple, requeue bool) error {
+	return d.Acknowledger.Nack(d.DeliveryTag, multiple, requeue)
+}
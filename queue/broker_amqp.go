@@ -0,0 +1,151 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+// AMQPBroker is a broker backed by rabbitmq. Messages are published with a shared
+// Publisher, and each queue being consumed from has its own connection
+type AMQPBroker struct {
+	connectionURL string
+	publisher     *Publisher
+	mux           sync.Mutex
+	// queues holds the connection used to consume from each queue
+	queues map[string]*QueueManager
+	// consumers maps consumer tags to the queue they are consuming from
+	consumers map[string]string
+}
+
+// NewAMQPBroker is used to connect to rabbitmq and generate our broker
+func NewAMQPBroker(connectionURL string) (*AMQPBroker, error) {
+	publisher, err := NewPublisher(connectionURL, DefaultPublisherPoolSize)
+	if err != nil {
+		return nil, err
+	}
+	return &AMQPBroker{
+		connectionURL: connectionURL,
+		publisher:     publisher,
+		queues:        make(map[string]*QueueManager),
+		consumers:     make(map[string]string),
+	}, nil
+}
+
+// ConnectionURL is used to retrieve the url of the rabbitmq server we connect to
+func (b *AMQPBroker) ConnectionURL() string {
+	return b.connectionURL
+}
+
+// PublishMessage is used to publish a message to the given queue
+func (b *AMQPBroker) PublishMessage(queueName string, body interface{}) error {
+	return b.publisher.PublishMessage(queueName, body)
+}
+
+// PublishMessageWithExchange is used to publish a message to the given exchange
+func (b *AMQPBroker) PublishMessageWithExchange(body interface{}, exchangeName string) error {
+	return b.publisher.PublishMessageWithExchange(body, exchangeName)
+}
+
+// Consume is used to start consuming messages from the queue. If the connection to
+// rabbitmq has been lost, we will reconnect before consuming
+func (b *AMQPBroker) Consume(queueName, consumer string, prefetch int) (<-chan Delivery, error) {
+	qm, err := b.queueManager(queueName)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		msgs, err := qm.consume(consumer, prefetch)
+		if err == nil {
+			b.mux.Lock()
+			b.consumers[consumer] = queueName
+			b.mux.Unlock()
+			return forwardDeliveries(queueName, msgs), nil
+		}
+		fmt.Println("error consuming from queue ", err)
+		qm.reconnect()
+	}
+}
+
+// Cancel is used to stop the consumer from receiving new messages
+func (b *AMQPBroker) Cancel(consumer string) error {
+	b.mux.Lock()
+	qm := b.queues[b.consumers[consumer]]
+	b.mux.Unlock()
+	if qm == nil {
+		return errors.New("consumer does not exist")
+	}
+	return qm.Channel.Cancel(consumer, false)
+}
+
+// RetryMessage is used to publish the message to the next retry queue for the queue it came from
+func (b *AMQPBroker) RetryMessage(d Delivery, reason error) bool {
+	qm, err := b.queueManager(d.Queue)
+	if err != nil {
+		fmt.Println("error loading queue to retry message ", err)
+		d.Nack(false, true)
+		return true
+	}
+	return qm.RetryMessage(d, reason)
+}
+
+// DeadLetterMessage is used to publish the message to the dead-letter exchange for the queue it came from
+func (b *AMQPBroker) DeadLetterMessage(d Delivery, reason error) {
+	qm, err := b.queueManager(d.Queue)
+	if err != nil {
+		fmt.Println("error loading queue to dead-letter message ", err)
+		d.Nack(false, false)
+		return
+	}
+	qm.DeadLetterMessage(d, reason)
+}
+
+// Close is used to close the publisher, and the connections of every queue being consumed from
+func (b *AMQPBroker) Close() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	for _, qm := range b.queues {
+		qm.Close()
+	}
+	b.queues = make(map[string]*QueueManager)
+	b.consumers = make(map[string]string)
+	b.publisher.Close()
+}
+
+// queueManager is used to retrieve the connection for the queue, connecting if we haven't already
+func (b *AMQPBroker) queueManager(queueName string) (*QueueManager, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if qm, ok := b.queues[queueName]; ok {
+		return qm, nil
+	}
+	qm, err := Initialize(queueName, b.connectionURL)
+	if err != nil {
+		return nil, err
+	}
+	b.queues[queueName] = qm
+	return qm, nil
+}
+
+// forwardDeliveries is used to convert rabbitmq deliveries into broker deliveries,
+// closing the returned channel once the rabbitmq deliveries channel is closed
+func forwardDeliveries(queueName string, msgs <-chan amqp.Delivery) <-chan Delivery {
+	out := make(chan Delivery)
+	go func() {
+		defer close(out)
+		for d := range msgs {
+			out <- Delivery{
+				Acknowledger: d.Acknowledger,
+				DeliveryTag:  d.DeliveryTag,
+				Queue:        queueName,
+				ContentType:  d.ContentType,
+				Headers:      d.Headers,
+				Timestamp:    d.Timestamp,
+				Body:         d.Body,
+			}
+		}
+	}()
+	return out
+}
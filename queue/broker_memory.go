@@ -0,0 +1,270 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// MemoryBroker is an in-process broker which keeps messages in memory. Messages are
+// lost once the process exits, so it is only suitable for tests and local development
+type MemoryBroker struct {
+	mux  sync.Mutex
+	cond *sync.Cond
+	// pending holds the messages of each queue waiting to be delivered
+	pending map[string][]Delivery
+	// unacked holds delivered messages which have yet to be acknowledged
+	unacked     map[uint64]*memoryConsumer
+	consumers   map[string]*memoryConsumer
+	deadLetters map[string][]DeadLetter
+	lastTag     uint64
+	closed      bool
+}
+
+type memoryConsumer struct {
+	queueName string
+	prefetch  int
+	unacked   map[uint64]Delivery
+	cancelled chan struct{}
+}
+
+// NewMemoryBroker is used to generate our in-memory broker
+func NewMemoryBroker() *MemoryBroker {
+	b := &MemoryBroker{
+		pending:     make(map[string][]Delivery),
+		unacked:     make(map[uint64]*memoryConsumer),
+		consumers:   make(map[string]*memoryConsumer),
+		deadLetters: make(map[string][]DeadLetter),
+	}
+	b.cond = sync.NewCond(&b.mux)
+	return b
+}
+
+// PublishMessage is used to publish a message to the given queue
+func (b *MemoryBroker) PublishMessage(queueName string, body interface{}) error {
+	bodyMarshaled, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return b.enqueue(Delivery{
+		Queue:       queueName,
+		ContentType: "text/plain",
+		Timestamp:   time.Now(),
+		Body:        bodyMarshaled,
+	})
+}
+
+// PublishMessageWithExchange is used to publish a message to the queues bound to the given exchange
+func (b *MemoryBroker) PublishMessageWithExchange(body interface{}, exchangeName string) error {
+	switch exchangeName {
+	case PinExchange:
+		return b.PublishMessage(IpfsPinQueue, body)
+	case PinRemovalExchange:
+		return b.PublishMessage(IpfsPinRemovalQueue, body)
+	case ClusterPinExchange:
+		// nothing consumes from the cluster pin exchange, so like rabbitmq the message is dropped
+		return nil
+	default:
+		return errors.New("invalid exchange name provided")
+	}
+}
+
+// Consume is used to start consuming messages from the queue, with at most
+// prefetch messages delivered to the consumer without being acknowledged
+func (b *MemoryBroker) Consume(queueName, consumer string, prefetch int) (<-chan Delivery, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.closed {
+		return nil, errors.New("broker is closed")
+	}
+	if _, ok := b.consumers[consumer]; ok {
+		return nil, errors.New("consumer already exists")
+	}
+	if prefetch <= 0 {
+		prefetch = 1
+	}
+	mc := &memoryConsumer{
+		queueName: queueName,
+		prefetch:  prefetch,
+		unacked:   make(map[uint64]Delivery),
+		cancelled: make(chan struct{}),
+	}
+	b.consumers[consumer] = mc
+	out := make(chan Delivery)
+	go b.dispatch(mc, out)
+	return out, nil
+}
+
+// Cancel is used to stop the consumer from receiving new messages. Messages
+// already delivered to the consumer may still be acknowledged
+func (b *MemoryBroker) Cancel(consumer string) error {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	mc, ok := b.consumers[consumer]
+	if !ok {
+		return errors.New("consumer does not exist")
+	}
+	delete(b.consumers, consumer)
+	close(mc.cancelled)
+	b.cond.Broadcast()
+	return nil
+}
+
+// RetryMessage is used to redeliver the message to its queue once the delay for the retry has passed
+func (b *MemoryBroker) RetryMessage(d Delivery, reason error) bool {
+	retry := retryCount(d.Headers) + 1
+	if retry > MaxRetries {
+		b.DeadLetterMessage(d, reason)
+		return false
+	}
+	retried := d
+	retried.Headers = failureHeaders(retry, reason)
+	time.AfterFunc(RetryDelay(retry), func() {
+		b.enqueue(retried)
+	})
+	d.Ack(false)
+	return true
+}
+
+// DeadLetterMessage is used to move the message to the dead-letters of its queue
+func (b *MemoryBroker) DeadLetterMessage(d Delivery, reason error) {
+	headers := failureHeaders(retryCount(d.Headers), reason)
+	b.mux.Lock()
+	b.deadLetters[d.Queue] = append(b.deadLetters[d.Queue], DeadLetter{
+		Queue:      d.Queue,
+		RetryCount: retryCount(d.Headers),
+		LastError:  headers[LastErrorHeader].(string),
+		Timestamp:  time.Now(),
+		Body:       string(d.Body),
+	})
+	b.mux.Unlock()
+	d.Ack(false)
+}
+
+// DeadLetters is used to retrieve the messages that have been dead-lettered for a queue
+func (b *MemoryBroker) DeadLetters(queueName string) []DeadLetter {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return append([]DeadLetter{}, b.deadLetters[queueName]...)
+}
+
+// Close is used to cancel every consumer, and stop accepting messages
+func (b *MemoryBroker) Close() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for consumer, mc := range b.consumers {
+		delete(b.consumers, consumer)
+		close(mc.cancelled)
+	}
+	b.cond.Broadcast()
+}
+
+// Ack is used to acknowledge a delivered message, or with multiple, every message
+// delivered to the consumer up to and including the given tag
+func (b *MemoryBroker) Ack(tag uint64, multiple bool) error {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	_, err := b.settle(tag, multiple)
+	return err
+}
+
+// Nack is used to reject a delivered message, returning it to the front of its queue
+// with requeue, or otherwise moving it to the dead-letters of the queue
+func (b *MemoryBroker) Nack(tag uint64, multiple, requeue bool) error {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	settled, err := b.settle(tag, multiple)
+	if err != nil {
+		return err
+	}
+	for _, d := range settled {
+		if requeue {
+			b.pending[d.Queue] = append([]Delivery{d}, b.pending[d.Queue]...)
+			continue
+		}
+		b.deadLetters[d.Queue] = append(b.deadLetters[d.Queue], DeadLetter{
+			Queue:      d.Queue,
+			RetryCount: retryCount(d.Headers),
+			LastError:  "message was rejected",
+			Timestamp:  time.Now(),
+			Body:       string(d.Body),
+		})
+	}
+	b.cond.Broadcast()
+	return nil
+}
+
+// settle is used to remove delivered messages from their consumer, returning the messages removed.
+// The lock must be held by the caller
+func (b *MemoryBroker) settle(tag uint64, multiple bool) ([]Delivery, error) {
+	mc, ok := b.unacked[tag]
+	if !ok {
+		return nil, errors.New("unknown delivery tag")
+	}
+	settled := []Delivery{}
+	for t, d := range mc.unacked {
+		if t == tag || (multiple && t < tag) {
+			settled = append(settled, d)
+			delete(mc.unacked, t)
+			delete(b.unacked, t)
+		}
+	}
+	b.cond.Broadcast()
+	return settled, nil
+}
+
+// enqueue is used to add a message to the back of its queue
+func (b *MemoryBroker) enqueue(d Delivery) error {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.closed {
+		return errors.New("broker is closed")
+	}
+	b.pending[d.Queue] = append(b.pending[d.Queue], d)
+	b.cond.Broadcast()
+	return nil
+}
+
+// dispatch is used to deliver messages from the queue to the consumer until it is cancelled
+func (b *MemoryBroker) dispatch(mc *memoryConsumer, out chan<- Delivery) {
+	defer close(out)
+	for {
+		b.mux.Lock()
+		for !isCancelled(mc) && (len(b.pending[mc.queueName]) == 0 || len(mc.unacked) >= mc.prefetch) {
+			b.cond.Wait()
+		}
+		if isCancelled(mc) {
+			b.mux.Unlock()
+			return
+		}
+		d := b.pending[mc.queueName][0]
+		b.pending[mc.queueName] = b.pending[mc.queueName][1:]
+		b.lastTag++
+		d.Acknowledger = b
+		d.DeliveryTag = b.lastTag
+		mc.unacked[d.DeliveryTag] = d
+		b.unacked[d.DeliveryTag] = mc
+		b.mux.Unlock()
+		select {
+		case out <- d:
+		case <-mc.cancelled:
+			// the message never reached the consumer, so return it to the queue
+			b.Nack(d.DeliveryTag, false, true)
+			return
+		}
+	}
+}
+
+func isCancelled(mc *memoryConsumer) bool {
+	select {
+	case <-mc.cancelled:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,188 @@
+package queue
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/RTradeLtd/Temporal/config"
+	"github.com/jinzhu/gorm"
+)
+
+// ConsumeMessages is used to consume messages that are sent to the queue with the given broker.
+// Messages failing due to a temporary error are retried with RetryMessage, and
+// messages that can't be processed are moved to the dead-letter queue with DeadLetterMessage
+func ConsumeMessages(broker Broker, queueName, consumer string, db *gorm.DB, cfg *config.TemporalConfig) error {
+	if consumer == "" {
+		// a consumer tag is needed to cancel the consumer when draining
+		consumer = fmt.Sprintf("%s-%v", queueName, os.Getpid())
+	}
+	settings := consumerSettings(cfg, queueName)
+	// upon SIGTERM we stop receiving new messages, and wait for the workers to finish those in-flight
+	draining := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(signals)
+	go func() {
+		<-signals
+		fmt.Println("shutdown requested, draining in-flight messages")
+		close(draining)
+		if err := broker.Cancel(consumer); err != nil {
+			fmt.Println("error cancelling consumer ", err)
+		}
+	}()
+	for {
+		msgs, err := broker.Consume(queueName, consumer, settings.Prefetch)
+		if err != nil {
+			return err
+		}
+		if err = processMessages(broker, queueName, msgs, db, cfg, settings.Concurrency); err != nil {
+			return err
+		}
+		select {
+		case <-draining:
+			fmt.Println("in-flight messages drained, shutting down")
+			return nil
+		default:
+			// the deliveries channel is closed once the broker loses its connection
+			fmt.Println("lost connection to broker")
+		}
+	}
+}
+
+// consumerSettings is used to load the consumer settings for a queue, falling back to
+// processing one message at a time
+func consumerSettings(cfg *config.TemporalConfig, queueName string) config.ConsumerConfig {
+	settings := cfg.RabbitMQ.Consumers[queueName]
+	if settings.Concurrency <= 0 {
+		settings.Concurrency = DefaultConsumerConcurrency
+	}
+	if settings.Prefetch <= 0 {
+		// anything less than the concurrency would leave workers idle
+		settings.Prefetch = settings.Concurrency
+	}
+	return settings
+}
+
+// processMessages is used to process messages with a pool of workers for the queue, returning once the
+// deliveries channel has been closed and every worker has finished. When a worker fails, the other
+// workers are stopped, and the error of the first failure is returned once they have all finished
+func processMessages(broker Broker, queueName string, msgs <-chan Delivery, db *gorm.DB, cfg *config.TemporalConfig, concurrency int) error {
+	stop := make(chan struct{})
+	done := make(chan error, concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			done <- runWorker(broker, queueName, msgs, stop, db, cfg)
+		}()
+	}
+	var failure error
+	for i := 0; i < concurrency; i++ {
+		if err := <-done; err != nil && failure == nil {
+			failure = err
+			close(stop)
+		}
+	}
+	return failure
+}
+
+// runWorker is used to process messages with the worker for the queue. Workers share the deliveries
+// channel, so running several of them lets us process messages in parallel. The worker stops receiving
+// messages once stop is closed, returning after the message it is processing is finished
+func runWorker(broker Broker, queueName string, msgs <-chan Delivery, stop <-chan struct{}, db *gorm.DB, cfg *config.TemporalConfig) error {
+	finished := make(chan struct{})
+	defer close(finished)
+	msgs = receiveUntil(msgs, stop, finished)
+	var err error
+	// check the queue name
+	switch queueName {
+	// only parse database pin requests
+	case DatabasePinAddQueue:
+		ProcessDatabasePinAdds(msgs, broker, db)
+	// only parse datbase file requests
+	case DatabaseFileAddQueue:
+		ProcessDatabaseFileAdds(msgs, broker, db)
+	case IpfsPinQueue:
+		ProccessIPFSPins(msgs, broker, db, cfg)
+	case IpfsFileQueue:
+		err = ProccessIPFSFiles(msgs, broker, cfg, db)
+		if err != nil {
+			return err
+		}
+	case PinPaymentConfirmationQueue:
+		err = ProcessPinPaymentConfirmation(msgs, broker, db, cfg.Ethereum.Connection.IPC.Path, cfg.Ethereum.Contracts.PaymentContractAddress, cfg)
+		if err != nil {
+			return err
+		}
+	case PinPaymentSubmissionQueue:
+		err = ProcessPinPaymentSubmissions(msgs, broker, db, cfg.Ethereum.Connection.IPC.Path, cfg.Ethereum.Contracts.PaymentContractAddress)
+		if err != nil {
+			return err
+		}
+	case EmailSendQueue:
+		fmt.Println("processing mail sends")
+		err = ProcessMailSends(msgs, broker, cfg)
+		if err != nil {
+			return err
+		}
+	case IpnsEntryQueue:
+		fmt.Println("processing IPNS entry creation requests")
+		err = ProcessIPNSEntryCreationRequests(msgs, broker, db, cfg)
+		if err != nil {
+			return err
+		}
+	case IpfsPinRemovalQueue:
+		fmt.Println("processing ipfs pin removals")
+		err = ProcessIPFSPinRemovals(msgs, broker, cfg, db)
+		if err != nil {
+			return err
+		}
+	case WebhookSendQueue:
+		fmt.Println("processing webhook sends")
+		err = ProcessWebhookSends(msgs, broker, db)
+		if err != nil {
+			return err
+		}
+	default:
+		log.Fatal("invalid queue name")
+	}
+	return nil
+}
+
+// receiveUntil is used to pass deliveries on to a worker until either stop or finished is closed, closing
+// the returned channel so that the worker stops. A delivery received as the worker stops is requeued
+func receiveUntil(msgs <-chan Delivery, stop, finished <-chan struct{}) <-chan Delivery {
+	out := make(chan Delivery)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-finished:
+				return
+			case d, ok := <-msgs:
+				if !ok {
+					return
+				}
+				select {
+				case out <- d:
+				case <-stop:
+					requeue(d)
+					return
+				case <-finished:
+					requeue(d)
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+func requeue(d Delivery) {
+	if err := d.Nack(false, true); err != nil {
+		fmt.Println("error requeueing message ", err)
+	}
+}
@@ -3,124 +3,92 @@ package queue
 import (
 	"encoding/json"
 	"fmt"
-	"strconv"
 	"time"
 
 	"github.com/RTradeLtd/Temporal/models"
 	"github.com/jinzhu/gorm"
-	"github.com/streadway/amqp"
 )
 
 var nilTime time.Time
 
 // ProcessDatabaseFileAdds is used to process database file add messages
-func ProcessDatabaseFileAdds(msgs <-chan amqp.Delivery, db *gorm.DB) {
+func ProcessDatabaseFileAdds(msgs <-chan Delivery, broker Broker, db *gorm.DB) {
+	uploadManager := models.NewUploadManager(db)
 	for d := range msgs {
 		if d.Body != nil {
 			if d.Body != nil {
 				dfa := DatabaseFileAdd{}
-				upload := models.Upload{}
 				// unmarshal the message body into the dfa struct
 				err := json.Unmarshal(d.Body, &dfa)
 				if err != nil {
-					d.Ack(false)
+					broker.DeadLetterMessage(d, err)
 					continue
 				}
-				// convert the int64 to an int. We need to make sure to add a check that we won't overflow
-				holdTime, err := strconv.Atoi(fmt.Sprintf("%v", dfa.HoldTimeInMonths))
-				if err != nil {
-					d.Ack(false)
-					continue
-				}
-				// we will take the current time, and add the number of months to get the date
-				// that we will garbage collect this from our repo
-				gcd := time.Now().AddDate(0, holdTime, 0)
-				upload.Hash = dfa.Hash
-				upload.HoldTimeInMonths = dfa.HoldTimeInMonths
-				upload.Type = "file"
-				upload.UploadAddress = dfa.UploaderAddress
-				upload.NetworkName = dfa.NetworkName
-				upload.GarbageCollectDate = gcd
-				lastUpload := models.Upload{}
-				if check := db.Where("hash = ? AND network_name = ?", upload.Hash, upload.NetworkName).Last(&lastUpload); check.Error != nil && check.Error != gorm.ErrRecordNotFound {
-					//TODO: add error handling
-					fmt.Println("Error ", check.Error)
-					d.Ack(false)
-					continue
-				}
-				// check the garbage collect dates, if the current upload to be pinned will be
-				// GCd before the latest one from the database, we will skip it
-				// however if it will be GCd at a later date, we will keep it
-				// and update the database
-				if lastUpload.GarbageCollectDate.Unix() > upload.GarbageCollectDate.Unix() {
-					upload.GarbageCollectDate = lastUpload.GarbageCollectDate
+				uploadType := dfa.UploadType
+				if uploadType == "" {
+					uploadType = models.UploadTypeFile
 				}
-				upload.UploaderAddresses = append(lastUpload.UploaderAddresses, dfa.UploaderAddress)
 				fmt.Println("Saving in database")
-				if check := db.Save(&upload); check.Error != nil {
-					//TOOD add error handling
-					fmt.Println("error ", check.Error)
-					d.Ack(false)
+				if err = saveUpload(uploadManager, dfa.Hash, uploadType, dfa.NetworkName, dfa.UploaderAddress, dfa.HoldTimeInMonths); err != nil {
+					fmt.Println("error ", err)
+					broker.RetryMessage(d, err)
 					continue
 				}
+				saveFileName(uploadManager, dfa.Hash, dfa.NetworkName, dfa.UploaderAddress, dfa.FileName)
 				fmt.Println("record saved")
+				d.Ack(false)
 			}
 		}
 	}
 }
 
 // ProcessDatabasePinAdds is used to process database file add messages
-func ProcessDatabasePinAdds(msgs <-chan amqp.Delivery, db *gorm.DB) {
+func ProcessDatabasePinAdds(msgs <-chan Delivery, broker Broker, db *gorm.DB) {
+	uploadManager := models.NewUploadManager(db)
 	for d := range msgs {
 		if d.Body != nil {
 			if d.Body != nil {
 				dpa := DatabasePinAdd{}
-				upload := models.Upload{}
 				// unmarshal the message body into the dfa struct
 				err := json.Unmarshal(d.Body, &dpa)
 				if err != nil {
-					d.Ack(false)
-					continue
-				}
-				// convert the int64 to an int. We need to make sure to add a check that we won't overflow
-				holdTime, err := strconv.Atoi(fmt.Sprintf("%v", dpa.HoldTimeInMonths))
-				if err != nil {
-					d.Ack(false)
-					continue
-				}
-				// we will take the current time, and add the number of months to get the date
-				// that we will garbage collect this from our repo
-				gcd := time.Now().AddDate(0, holdTime, 0)
-				upload.Hash = dpa.Hash
-				upload.HoldTimeInMonths = dpa.HoldTimeInMonths
-				upload.Type = "pin"
-				upload.UploadAddress = dpa.UploaderAddress
-				upload.NetworkName = dpa.NetworkName
-				upload.GarbageCollectDate = gcd
-				lastUpload := models.Upload{}
-				if check := db.Where("hash = ? AND network_name = ?", upload.Hash, upload.NetworkName).Last(&lastUpload); check.Error != nil && check.Error != gorm.ErrRecordNotFound {
-					//TODO: add error handling
-					fmt.Println("Error ", check.Error)
-					d.Ack(false)
+					broker.DeadLetterMessage(d, err)
 					continue
 				}
-				// check the garbage collect dates, if the current upload to be pinned will be
-				// GCd before the latest one from the database, we will skip it
-				// however if it will be GCd at a later date, we will keep it
-				// and update the database
-				if lastUpload.GarbageCollectDate.Unix() > upload.GarbageCollectDate.Unix() {
-					upload.GarbageCollectDate = lastUpload.GarbageCollectDate
-				}
-				upload.UploaderAddresses = append(lastUpload.UploaderAddresses, dpa.UploaderAddress)
 				fmt.Println("Saving in database")
-				if check := db.Save(&upload); check.Error != nil {
-					//TOOD add error handling
-					fmt.Println("error ", check.Error)
-					d.Ack(false)
+				if err = saveUpload(uploadManager, dpa.Hash, "pin", dpa.NetworkName, dpa.UploaderAddress, dpa.HoldTimeInMonths); err != nil {
+					fmt.Println("error ", err)
+					broker.RetryMessage(d, err)
 					continue
 				}
 				fmt.Println("record saved")
+				d.Ack(false)
 			}
 		}
 	}
 }
+
+// saveUpload is used to store an upload, adding the uploader as an owner of the upload if it already exists
+func saveUpload(um *models.UploadManager, hash, uploadType, networkName, uploaderAddress string, holdTimeInMonths int64) error {
+	_, err := um.FindUploadByHashAndNetwork(hash, networkName)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	if err == gorm.ErrRecordNotFound {
+		_, err = um.NewUpload(hash, uploadType, networkName, uploaderAddress, "", holdTimeInMonths)
+		return err
+	}
+	_, err = um.UpdateUpload(holdTimeInMonths, uploaderAddress, hash, networkName, "")
+	return err
+}
+
+// saveFileName is used to store the name a file was uploaded with. The name is only used
+// for downloads, so failures are logged rather than failing the upload
+func saveFileName(um *models.UploadManager, hash, networkName, uploaderAddress, fileName string) {
+	if fileName == "" {
+		return
+	}
+	if err := um.SetUploadFileName(uploaderAddress, hash, networkName, fileName); err != nil {
+		fmt.Println("error saving file name ", err)
+	}
+}
@@ -0,0 +1,344 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+/*
+Every queue has a dead-letter exchange, a set of retry queues and a terminal dead-letter queue.
+
+Messages failing due to a possibly temporary error are published to the retry queue matching
+their retry count. Retry queues have no consumers, and once the message ttl of the retry queue
+expires, the message is dead-lettered back into the original queue. Each retry queue has a
+longer ttl than the previous one, giving us an exponential backoff.
+
+Messages that have exhausted their retries, or which can never be processed, are published to the
+dead-letter exchange of the queue which routes them to the dead-letter queue. Here they remain
+until an admin inspects, replays or purges them.
+*/
+
+const (
+	// RetryCountHeader is the header used to track how many times a message has been retried
+	RetryCountHeader = "x-retry-count"
+	// LastErrorHeader is the header used to record why a message was last retried or dead-lettered
+	LastErrorHeader = "x-last-error"
+)
+
+var (
+	// MaxRetries is the number of times a message will be retried before being dead-lettered
+	MaxRetries = 5
+	// RetryBaseDelay is the delay before the first retry, doubling on every subsequent retry
+	RetryBaseDelay = time.Second * 5
+)
+
+// DeadLetter is a message that has been dead-lettered
+type DeadLetter struct {
+	Queue      string    `json:"queue"`
+	RetryCount int       `json:"retry_count"`
+	LastError  string    `json:"last_error"`
+	Timestamp  time.Time `json:"timestamp"`
+	Body       string    `json:"body"`
+}
+
+// ConsumerQueues are the queues we run consumers for, and which dead-letters may be managed for
+var ConsumerQueues = []string{
+	DatabaseFileAddQueue,
+	DatabasePinAddQueue,
+	IpfsPinQueue,
+	IpfsFileQueue,
+	PinPaymentConfirmationQueue,
+	PinPaymentSubmissionQueue,
+	EmailSendQueue,
+	IpnsEntryQueue,
+	IpfsPinRemovalQueue,
+	WebhookSendQueue,
+}
+
+// IsConsumerQueue is used to check whether or not the given queue is one we consume from
+func IsConsumerQueue(queueName string) bool {
+	for _, v := range ConsumerQueues {
+		if v == queueName {
+			return true
+		}
+	}
+	return false
+}
+
+// DeadLetterExchangeName is used to get the name of the dead-letter exchange for a queue
+func DeadLetterExchangeName(queueName string) string {
+	return queueName + ".dlx"
+}
+
+// DeadLetterQueueName is used to get the name of the dead-letter queue for a queue
+func DeadLetterQueueName(queueName string) string {
+	return queueName + ".dead"
+}
+
+// RetryQueueName is used to get the name of the queue holding messages for the given retry
+func RetryQueueName(queueName string, retry int) string {
+	return fmt.Sprintf("%s.retry.%v", queueName, retry)
+}
+
+// RetryDelay is used to get how long a message waits before the given retry
+func RetryDelay(retry int) time.Duration {
+	return RetryBaseDelay * time.Duration(1<<uint(retry-1))
+}
+
+// declareDeadLetterTopology is used to declare the dead-letter exchange, dead-letter queue and retry queues for a queue
+func (qm *QueueManager) declareDeadLetterTopology(queueName string) error {
+	err := qm.Channel.ExchangeDeclare(
+		DeadLetterExchangeName(queueName), // name
+		"direct",                          // type
+		true,                              // durable
+		false,                             // auto-delete
+		false,                             // internal
+		false,                             // no wait
+		nil,                               // args
+	)
+	if err != nil {
+		return err
+	}
+	_, err = qm.Channel.QueueDeclare(
+		DeadLetterQueueName(queueName), // name
+		true,                           // durable
+		false,                          // delete when unused
+		false,                          // exclusive
+		false,                          // no-wait
+		nil,                            // arguments
+	)
+	if err != nil {
+		return err
+	}
+	err = qm.Channel.QueueBind(
+		DeadLetterQueueName(queueName),    // name of the queue
+		queueName,                         // bindingKey
+		DeadLetterExchangeName(queueName), // sourceExchange
+		false,                             // noWait
+		nil,                               // arguments
+	)
+	if err != nil {
+		return err
+	}
+	for retry := 1; retry <= MaxRetries; retry++ {
+		_, err = qm.Channel.QueueDeclare(
+			RetryQueueName(queueName, retry), // name
+			true,                             // durable
+			false,                            // delete when unused
+			false,                            // exclusive
+			false,                            // no-wait
+			amqp.Table{
+				"x-message-ttl":             int64(RetryDelay(retry) / time.Millisecond),
+				"x-dead-letter-exchange":    "",
+				"x-dead-letter-routing-key": queueName,
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RetryMessage is used to retry a message that failed due to a possibly temporary error.
+// Once a message has exhausted its retries it is dead-lettered, in which case false is returned
+func (qm *QueueManager) RetryMessage(d Delivery, reason error) bool {
+	retry := retryCount(d.Headers) + 1
+	if retry > MaxRetries {
+		qm.DeadLetterMessage(d, reason)
+		return false
+	}
+	err := qm.Channel.Publish(
+		"",                                   // exchange
+		RetryQueueName(qm.Queue.Name, retry), // routing key
+		false,                                // mandatory
+		false,                                // immediate
+		amqp.Publishing{
+			Headers:      failureHeaders(retry, reason),
+			DeliveryMode: amqp.Persistent,
+			ContentType:  d.ContentType,
+			Body:         d.Body,
+		},
+	)
+	if err != nil {
+		// leave the message with rabbitmq so that it is redelivered
+		fmt.Println("error publishing message for retry ", err)
+		d.Nack(false, true)
+		return true
+	}
+	d.Ack(false)
+	return true
+}
+
+// DeadLetterMessage is used to move a message that can't be processed to the dead-letter queue
+func (qm *QueueManager) DeadLetterMessage(d Delivery, reason error) {
+	err := qm.Channel.Publish(
+		DeadLetterExchangeName(qm.Queue.Name), // exchange
+		qm.Queue.Name,                         // routing key
+		false,                                 // mandatory
+		false,                                 // immediate
+		amqp.Publishing{
+			Headers:      failureHeaders(retryCount(d.Headers), reason),
+			DeliveryMode: amqp.Persistent,
+			ContentType:  d.ContentType,
+			Timestamp:    time.Now(),
+			Body:         d.Body,
+		},
+	)
+	if err != nil {
+		// rejecting the message will have rabbitmq route it to the dead-letter exchange with the
+		// dead-letter policy of the queue, although we lose the reason it was dead-lettered
+		fmt.Println("error publishing message to dead-letter exchange ", err)
+		d.Nack(false, false)
+		return
+	}
+	d.Ack(false)
+}
+
+// InspectDeadLetters is used to view up to limit dead-lettered messages without removing them
+func (qm *QueueManager) InspectDeadLetters(limit int) ([]DeadLetter, error) {
+	deadLetters := []DeadLetter{}
+	var last *amqp.Delivery
+	for i := 0; i < limit; i++ {
+		d, ok, err := qm.Channel.Get(DeadLetterQueueName(qm.Queue.Name), false)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		deadLetters = append(deadLetters, toDeadLetter(qm.Queue.Name, d))
+		last = &d
+	}
+	if last != nil {
+		// return all of the messages we have retrieved back to the dead-letter queue
+		if err := last.Nack(true, true); err != nil {
+			return nil, err
+		}
+	}
+	return deadLetters, nil
+}
+
+// ReplayDeadLetters is used to send up to limit dead-lettered messages back to the queue
+// with their retry count reset, returning the number of messages replayed
+func (qm *QueueManager) ReplayDeadLetters(limit int) (int, error) {
+	replayed := 0
+	for replayed < limit {
+		d, ok, err := qm.Channel.Get(DeadLetterQueueName(qm.Queue.Name), false)
+		if err != nil {
+			return replayed, err
+		}
+		if !ok {
+			break
+		}
+		err = qm.Channel.Publish(
+			"",            // exchange
+			qm.Queue.Name, // routing key
+			false,         // mandatory
+			false,         // immediate
+			amqp.Publishing{
+				DeliveryMode: amqp.Persistent,
+				ContentType:  d.ContentType,
+				Body:         d.Body,
+			},
+		)
+		if err != nil {
+			d.Nack(false, true)
+			return replayed, err
+		}
+		if err = d.Ack(false); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+	return replayed, nil
+}
+
+// PurgeDeadLetters is used to remove all dead-lettered messages, returning the number of messages removed
+func (qm *QueueManager) PurgeDeadLetters() (int, error) {
+	return qm.Channel.QueuePurge(DeadLetterQueueName(qm.Queue.Name), false)
+}
+
+func failureHeaders(retry int, reason error) amqp.Table {
+	if reason == nil {
+		reason = errors.New("unknown error")
+	}
+	return amqp.Table{
+		RetryCountHeader: int32(retry),
+		LastErrorHeader:  reason.Error(),
+	}
+}
+
+func retryCount(headers map[string]interface{}) int {
+	switch count := headers[RetryCountHeader].(type) {
+	case int32:
+		return int(count)
+	case int64:
+		return int(count)
+	case int:
+		return count
+	default:
+		return 0
+	}
+}
+
+func toDeadLetter(queueName string, d amqp.Delivery) DeadLetter {
+	lastError, _ := d.Headers[LastErrorHeader].(string)
+	return DeadLetter{
+		Queue:      queueName,
+		RetryCount: retryCount(d.Headers),
+		LastError:  lastError,
+		Timestamp:  d.Timestamp,
+		Body:       string(d.Body),
+	}
+}
+
+// DefaultManagementPort is the port of the rabbitmq management api, used when no management url is configured
+const DefaultManagementPort = "15672"
+
+// CheckDeadLetterPolicy is used to check, with the rabbitmq management api, that messages rejected by rabbitmq
+// are dead-lettered to the dead-letter exchange of the queue, with the policies set by
+// scripts/rabbitmq_dead_letter_policies.sh. When managementURL is empty, the management api is expected on the
+// host of the connection url, and it is authenticated with the credentials of the connection url
+func CheckDeadLetterPolicy(connectionURL, managementURL, queueName string) error {
+	uri, err := amqp.ParseURI(connectionURL)
+	if err != nil {
+		return err
+	}
+	if managementURL == "" {
+		managementURL = fmt.Sprintf("http://%s:%s", uri.Host, DefaultManagementPort)
+	}
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/queues/%s/%s",
+		strings.TrimSuffix(managementURL, "/"), url.PathEscape(uri.Vhost), url.PathEscape(queueName)), nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(uri.Username, uri.Password)
+	client := &http.Client{Timeout: time.Second * 10}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to check the dead-letter policy of %s: %s", queueName, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to check the dead-letter policy of %s: management api responded with %s", queueName, resp.Status)
+	}
+	info := make(map[string]interface{})
+	if err = json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return err
+	}
+	// queues without a policy have an empty list rather than an object
+	definition, _ := info["effective_policy_definition"].(map[string]interface{})
+	if definition["dead-letter-exchange"] != DeadLetterExchangeName(queueName) {
+		return fmt.Errorf("queue %s has no policy dead-lettering to %s, run scripts/rabbitmq_dead_letter_policies.sh to set it",
+			queueName, DeadLetterExchangeName(queueName))
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package queue
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 
@@ -11,38 +12,36 @@ import (
 
 	"github.com/RTradeLtd/Temporal/config"
 	"github.com/RTradeLtd/Temporal/rtfs"
+	"github.com/RTradeLtd/Temporal/rtfs_cluster"
 
 	"github.com/RTradeLtd/Temporal/models"
 	"github.com/jinzhu/gorm"
-	"github.com/streadway/amqp"
 )
 
 // ProccessIPFSPins is used to process IPFS pin requests
-func ProccessIPFSPins(msgs <-chan amqp.Delivery, db *gorm.DB, cfg *config.TemporalConfig) error {
+func ProccessIPFSPins(msgs <-chan Delivery, broker Broker, db *gorm.DB, cfg *config.TemporalConfig) error {
 	userManager := models.NewUserManager(db)
 	//uploadManager := models.NewUploadManager(db)
 	networkManager := models.NewHostedIPFSNetworkManager(db)
 	uploadManager := models.NewUploadManager(db)
-	qm, err := Initialize(EmailSendQueue, cfg.RabbitMQ.URL)
-	if err != nil {
-		return err
-	}
+	pinRequestManager := models.NewPinRequestManager(db)
+	jobManager := models.NewJobManager(db)
 	for d := range msgs {
 		pin := &IPFSPin{}
 		err := json.Unmarshal(d.Body, pin)
 		if err != nil {
-			//TODO log and handle
 			fmt.Println(err)
-			d.Ack(false)
+			broker.DeadLetterMessage(d, err)
 			continue
 		}
+		startJob(jobManager, pin.JobID)
 		apiURL := ""
 		if pin.NetworkName != "public" {
 			canAccess, err := userManager.CheckIfUserHasAccessToNetwork(pin.EthAddress, pin.NetworkName)
 			if err != nil {
-				//TODO log and handle
 				fmt.Println("error checking for private network access", err)
-				d.Ack(false)
+				failPin(broker, jobManager, pinRequestManager, pin, err)
+				broker.DeadLetterMessage(d, err)
 				continue
 			}
 			if !canAccess {
@@ -54,13 +53,14 @@ func ProccessIPFSPins(msgs <-chan amqp.Delivery, db *gorm.DB, cfg *config.Tempor
 					ContentType:  "",
 					EthAddresses: addresses,
 				}
-				err = qm.PublishMessage(es)
+				err = broker.PublishMessage(EmailSendQueue, es)
 				if err != nil {
 					//TODO log and handle
 					fmt.Println(err)
 				}
 				//TODO log 	and handle
 				fmt.Println("unauthorized access to private net ", pin.NetworkName)
+				failPin(broker, jobManager, pinRequestManager, pin, errors.New("unauthorized access to private network"))
 				d.Ack(false)
 				continue
 			}
@@ -68,7 +68,8 @@ func ProccessIPFSPins(msgs <-chan amqp.Delivery, db *gorm.DB, cfg *config.Tempor
 			if err != nil {
 				//TODO: decide if we should send out an email
 				fmt.Println(err)
-				d.Ack(false)
+				failPin(broker, jobManager, pinRequestManager, pin, err)
+				broker.DeadLetterMessage(d, err)
 				continue
 			}
 			apiURL = url
@@ -83,16 +84,16 @@ func ProccessIPFSPins(msgs <-chan amqp.Delivery, db *gorm.DB, cfg *config.Tempor
 				ContentType:  "",
 				EthAddresses: addresses,
 			}
-			errOne := qm.PublishMessage(es)
+			errOne := broker.PublishMessage(EmailSendQueue, es)
 			if errOne != nil {
-				// For this, we will not ack since we want to be able to send messages
 				fmt.Println("error publishing message ", err)
-				continue
 			}
 			fmt.Println(err)
-			d.Ack(false)
+			failPin(broker, jobManager, pinRequestManager, pin, err)
+			broker.DeadLetterMessage(d, err)
 			continue
 		}
+		updatePinRequestStatus(pinRequestManager, pin, models.PinStatusPinning)
 		err = ipfsManager.Pin(pin.CID)
 		if err != nil {
 			addresses := []string{}
@@ -103,73 +104,121 @@ func ProccessIPFSPins(msgs <-chan amqp.Delivery, db *gorm.DB, cfg *config.Tempor
 				ContentType:  "",
 				EthAddresses: addresses,
 			}
-			errOne := qm.PublishMessage(es)
+			errOne := broker.PublishMessage(EmailSendQueue, es)
 			if errOne != nil {
 				fmt.Println("error publishing message ", err)
 			}
-			//TODO log and handle
-			// we aren't acknowlding this since it could be a temporary failure
+			// this could be a temporary failure, so we will retry
 			fmt.Println(err)
 			fmt.Println("error pinning to network ", pin.NetworkName)
+			if broker.RetryMessage(d, err) {
+				retryJob(jobManager, pin.JobID, err)
+			} else {
+				failPin(broker, jobManager, pinRequestManager, pin, err)
+			}
 			continue
 		}
 		_, err = uploadManager.FindUploadByHashAndNetwork(pin.CID, pin.NetworkName)
 		if err != nil && err != gorm.ErrRecordNotFound {
 			fmt.Println("error getting model from database ", err)
-			// decide what to do here
-			d.Ack(false)
+			failPin(broker, jobManager, pinRequestManager, pin, err)
+			broker.DeadLetterMessage(d, err)
 			continue
 		}
 		if err == gorm.ErrRecordNotFound {
-			_, check := uploadManager.NewUpload(pin.CID, "pin", pin.NetworkName, pin.EthAddress, pin.HoldTimeInMonths)
+			_, check := uploadManager.NewUpload(pin.CID, "pin", pin.NetworkName, pin.EthAddress, pin.PaymentNumber, pin.HoldTimeInMonths)
 			if check != nil {
 				fmt.Println("error creating new upload ", check)
 				// decide what to do ehre, who we should email, etcc...
-				d.Ack(false)
+				failPin(broker, jobManager, pinRequestManager, pin, check)
+				broker.DeadLetterMessage(d, check)
 				continue
 			}
+			updatePinRequestStatus(pinRequestManager, pin, models.PinStatusPinned)
+			publishPinWebhookEvent(broker, models.WebhookEventPinCompleted, pin, "")
+			completeJob(jobManager, pin.JobID, pin.CID)
 			d.Ack(false)
 			continue
 		}
 		// the record already exists so we will update
-		_, err = uploadManager.UpdateUpload(pin.HoldTimeInMonths, pin.EthAddress, pin.CID, pin.NetworkName)
+		_, err = uploadManager.UpdateUpload(pin.HoldTimeInMonths, pin.EthAddress, pin.CID, pin.NetworkName, pin.PaymentNumber)
 		if err != nil {
 			fmt.Println("error updating model in database ", err)
 			// TODO: decide what to do, who we should email, etcc
-			d.Ack(false)
+			failPin(broker, jobManager, pinRequestManager, pin, err)
+			broker.DeadLetterMessage(d, err)
 			continue
 		}
+		updatePinRequestStatus(pinRequestManager, pin, models.PinStatusPinned)
+		publishPinWebhookEvent(broker, models.WebhookEventPinCompleted, pin, "")
+		completeJob(jobManager, pin.JobID, pin.CID)
 		d.Ack(false)
 	}
 	return nil
 }
 
+// updatePinRequestStatus is used to update any pinning service requests associated with a pin.
+// Failures are only logged, as pins do not need to originate from the pinning service api
+func updatePinRequestStatus(pm *models.PinRequestManager, pin *IPFSPin, status string) {
+	err := pm.UpdatePinRequestStatus(pin.EthAddress, pin.CID, pin.NetworkName, status)
+	if err != nil {
+		fmt.Println("error updating pin request status ", err)
+	}
+}
+
+// failPin is used to mark a pin as failed once it will not be retried, on both its job and any pinning
+// service requests, which would otherwise be polled forever, and to notify webhooks of the failure
+func failPin(p MessagePublisher, jm *models.JobManager, pm *models.PinRequestManager, pin *IPFSPin, err error) {
+	updatePinRequestStatus(pm, pin, models.PinStatusFailed)
+	publishPinWebhookEvent(p, models.WebhookEventPinFailed, pin, err.Error())
+	failJob(jm, pin.JobID, err)
+}
+
+// publishPinWebhookEvent is used to notify webhooks of the outcome of a pin, with reason being set for failures
+func publishPinWebhookEvent(p MessagePublisher, event string, pin *IPFSPin, reason string) {
+	data := map[string]interface{}{
+		"cid":          pin.CID,
+		"network_name": pin.NetworkName,
+		"job_id":       pin.JobID,
+	}
+	if reason != "" {
+		data["reason"] = reason
+	}
+	publishWebhookEvent(p, event, pin.EthAddress, data)
+}
+
+const (
+	// PinRemovalReleased is the result of a pin removal job when other users still hold the content,
+	// so only the user's claim on it was removed
+	PinRemovalReleased = "released"
+	// PinRemovalUnpinned is the result of a pin removal job when the content was unpinned
+	PinRemovalUnpinned = "unpinned"
+)
+
 // ProcessIPFSPinRemovals is used to listen for and process any IPFS pin removals.
-// This queue must be running on each of the IPFS nodes, and we must eventually run checks
-// to ensure that pins were actually removed
-func ProcessIPFSPinRemovals(msgs <-chan amqp.Delivery, cfg *config.TemporalConfig, db *gorm.DB) error {
+// A removal only releases the user's own claim on the content, which is unpinned from
+// the network, and the cluster for the public network, once no other user holds an active retention of it
+func ProcessIPFSPinRemovals(msgs <-chan Delivery, broker Broker, cfg *config.TemporalConfig, db *gorm.DB) error {
 	userManager := models.NewUserManager(db)
 	networkManager := models.NewHostedIPFSNetworkManager(db)
-	qmEmail, err := Initialize(EmailSendQueue, cfg.RabbitMQ.URL)
-	if err != nil {
-		return err
-	}
+	uploadManager := models.NewUploadManager(db)
+	jobManager := models.NewJobManager(db)
 	for d := range msgs {
 		rm := IPFSPinRemoval{}
 		err := json.Unmarshal(d.Body, &rm)
 		if err != nil {
-			//TODO: log and handle
 			fmt.Println("error unmarshaling ", err)
-			d.Ack(false)
+			broker.DeadLetterMessage(d, err)
 			continue
 		}
+		startJob(jobManager, rm.JobID)
 		apiURL := ""
 		if rm.NetworkName != "public" {
 			canAccess, err := userManager.CheckIfUserHasAccessToNetwork(rm.EthAddress, rm.NetworkName)
 			if err != nil {
-				//TODO: log and handle
 				fmt.Println("error checking for network access ", err)
-				d.Ack(false)
+				failJob(jobManager, rm.JobID, err)
+				broker.DeadLetterMessage(d, err)
 				continue
 			}
 			if !canAccess {
@@ -181,67 +230,136 @@ func ProcessIPFSPinRemovals(msgs <-chan amqp.Delivery, cfg *config.TemporalConfi
 					ContentType:  "",
 					EthAddresses: addresses,
 				}
-				err = qmEmail.PublishMessage(es)
+				err = broker.PublishMessage(EmailSendQueue, es)
 				if err != nil {
 					//TODO log and handle
 					fmt.Println(err)
 				}
 				//TODO log 	and handle
 				fmt.Println("unauthorized access to private net ", rm.NetworkName)
+				failJob(jobManager, rm.JobID, errors.New("unauthorized access to private network"))
 				d.Ack(false)
 				continue
 			}
 			apiURL, err = networkManager.GetAPIURLByName(rm.NetworkName)
 			if err != nil {
-				//TODO log and handle
 				fmt.Println("failed to get api url for private network ", err)
-				d.Ack(false)
+				failJob(jobManager, rm.JobID, err)
+				broker.DeadLetterMessage(d, err)
 				continue
 			}
 		}
-		ipfsManager, err := rtfs.Initialize("", apiURL)
-		if err != nil {
-			addresses := []string{rm.EthAddress}
-			es := EmailSend{
-				Subject:      IpfsInitializationFailedSubject,
-				Content:      fmt.Sprintf("Failed to connect to IPFS network %s for reason %s", rm.NetworkName, err),
-				ContentType:  "",
-				EthAddresses: addresses,
-			}
-			errOne := qmEmail.PublishMessage(es)
-			if errOne != nil {
-				fmt.Println("error publishing email to queue ", errOne)
-			}
-			fmt.Println("error connecting to IPFS network ", err)
+		// the content is only unpinned when no other user still holds it
+		unpin, err := uploadManager.ReleaseUpload(rm.EthAddress, rm.ContentHash, rm.NetworkName)
+		if err == gorm.ErrRecordNotFound {
+			// users may only release their own claim on content, and there is nothing to retry
+			fmt.Println("pin removal for content not owned by user ", rm.EthAddress)
+			failJob(jobManager, rm.JobID, errors.New("content is not pinned by this account"))
 			d.Ack(false)
 			continue
 		}
-		err = ipfsManager.Shell.Unpin(rm.ContentHash)
 		if err != nil {
+			fmt.Println("error releasing upload in database ", err)
+			retryOrFailJob(broker, jobManager, d, rm.JobID, err)
+			continue
+		}
+		if !unpin {
+			// other users are still paying to keep the content, so only the user's claim was removed
+			completeJob(jobManager, rm.JobID, PinRemovalReleased)
+			d.Ack(false)
+			continue
+		}
+		// the upload isn't locked while unpinning, so a retry releases the upload again and unpins it once more
+		if unpinErr := unpinContent(rm.ContentHash, rm.NetworkName, apiURL); unpinErr != nil {
 			addresses := []string{rm.EthAddress}
 			es := EmailSend{
 				Subject:      "Pin removal failed",
-				Content:      fmt.Sprintf("Pin removal failed for ipfs network %s due to reason %s", rm.NetworkName, err),
+				Content:      fmt.Sprintf("Pin removal failed for ipfs network %s due to reason %s", rm.NetworkName, unpinErr),
 				ContentType:  "",
 				EthAddresses: addresses,
 			}
-			errOne := qmEmail.PublishMessage(es)
+			errOne := broker.PublishMessage(EmailSendQueue, es)
 			if errOne != nil {
 				//TODO log and handle
 				fmt.Println("error publishing email to queue ", errOne)
 			}
-			fmt.Println("failed to remove content hash ", err)
+			fmt.Println("failed to remove content hash ", unpinErr)
+			retryOrFailJob(broker, jobManager, d, rm.JobID, unpinErr)
+			continue
+		}
+		removed, err := uploadManager.FinishRelease(rm.ContentHash, rm.NetworkName)
+		if err != nil {
+			fmt.Println("error removing released upload from database ", err)
+			retryOrFailJob(broker, jobManager, d, rm.JobID, err)
+			continue
+		}
+		if !removed {
+			// another user pinned the content while it was being unpinned, so it is pinned again for them
+			if err = repinContent(rm.ContentHash, apiURL); err != nil {
+				// the claim of the user is already gone, so a retry would find nothing to release
+				fmt.Println("error pinning released content again ", err)
+				failJob(jobManager, rm.JobID, err)
+				broker.DeadLetterMessage(d, err)
+				continue
+			}
+			completeJob(jobManager, rm.JobID, PinRemovalReleased)
 			d.Ack(false)
 			continue
 		}
+		completeJob(jobManager, rm.JobID, PinRemovalUnpinned)
+		d.Ack(false)
+	}
+	return nil
+}
+
+// unpinContent is used to unpin content from the ipfs node of a network, and from the cluster for the
+// public network. Content which is no longer pinned is skipped, so that removals can be retried
+func unpinContent(contentHash, networkName, apiURL string) error {
+	ipfsManager, err := rtfs.Initialize("", apiURL)
+	if err != nil {
+		return err
+	}
+	pinType, err := ipfsManager.PinLs(contentHash)
+	if err != nil {
+		return err
+	}
+	if pinType != "" {
+		if err = ipfsManager.Shell.Unpin(contentHash); err != nil {
+			return err
+		}
+	}
+	// only the public network is replicated with the cluster
+	if networkName != "public" {
+		return nil
+	}
+	clusterManager, err := rtfs_cluster.Initialize()
+	if err != nil {
+		return err
+	}
+	tracked, err := clusterManager.IsTracked(contentHash)
+	if err != nil {
+		return err
+	}
+	if tracked {
+		return clusterManager.RemovePinFromCluster(contentHash)
 	}
 	return nil
 }
 
+// repinContent is used to pin content on the ipfs node of a network again, once it was pinned by another
+// user while being unpinned
+func repinContent(contentHash, apiURL string) error {
+	ipfsManager, err := rtfs.Initialize("", apiURL)
+	if err != nil {
+		return err
+	}
+	return ipfsManager.Pin(contentHash)
+}
+
 // ProccessIPFSFiles is used to process messages sent to rabbitmq to upload files to IPFS.
 // This function is invoked with the advanced method of file uploads, and is significantly more resilient than
 // the simple file upload method.
-func ProccessIPFSFiles(msgs <-chan amqp.Delivery, cfg *config.TemporalConfig, db *gorm.DB) error {
+func ProccessIPFSFiles(msgs <-chan Delivery, broker Broker, cfg *config.TemporalConfig, db *gorm.DB) error {
 	// construct the endpoint url to access our minio server
 	endpoint := fmt.Sprintf("%s:%s", cfg.MINIO.Connection.IP, cfg.MINIO.Connection.Port)
 	// grab our credentials for minio
@@ -260,17 +378,10 @@ func ProccessIPFSFiles(msgs <-chan amqp.Delivery, cfg *config.TemporalConfig, db
 		return err
 	}
 	fmt.Println("minio connection setup")
-	qmFile, err := Initialize(IpfsFileQueue, cfg.RabbitMQ.URL)
-	if err != nil {
-		return err
-	}
-	qmEmail, err := Initialize(EmailSendQueue, cfg.RabbitMQ.URL)
-	if err != nil {
-		return err
-	}
 	userManager := models.NewUserManager(db)
 	networkManager := models.NewHostedIPFSNetworkManager(db)
 	uploadManager := models.NewUploadManager(db)
+	jobManager := models.NewJobManager(db)
 	// process any received messages
 	fmt.Println("processing ipfs file messages")
 	for d := range msgs {
@@ -279,11 +390,11 @@ func ProccessIPFSFiles(msgs <-chan amqp.Delivery, cfg *config.TemporalConfig, db
 		// unmarshal the messagee
 		err = json.Unmarshal(d.Body, &ipfsFile)
 		if err != nil {
-			//TODO: log and handle
 			fmt.Println(err)
-			d.Ack(false)
+			broker.DeadLetterMessage(d, err)
 			continue
 		}
+		startJob(jobManager, ipfsFile.JobID)
 		fmt.Println("determining network")
 		apiURL := ""
 		// determing private network access rights
@@ -292,7 +403,8 @@ func ProccessIPFSFiles(msgs <-chan amqp.Delivery, cfg *config.TemporalConfig, db
 			if err != nil {
 				//TODO log and handle, decide how we would do this
 				fmt.Println("error checking for private network access", err)
-				d.Ack(false)
+				failJob(jobManager, ipfsFile.JobID, err)
+				broker.DeadLetterMessage(d, err)
 				continue
 			}
 			if !canAccess {
@@ -304,13 +416,14 @@ func ProccessIPFSFiles(msgs <-chan amqp.Delivery, cfg *config.TemporalConfig, db
 					ContentType:  "",
 					EthAddresses: addresses,
 				}
-				err = qmEmail.PublishMessage(es)
+				err = broker.PublishMessage(EmailSendQueue, es)
 				if err != nil {
 					//TODO log and handle
 					fmt.Println(err)
 				}
 				//TODO log 	and handle
 				fmt.Println("unauthorized access to private net ", ipfsFile.NetworkName)
+				failJob(jobManager, ipfsFile.JobID, errors.New("unauthorized access to private network"))
 				d.Ack(false)
 				continue
 			}
@@ -318,7 +431,8 @@ func ProccessIPFSFiles(msgs <-chan amqp.Delivery, cfg *config.TemporalConfig, db
 			if err != nil {
 				//TODO send email, log, handle
 				fmt.Println("error getting API url by name ", err)
-				d.Ack(false)
+				failJob(jobManager, ipfsFile.JobID, err)
+				broker.DeadLetterMessage(d, err)
 				continue
 			}
 			apiURL = apiURLName
@@ -332,12 +446,13 @@ func ProccessIPFSFiles(msgs <-chan amqp.Delivery, cfg *config.TemporalConfig, db
 					ContentType:  "",
 					EthAddresses: addresses,
 				}
-				errOne := qmEmail.PublishMessage(es)
+				errOne := broker.PublishMessage(EmailSendQueue, es)
 				if errOne != nil {
 					fmt.Println("error publishing message ", err)
 				}
 				fmt.Println(err)
-				d.Ack(false)
+				failJob(jobManager, ipfsFile.JobID, err)
+				broker.DeadLetterMessage(d, err)
 				continue
 			}
 		}
@@ -348,7 +463,8 @@ func ProccessIPFSFiles(msgs <-chan amqp.Delivery, cfg *config.TemporalConfig, db
 		if err != nil {
 			//TODO: log and handle, should we email them when this fails?
 			fmt.Println(err)
-			d.Ack(false)
+			failJob(jobManager, ipfsFile.JobID, err)
+			broker.DeadLetterMessage(d, err)
 			continue
 		}
 		fmt.Println("file retrieved from minio")
@@ -365,20 +481,22 @@ func ProccessIPFSFiles(msgs <-chan amqp.Delivery, cfg *config.TemporalConfig, db
 				ContentType:  "",
 				EthAddresses: addresses,
 			}
-			errOne := qmEmail.PublishMessage(es)
+			errOne := broker.PublishMessage(EmailSendQueue, es)
 			if errOne != nil {
 				fmt.Println(errOne)
 			}
 			//TODO: log and handle
 			fmt.Println(err)
-			d.Ack(false)
+			failJob(jobManager, ipfsFile.JobID, err)
+			broker.DeadLetterMessage(d, err)
 			continue
 		}
 		holdTimeInt, err := strconv.ParseInt(ipfsFile.HoldTimeInMonths, 10, 64)
 		if err != nil {
 			fmt.Println("erorr parsing string to int ", err)
 			//TODO decide how to handle, etc..
-			d.Ack(false)
+			failJob(jobManager, ipfsFile.JobID, err)
+			broker.DeadLetterMessage(d, err)
 			continue
 		}
 		ipfsPin := IPFSPin{
@@ -387,7 +505,7 @@ func ProccessIPFSFiles(msgs <-chan amqp.Delivery, cfg *config.TemporalConfig, db
 			EthAddress:       ipfsFile.EthAddress,
 			HoldTimeInMonths: holdTimeInt,
 		}
-		err = qmFile.PublishMessageWithExchange(ipfsPin, PinExchange)
+		err = broker.PublishMessageWithExchange(ipfsPin, PinExchange)
 		if err != nil {
 			// this we will won't ack, or continue on since the file has already been added to ipfs and can be pinned seperately
 			fmt.Println("error publishing ipfs pin message to the pin exchange ", err)
@@ -398,7 +516,8 @@ func ProccessIPFSFiles(msgs <-chan amqp.Delivery, cfg *config.TemporalConfig, db
 		if err != nil {
 			//TODO: log and handle
 			fmt.Println(err)
-			d.Ack(false)
+			failJob(jobManager, ipfsFile.JobID, err)
+			broker.DeadLetterMessage(d, err)
 			continue
 		}
 		// TODO: decide whether or not we should email on "backend" failures
@@ -410,29 +529,48 @@ func ProccessIPFSFiles(msgs <-chan amqp.Delivery, cfg *config.TemporalConfig, db
 		if check.Error != nil && check.Error != gorm.ErrRecordNotFound {
 			//TODO: log and handle
 			fmt.Println(err)
-			d.Ack(false)
+			failJob(jobManager, ipfsFile.JobID, check.Error)
+			broker.DeadLetterMessage(d, check.Error)
 			continue
 		}
 		// TODO: add email notification indicating that the file was added, giving the content hash for the particular file
 		if check.Error == gorm.ErrRecordNotFound {
-			_, err = uploadManager.NewUpload(resp, "file", ipfsFile.NetworkName, ipfsFile.EthAddress, holdTimeInt)
+			_, err = uploadManager.NewUpload(resp, "file", ipfsFile.NetworkName, ipfsFile.EthAddress, "", holdTimeInt)
 			if err != nil {
 				//TODO decide how we should handle this
 				fmt.Println("error creating new upload in database ", err)
-				d.Ack(false)
+				failJob(jobManager, ipfsFile.JobID, err)
+				broker.DeadLetterMessage(d, err)
 				continue
 			}
+			saveFileName(uploadManager, resp, ipfsFile.NetworkName, ipfsFile.EthAddress, ipfsFile.FileName)
+			publishFileWebhookEvent(broker, &ipfsFile, resp)
+			completeJob(jobManager, ipfsFile.JobID, resp)
 			d.Ack(false)
 			continue
 		}
-		_, err = uploadManager.UpdateUpload(holdTimeInt, ipfsFile.EthAddress, resp, ipfsFile.NetworkName)
+		_, err = uploadManager.UpdateUpload(holdTimeInt, ipfsFile.EthAddress, resp, ipfsFile.NetworkName, "")
 		if err != nil {
 			//TODO decide how to handle
 			fmt.Println("error updating upload in database ", err)
-			d.Ack(false)
+			failJob(jobManager, ipfsFile.JobID, err)
+			broker.DeadLetterMessage(d, err)
 			continue
 		}
+		saveFileName(uploadManager, resp, ipfsFile.NetworkName, ipfsFile.EthAddress, ipfsFile.FileName)
+		publishFileWebhookEvent(broker, &ipfsFile, resp)
+		completeJob(jobManager, ipfsFile.JobID, resp)
 		d.Ack(false)
 	}
 	return nil
 }
+
+// publishFileWebhookEvent is used to notify webhooks that a file was added, along with its content hash
+func publishFileWebhookEvent(p MessagePublisher, ipfsFile *IPFSFile, cid string) {
+	publishWebhookEvent(p, models.WebhookEventFileAdded, ipfsFile.EthAddress, map[string]interface{}{
+		"cid":          cid,
+		"network_name": ipfsFile.NetworkName,
+		"object_name":  ipfsFile.ObjectName,
+		"job_id":       ipfsFile.JobID,
+	})
+}
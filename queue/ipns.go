@@ -2,6 +2,7 @@ package queue
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -10,7 +11,6 @@ import (
 
 	"github.com/RTradeLtd/Temporal/rtfs"
 	"github.com/jinzhu/gorm"
-	"github.com/streadway/amqp"
 )
 
 // IPNSEntry is used to hold relevant information needed to process IPNS entry creation requests
@@ -22,10 +22,11 @@ type IPNSEntry struct {
 	Key         string        `json:"key"`
 	EthAddress  string        `json:"eth_address"`
 	NetworkName string        `json:"network_name"`
+	JobID       string        `json:"job_id,omitempty"`
 }
 
 // ProcessIPNSEntryCreationRequests is used to process IPNS entry creation requests
-func ProcessIPNSEntryCreationRequests(msgs <-chan amqp.Delivery, db *gorm.DB, cfg *config.TemporalConfig) error {
+func ProcessIPNSEntryCreationRequests(msgs <-chan Delivery, broker Broker, db *gorm.DB, cfg *config.TemporalConfig) error {
 	ipfsManager, err := rtfs.Initialize("", "")
 	err = ipfsManager.CreateKeystoreManager()
 	if err != nil {
@@ -34,22 +35,19 @@ func ProcessIPNSEntryCreationRequests(msgs <-chan amqp.Delivery, db *gorm.DB, cf
 	ipnsManager := models.NewIPNSManager(db)
 	userManager := models.NewUserManager(db)
 	networkManager := models.NewHostedIPFSNetworkManager(db)
-	qmEmail, err := Initialize(IpnsEntryQueue, cfg.RabbitMQ.URL)
-	if err != nil {
-		return err
-	}
+	jobManager := models.NewJobManager(db)
 	for d := range msgs {
 		fmt.Println("ipns entry creation request detected")
 		ie := IPNSEntry{}
 		fmt.Println("unmarshaling response")
 		err = json.Unmarshal(d.Body, &ie)
 		if err != nil {
-			//TODO log and handle
 			fmt.Println("error unmarshaling ipns entry struct ", err)
-			d.Ack(false)
+			broker.DeadLetterMessage(d, err)
 			continue
 		}
 		fmt.Println("response unmarshaled")
+		startJob(jobManager, ie.JobID)
 		apiURL := ""
 		if ie.NetworkName != "public" {
 			fmt.Println("private ipfs network detected")
@@ -57,7 +55,8 @@ func ProcessIPNSEntryCreationRequests(msgs <-chan amqp.Delivery, db *gorm.DB, cf
 			if err != nil {
 				//TODO log and handle, decide how we should handle
 				fmt.Println("error checking for private network acess ", err)
-				d.Ack(false)
+				failJob(jobManager, ie.JobID, err)
+				broker.DeadLetterMessage(d, err)
 				continue
 			}
 			if !canAccess {
@@ -69,12 +68,13 @@ func ProcessIPNSEntryCreationRequests(msgs <-chan amqp.Delivery, db *gorm.DB, cf
 					ContentType:  "",
 					EthAddresses: addresses,
 				}
-				err = qmEmail.PublishMessage(es)
+				err = broker.PublishMessage(EmailSendQueue, es)
 				if err != nil {
 					//TODO log and handle
 					fmt.Println("error publishing message ", err)
 				}
 				fmt.Println("unauthorized access to private net ", ie.NetworkName)
+				failJob(jobManager, ie.JobID, errors.New("unauthorized access to private network"))
 				d.Ack(false)
 				continue
 			}
@@ -82,7 +82,8 @@ func ProcessIPNSEntryCreationRequests(msgs <-chan amqp.Delivery, db *gorm.DB, cf
 			if err != nil {
 				//TODO send email, log, handle
 				fmt.Println("erro getting API url by name ", err)
-				d.Ack(false)
+				failJob(jobManager, ie.JobID, err)
+				broker.DeadLetterMessage(d, err)
 				continue
 			}
 			apiURL = apiURLName
@@ -96,12 +97,13 @@ func ProcessIPNSEntryCreationRequests(msgs <-chan amqp.Delivery, db *gorm.DB, cf
 					ContentType:  "",
 					EthAddresses: addresses,
 				}
-				errOne := qmEmail.PublishMessage(es)
+				errOne := broker.PublishMessage(EmailSendQueue, es)
 				if errOne != nil {
 					fmt.Println("error publishing message ", err)
 				}
 				fmt.Println(err)
-				d.Ack(false)
+				failJob(jobManager, ie.JobID, err)
+				broker.DeadLetterMessage(d, err)
 				continue
 			}
 		}
@@ -118,12 +120,13 @@ func ProcessIPNSEntryCreationRequests(msgs <-chan amqp.Delivery, db *gorm.DB, cf
 				ContentType:  "",
 				EthAddresses: addresses,
 			}
-			errOne := qmEmail.PublishMessage(es)
+			errOne := broker.PublishMessage(EmailSendQueue, es)
 			if errOne != nil {
 				fmt.Println("error publishing message to email queue ", errOne)
 			}
 			fmt.Println("error publishing IPNS entry ", err)
-			d.Ack(false)
+			failJob(jobManager, ie.JobID, err)
+			broker.DeadLetterMessage(d, err)
 			continue
 		}
 		_, err = ipnsManager.UpdateIPNSEntry(response.Name, ie.CID, ie.Key, ie.NetworkName, ie.LifeTime, ie.TTL)
@@ -134,13 +137,21 @@ func ProcessIPNSEntryCreationRequests(msgs <-chan amqp.Delivery, db *gorm.DB, cf
 		fmt.Println("response published successfully")
 		fmt.Println("IPNS entry creation successful ", response)
 		//TODO update database
+		publishWebhookEvent(broker, models.WebhookEventIPNSPublished, ie.EthAddress, map[string]interface{}{
+			"name":         response.Name,
+			"cid":          ie.CID,
+			"key":          ie.Key,
+			"network_name": ie.NetworkName,
+			"job_id":       ie.JobID,
+		})
+		completeJob(jobManager, ie.JobID, response.Name)
 		d.Ack(false)
 	}
 	return nil
 }
 
 // ProcessIPNSUpdates is used to process any IPNS updates, saving them to the database
-func ProcessIPNSUpdates(msgs <-chan amqp.Delivery, db *gorm.DB) error {
+func ProcessIPNSUpdates(msgs <-chan Delivery, db *gorm.DB) error {
 	im := models.NewIPNSManager(db)
 	//um := models.NewUserManager(db)
 	manager, err := rtfs.Initialize("", "")
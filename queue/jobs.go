@@ -0,0 +1,58 @@
+package queue
+
+import (
+	"fmt"
+
+	"github.com/RTradeLtd/Temporal/models"
+)
+
+/*
+Helpers used by the consumers to report the state of the job a message belongs to.
+Messages published before jobs were introduced, or by other consumers, will not
+have a job id in which case these are a no-op
+*/
+
+// startJob is used to mark a job as being processed
+func startJob(jm *models.JobManager, jobID string) {
+	updateJob(jm, jobID, models.JobStateRunning, "")
+}
+
+// retryJob is used to move a job back to the queued state when a message
+// is not acknowledged due to a possibly temporary failure
+func retryJob(jm *models.JobManager, jobID string, err error) {
+	updateJob(jm, jobID, models.JobStateQueued, fmt.Sprintf("retrying after error: %s", err))
+}
+
+// failJob is used to mark a job as failed
+func failJob(jm *models.JobManager, jobID string, err error) {
+	updateJob(jm, jobID, models.JobStateFailed, err.Error())
+}
+
+// completeJob is used to mark a job as succeeded, storing its result
+func completeJob(jm *models.JobManager, jobID, result string) {
+	if jobID == "" {
+		return
+	}
+	if err := jm.CompleteJob(jobID, result); err != nil {
+		fmt.Println("error completing job ", err)
+	}
+}
+
+// retryOrFailJob is used to retry a message after a possibly temporary failure,
+// failing its job once the message can no longer be retried
+func retryOrFailJob(broker Broker, jm *models.JobManager, d Delivery, jobID string, err error) {
+	if broker.RetryMessage(d, err) {
+		retryJob(jm, jobID, err)
+	} else {
+		failJob(jm, jobID, err)
+	}
+}
+
+func updateJob(jm *models.JobManager, jobID, state, errMsg string) {
+	if jobID == "" {
+		return
+	}
+	if err := jm.UpdateJobState(jobID, state, errMsg); err != nil {
+		fmt.Println("error updating job state ", err)
+	}
+}
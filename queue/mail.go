@@ -6,7 +6,6 @@ import (
 
 	"github.com/RTradeLtd/Temporal/config"
 	"github.com/RTradeLtd/Temporal/mail"
-	"github.com/streadway/amqp"
 )
 
 var (
@@ -30,6 +29,18 @@ var (
 	PaymentConfirmationFailedSubject = "Payment Confirmation Failed"
 	// PaymentConfirmationFailedContent is a content used when a payment confirmation failure occurs
 	PaymentConfirmationFailedContent = "Payment failed for content hash %s with error %s"
+	// EmailVerificationSubject is a subject used when asking a user to verify their email address
+	EmailVerificationSubject = "Verify your Temporal email address"
+	// EmailVerificationContent is the content used when asking a user to verify their email address, formatted with the token and its expiry
+	EmailVerificationContent = "Welcome to Temporal! To verify your email address, and enable your account, submit the verification token %s to /api/v1/verify/email. The token expires at %s"
+	// PasswordResetSubject is a subject used when a user has requested a password reset
+	PasswordResetSubject = "Reset your Temporal password"
+	// PasswordResetContent is the content used when a user has requested a password reset, formatted with the token and its expiry
+	PasswordResetContent = "A password reset was requested for your Temporal account. To choose a new password, submit the reset token %s to /api/v1/password/reset/confirm. The token expires at %s. If you did not request this, you can ignore this email"
+	// AccountLockedSubject is a subject used when an account is locked out by failed logins
+	AccountLockedSubject = "Your Temporal account has been locked"
+	// AccountLockedContent is the content used when an account is locked out by failed logins, formatted with when the lockout ends
+	AccountLockedContent = "Your Temporal account has been locked after too many failed logins, and can be logged in to again at %s. If these logins were not you, we recommend resetting your password through /api/v1/password/reset"
 )
 
 // EmailSend is a helper struct used to contained formatted content ot send as an email
@@ -41,7 +52,7 @@ type EmailSend struct {
 }
 
 // ProcessMailSends is a function used to process mail send queue messages
-func ProcessMailSends(msgs <-chan amqp.Delivery, tCfg *config.TemporalConfig) error {
+func ProcessMailSends(msgs <-chan Delivery, broker Broker, tCfg *config.TemporalConfig) error {
 	mm, err := mail.GenerateMailManager(tCfg)
 	if err != nil {
 		return err
@@ -52,7 +63,7 @@ func ProcessMailSends(msgs <-chan amqp.Delivery, tCfg *config.TemporalConfig) er
 		err = json.Unmarshal(d.Body, &es)
 		if err != nil {
 			fmt.Println("error unmarshaling", err)
-			d.Ack(false)
+			broker.DeadLetterMessage(d, err)
 			continue
 		}
 		emails := make(map[string]string)
@@ -61,7 +72,6 @@ func ProcessMailSends(msgs <-chan amqp.Delivery, tCfg *config.TemporalConfig) er
 			if err != nil {
 				//TODO: decide on how this should be handled
 				fmt.Println("error finding email address for user", err)
-				d.Ack(false)
 				continue
 			}
 			emails[v] = resp[v]
@@ -74,6 +84,7 @@ func ProcessMailSends(msgs <-chan amqp.Delivery, tCfg *config.TemporalConfig) er
 			}
 		}
 		fmt.Println("emails sent")
+		d.Ack(false)
 	}
 	return nil
 }
@@ -3,6 +3,7 @@ package queue
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 
@@ -15,7 +16,6 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/jinzhu/gorm"
-	"github.com/streadway/amqp"
 )
 
 type PinPaymentConfirmation struct {
@@ -43,7 +43,7 @@ type PinPaymentSubmission struct {
 
 // ProcessPinPaymentConfirmation is used to process pin payment confirmations to inject content into TEMPORAL
 // currently only supprots the private IPFS network
-func ProcessPinPaymentConfirmation(msgs <-chan amqp.Delivery, db *gorm.DB, ipcPath, paymentContractAddress string, cfg *config.TemporalConfig) error {
+func ProcessPinPaymentConfirmation(msgs <-chan Delivery, broker Broker, db *gorm.DB, ipcPath, paymentContractAddress string, cfg *config.TemporalConfig) error {
 	fmt.Println("dialing")
 	client, err := ethclient.Dial(ipcPath)
 	if err != nil {
@@ -56,14 +56,6 @@ func ProcessPinPaymentConfirmation(msgs <-chan amqp.Delivery, db *gorm.DB, ipcPa
 		fmt.Println("error generating payment contract", err)
 		return err
 	}
-	qmEmail, err := Initialize(EmailSendQueue, cfg.RabbitMQ.URL)
-	if err != nil {
-		return err
-	}
-	qmIpfs, err := Initialize(IpfsPinQueue, cfg.RabbitMQ.URL)
-	if err != nil {
-		return err
-	}
 	paymentManager := models.NewPinPaymentManager(db)
 
 	for d := range msgs {
@@ -71,24 +63,23 @@ func ProcessPinPaymentConfirmation(msgs <-chan amqp.Delivery, db *gorm.DB, ipcPa
 		ppc := &PinPaymentConfirmation{}
 		err = json.Unmarshal(d.Body, ppc)
 		if err != nil {
-			//TODO handle
 			fmt.Println(err)
-			d.Ack(false)
+			broker.DeadLetterMessage(d, err)
 			continue
 		}
 		tx, isPending, err := client.TransactionByHash(context.Background(), common.HexToHash(ppc.TxHash))
 		if err != nil {
-			//TODO handle
 			fmt.Println(err)
-			// could be temporary error, so lets not ack
+			// could be temporary error, so lets retry
+			broker.RetryMessage(d, err)
 			continue
 		}
 		if isPending {
 			_, err := bind.WaitMined(context.Background(), client, tx)
 			if err != nil {
-				//TODO handle
 				fmt.Println(err)
-				// could be a temporary error so lets not ack
+				// could be a temporary error so lets retry
+				broker.RetryMessage(d, err)
 				continue
 			}
 		}
@@ -102,20 +93,20 @@ func ProcessPinPaymentConfirmation(msgs <-chan amqp.Delivery, db *gorm.DB, ipcPa
 				ContentType:  "",
 				EthAddresses: addresses,
 			}
-			err = qmEmail.PublishMessage(es)
+			err = broker.PublishMessage(EmailSendQueue, es)
 			if err != nil {
 				fmt.Println("error publishing message ", err)
 			}
-			// the message was improperly formatted so its garbagio
+			// the message was improperly formatted so it can never be processed
 			fmt.Println("unable to convert string to big int")
-			d.Ack(false)
+			broker.DeadLetterMessage(d, errors.New("unable to convert string to big int"))
 			continue
 		}
 		payment, err := contract.Payments(nil, common.HexToAddress(ppc.EthAddress), numberBig)
 		if err != nil {
-			// TODO handle
 			fmt.Println(err)
-			// could be a temporary issue, so lets not ack
+			// could be a temporary issue, so lets retry
+			broker.RetryMessage(d, err)
 			continue
 		}
 		fmt.Printf("Payment struct \n%+v\n", payment)
@@ -129,7 +120,7 @@ func ProcessPinPaymentConfirmation(msgs <-chan amqp.Delivery, db *gorm.DB, ipcPa
 				ContentType:  "",
 				EthAddresses: addresses,
 			}
-			err = qmEmail.PublishMessage(es)
+			err = broker.PublishMessage(EmailSendQueue, es)
 			if err != nil {
 				fmt.Println("error publishing message ", err)
 			}
@@ -141,9 +132,8 @@ func ProcessPinPaymentConfirmation(msgs <-chan amqp.Delivery, db *gorm.DB, ipcPa
 		}
 		paymentFromDatabase, err := paymentManager.RetrieveLatestPayment(ppc.EthAddress)
 		if err != nil {
-			//TODO: decide how we should handle
 			fmt.Println("failed to retrieve latest payment ", err)
-			d.Ack(false)
+			broker.DeadLetterMessage(d, err)
 			continue
 		}
 		// decide whether or not this should be handled here, or injected into the pin queue...
@@ -153,10 +143,11 @@ func ProcessPinPaymentConfirmation(msgs <-chan amqp.Delivery, db *gorm.DB, ipcPa
 			NetworkName:      paymentFromDatabase.NetworkName,
 			EthAddress:       ppc.EthAddress,
 			HoldTimeInMonths: paymentFromDatabase.HoldTimeInMonths,
+			PaymentNumber:    ppc.PaymentNumber,
 		}
 
 		// DECIDE HOW WE SHOULD HANDLE FAILURES
-		err = qmIpfs.PublishMessageWithExchange(ip, PinExchange)
+		err = broker.PublishMessageWithExchange(ip, PinExchange)
 		if err != nil {
 			addresses := []string{}
 			addresses = append(addresses, ppc.EthAddress)
@@ -166,15 +157,20 @@ func ProcessPinPaymentConfirmation(msgs <-chan amqp.Delivery, db *gorm.DB, ipcPa
 				ContentType:  "",
 				EthAddresses: addresses,
 			}
-			errOne := qmEmail.PublishMessage(es)
+			errOne := broker.PublishMessage(EmailSendQueue, es)
 			if errOne != nil {
 				fmt.Println("error publishing email to queue", errOne)
 			}
-			//TODO log and handle
 			fmt.Println("error publishing pin to queue ", err)
-			d.Ack(false)
+			broker.DeadLetterMessage(d, err)
 			continue
 		}
+		publishWebhookEvent(broker, models.WebhookEventPaymentConfirmed, ppc.EthAddress, map[string]interface{}{
+			"content_hash":   ppc.ContentHash,
+			"tx_hash":        ppc.TxHash,
+			"payment_number": ppc.PaymentNumber,
+			"network_name":   paymentFromDatabase.NetworkName,
+		})
 		d.Ack(false)
 	}
 	return nil
@@ -184,7 +180,7 @@ func ProcessPinPaymentConfirmation(msgs <-chan amqp.Delivery, db *gorm.DB, ipcPa
 // while functional, this route isn't recommended as there are security risks involved. This will be upgraded over time so we can try
 // to implement a more secure method. However keep in mind, this will always be "insecure". We may transition
 // to letting the user sign the transactino, and we can broadcast the signed transaction
-func ProcessPinPaymentSubmissions(msgs <-chan amqp.Delivery, db *gorm.DB, ipcPath, paymentContractAddress string) error {
+func ProcessPinPaymentSubmissions(msgs <-chan Delivery, broker Broker, db *gorm.DB, ipcPath, paymentContractAddress string) error {
 	client, err := ethclient.Dial(ipcPath)
 	if err != nil {
 		return err
@@ -204,14 +200,14 @@ func ProcessPinPaymentSubmissions(msgs <-chan amqp.Delivery, db *gorm.DB, ipcPat
 		err = json.Unmarshal(d.Body, &pps)
 		if err != nil {
 			fmt.Println("error unmarshaling", err)
-			d.Ack(false)
+			broker.DeadLetterMessage(d, err)
 			continue
 		}
 		k := keystore.Key{}
 		err = k.UnmarshalJSON(pps.PrivateKey)
 		if err != nil {
 			fmt.Println("error unmarshaling private key", err)
-			d.Ack(false)
+			broker.DeadLetterMessage(d, err)
 			continue
 		}
 		auth := bind.NewKeyedTransactor(k.PrivateKey)
@@ -224,34 +220,36 @@ func ProcessPinPaymentSubmissions(msgs <-chan amqp.Delivery, db *gorm.DB, ipcPat
 		num, valid := new(big.Int).SetString(pps.Number, 10)
 		if !valid {
 			fmt.Println("unable to convert payment number from string to big int")
-			d.Ack(false)
+			broker.DeadLetterMessage(d, errors.New("unable to convert payment number from string to big int"))
 			continue
 		}
 		amount, valid := new(big.Int).SetString(pps.ChargeAmount, 10)
 		if !valid {
 			fmt.Println("unable to convert charge amount from string to big int")
-			d.Ack(false)
+			broker.DeadLetterMessage(d, errors.New("unable to convert charge amount from string to big int"))
 			continue
 		}
 		auth.GasLimit = 275000
 		tx, err := contract.MakePayment(auth, h, v, r, s, num, method, amount, prefixed)
 		if err != nil {
-			// this could be a temporary error so we wont ack it
+			// this could be a temporary error so we will retry
 			fmt.Println("error making payment", err)
+			broker.RetryMessage(d, err)
 			continue
 		}
 		fmt.Println("successfully sent payment transaction, waiting for it to be mined")
 		_, err = bind.WaitMined(context.Background(), client, tx)
 		if err != nil {
-			// this could be a temporary error, so we wont ack it
+			// this could be a temporary error, so we will retry
 			fmt.Println("error waiting for tx to be mined", err)
+			broker.RetryMessage(d, err)
 			continue
 		}
 		paymentStruct, err := contract.Payments(nil, auth.From, num)
 		if err != nil {
 			//TODO: add error handling (msg client via email notifying failure)
 			fmt.Println("error retrieving payments", err)
-			d.Ack(false)
+			broker.DeadLetterMessage(d, err)
 			continue
 		}
 		if paymentStruct.State != 1 {
@@ -262,14 +260,14 @@ func ProcessPinPaymentSubmissions(msgs <-chan amqp.Delivery, db *gorm.DB, ipcPat
 		paymentFromDB, err := ppm.FindPaymentByNumberAndAddress(num.String(), auth.From.String())
 		if err != nil {
 			fmt.Println("erorr reading payment from database", err)
-			d.Ack(false)
+			broker.DeadLetterMessage(d, err)
 			continue
 		}
 		contentHash := paymentFromDB.ContentHash
 		err = manager.Pin(contentHash)
 		if err != nil {
 			fmt.Println("error pinning to IPFS", err)
-			d.Ack(false)
+			broker.RetryMessage(d, err)
 			continue
 		}
 		fmt.Println("Content pinned to IPFS")
@@ -0,0 +1,261 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+var (
+	// DefaultPublisherPoolSize is the number of idle channels kept open by a publisher
+	DefaultPublisherPoolSize = 10
+	// PublishConfirmTimeout is how long we will wait for rabbitmq to confirm a published message
+	PublishConfirmTimeout = time.Second * 10
+	// ReconnectBaseDelay is the delay before the first attempt to reconnect to rabbitmq, doubling on every failure
+	ReconnectBaseDelay = time.Second
+	// ReconnectMaxDelay is the longest we will wait between attempts to reconnect to rabbitmq
+	ReconnectMaxDelay = time.Second * 30
+)
+
+// Publisher is a long-lived publisher which is safe to share between goroutines.
+// It keeps a pool of channels in confirm mode on a single connection, and reconnects,
+// redeclaring any queues and exchanges, whenever the connection to rabbitmq is lost
+type Publisher struct {
+	connectionURL string
+	mux           sync.Mutex
+	conn          *amqp.Connection
+	// declared tracks the queues and exchanges declared on the current connection
+	declared map[string]bool
+	channels chan *confirmChannel
+}
+
+// confirmChannel is a channel in confirm mode, along with the connection it was opened on
+type confirmChannel struct {
+	conn     *amqp.Connection
+	ch       *amqp.Channel
+	confirms chan amqp.Confirmation
+}
+
+// NewPublisher is used to connect to rabbitmq and generate our publisher
+func NewPublisher(connectionURL string, poolSize int) (*Publisher, error) {
+	p := &Publisher{
+		connectionURL: connectionURL,
+		channels:      make(chan *confirmChannel, poolSize),
+	}
+	if _, err := p.getConnection(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ConnectionURL is used to retrieve the url of the rabbitmq server we publish to
+func (p *Publisher) ConnectionURL() string {
+	return p.connectionURL
+}
+
+// PublishMessage is used to publish a message to the given queue, waiting for rabbitmq to confirm it
+func (p *Publisher) PublishMessage(queueName string, body interface{}) error {
+	return p.publish("", queueName, queueName, body)
+}
+
+// PublishMessageWithExchange is used to publish a message to the given exchange, waiting for rabbitmq to confirm it
+func (p *Publisher) PublishMessageWithExchange(body interface{}, exchangeName string) error {
+	routingKey := ""
+	switch exchangeName {
+	case PinExchange:
+		routingKey = PinExchangeKey
+	case PinRemovalExchange:
+		routingKey = PinRemovalExchangeKey
+	case ClusterPinExchange:
+		break
+	default:
+		return errors.New("invalid exchange name provided")
+	}
+	return p.publish(exchangeName, routingKey, exchangeName, body)
+}
+
+// Close is used to close the connection of the publisher
+func (p *Publisher) Close() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
+
+// publish is used to publish a message, retrying once on a fresh channel
+// as the channel or connection may have been closed since it was last used
+func (p *Publisher) publish(exchange, routingKey, declareName string, body interface{}) error {
+	bodyMarshaled, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		err = p.tryPublish(exchange, routingKey, declareName, bodyMarshaled)
+		if err == nil {
+			return nil
+		}
+		fmt.Println("error publishing message ", err)
+	}
+	return err
+}
+
+func (p *Publisher) tryPublish(exchange, routingKey, declareName string, body []byte) error {
+	cc, err := p.getChannel()
+	if err != nil {
+		return err
+	}
+	if err = p.declare(cc, declareName); err != nil {
+		cc.ch.Close()
+		return err
+	}
+	err = cc.ch.Publish(
+		exchange,   // exchange
+		routingKey, // routing key
+		false,      // mandatory
+		false,      // immediate
+		amqp.Publishing{
+			DeliveryMode: amqp.Persistent,
+			ContentType:  "text/plain",
+			Body:         body,
+		},
+	)
+	if err != nil {
+		cc.ch.Close()
+		return err
+	}
+	select {
+	case confirm, ok := <-cc.confirms:
+		if !ok {
+			return errors.New("channel closed before message was confirmed")
+		}
+		p.putChannel(cc)
+		if !confirm.Ack {
+			return errors.New("message was not acknowledged by rabbitmq")
+		}
+		return nil
+	case <-time.After(PublishConfirmTimeout):
+		// a late confirmation would be mistaken for the next message, so the channel can't be reused
+		cc.ch.Close()
+		return errors.New("timed out waiting for message to be confirmed")
+	}
+}
+
+// declare is used to declare the queue, or exchange, being published to once per connection
+func (p *Publisher) declare(cc *confirmChannel, name string) error {
+	p.mux.Lock()
+	declared := p.conn == cc.conn && p.declared[name]
+	p.mux.Unlock()
+	if declared {
+		return nil
+	}
+	qm := &QueueManager{Connection: cc.conn, Channel: cc.ch}
+	var err error
+	switch name {
+	case PinExchange:
+		err = qm.DeclareIPFSPinExchange()
+	case PinRemovalExchange:
+		err = qm.DeclareIPFSPinRemovalExchange()
+	case ClusterPinExchange:
+		err = qm.DeclareIPFSClusterPinExchange()
+	default:
+		err = qm.declareTopology(name)
+	}
+	if err != nil {
+		return err
+	}
+	p.mux.Lock()
+	if p.conn == cc.conn {
+		p.declared[name] = true
+	}
+	p.mux.Unlock()
+	return nil
+}
+
+// getChannel is used to take an idle channel from the pool, opening a new one if there are none
+func (p *Publisher) getChannel() (*confirmChannel, error) {
+	conn, err := p.getConnection()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		select {
+		case cc := <-p.channels:
+			if cc.conn == conn {
+				return cc, nil
+			}
+			// the channel belongs to a connection which has since been closed
+		default:
+			ch, err := conn.Channel()
+			if err != nil {
+				return nil, err
+			}
+			if err = ch.Confirm(false); err != nil {
+				ch.Close()
+				return nil, err
+			}
+			return &confirmChannel{
+				conn:     conn,
+				ch:       ch,
+				confirms: ch.NotifyPublish(make(chan amqp.Confirmation, 1)),
+			}, nil
+		}
+	}
+}
+
+// putChannel is used to return a channel to the pool, closing it if the pool is full
+func (p *Publisher) putChannel(cc *confirmChannel) {
+	select {
+	case p.channels <- cc:
+	default:
+		cc.ch.Close()
+	}
+}
+
+// getConnection is used to retrieve the current connection, dialing rabbitmq if we aren't connected
+func (p *Publisher) getConnection() (*amqp.Connection, error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.conn != nil {
+		return p.conn, nil
+	}
+	conn, err := setupConnection(p.connectionURL)
+	if err != nil {
+		return nil, err
+	}
+	p.conn = conn
+	p.declared = make(map[string]bool)
+	go p.watchConnection(conn)
+	return conn, nil
+}
+
+// watchConnection is used to reconnect to rabbitmq once the given connection has been closed
+func (p *Publisher) watchConnection(conn *amqp.Connection) {
+	reason, ok := <-conn.NotifyClose(make(chan *amqp.Error, 1))
+	if !ok {
+		// the connection was closed by us
+		return
+	}
+	fmt.Println("publisher lost connection to rabbitmq ", reason)
+	p.mux.Lock()
+	if p.conn == conn {
+		p.conn = nil
+	}
+	p.mux.Unlock()
+	backoff := ReconnectBaseDelay
+	for {
+		if _, err := p.getConnection(); err == nil {
+			fmt.Println("publisher reconnected to rabbitmq")
+			return
+		}
+		time.Sleep(backoff)
+		if backoff < ReconnectMaxDelay {
+			backoff = backoff * 2
+		}
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"time"
 
 	"github.com/RTradeLtd/Temporal/config"
 	"github.com/RTradeLtd/Temporal/database"
@@ -21,6 +21,10 @@ var PinPaymentSubmissionQueue = "pin-payment-submission-queue"
 var EmailSendQueue = "email-send-queue"
 var IpnsEntryQueue = "ipns-entry-queue"
 var IpfsPinRemovalQueue = "ipns-pin-removal-queue"
+var WebhookSendQueue = "webhook-send-queue"
+
+// DefaultConsumerConcurrency is the number of messages processed in parallel by consumers without any configuration
+var DefaultConsumerConcurrency = 1
 
 var AdminEmail = "temporal.reports@rtradetechnologies.com"
 
@@ -29,6 +33,8 @@ type QueueManager struct {
 	Connection *amqp.Connection
 	Channel    *amqp.Channel
 	Queue      *amqp.Queue
+	// connectionURL is kept so that consumers can reconnect
+	connectionURL string
 }
 
 // IPFSPin is a struct used when sending pin request
@@ -37,6 +43,9 @@ type IPFSPin struct {
 	NetworkName      string `json:"network_name"`
 	EthAddress       string `json:"eth_address"`
 	HoldTimeInMonths int64  `json:"hold_time_in_months"`
+	JobID            string `json:"job_id,omitempty"`
+	// PaymentNumber is the number of the pin payment made for the pin, if any
+	PaymentNumber string `json:"payment_number,omitempty"`
 }
 
 type IPFSFile struct {
@@ -45,12 +54,17 @@ type IPFSFile struct {
 	EthAddress       string `json:"eth_address"`
 	NetworkName      string `json:"network_name"`
 	HoldTimeInMonths string `json:"hold_time_in_months"`
+	JobID            string `json:"job_id,omitempty"`
+	FileName         string `json:"file_name,omitempty"`
 }
 
+// IPFSPinRemoval is used to release a user's pin of content, which is only unpinned
+// once no other user holds an active retention of it
 type IPFSPinRemoval struct {
 	ContentHash string `json:"content_hash"`
 	NetworkName string `json:"network_name"`
 	EthAddress  string `json:"eth_address"`
+	JobID       string `json:"job_id,omitempty"`
 }
 
 // DatabaseFileAdd is a struct used when sending data to rabbitmq
@@ -59,6 +73,9 @@ type DatabaseFileAdd struct {
 	HoldTimeInMonths int64  `json:"hold_time_in_months"`
 	UploaderAddress  string `json:"uploader_address"`
 	NetworkName      string `json:"network_name"`
+	FileName         string `json:"file_name,omitempty"`
+	// UploadType is the type of the upload, defaulting to a file
+	UploadType string `json:"upload_type,omitempty"`
 }
 
 // DatabasePinAdd is a struct used wehn sending data to rabbitmq
@@ -86,37 +103,58 @@ func Initialize(queueName, connectionURL string) (*QueueManager, error) {
 	if err != nil {
 		return nil, err
 	}
-	qm := QueueManager{Connection: conn}
+	qm := QueueManager{Connection: conn, connectionURL: connectionURL}
 	if err := qm.OpenChannel(); err != nil {
 		return nil, err
 	}
-	// Declare Non Default exchanges for the particular queue
+	if err := qm.declareTopology(queueName); err != nil {
+		return nil, err
+	}
+	return &qm, nil
+}
+
+// declareTopology is used to declare the queue, along with any non default exchanges for the particular queue
+func (qm *QueueManager) declareTopology(queueName string) error {
+	var err error
 	switch queueName {
 	case IpfsPinRemovalQueue:
 		err = qm.DeclareIPFSPinRemovalExchange()
-		if err != nil {
-			return nil, err
-		}
 	case IpfsPinQueue:
 		err = qm.DeclareIPFSPinExchange()
-		if err != nil {
-			return nil, err
-		}
 	case ClusterPinExchange:
 		err = qm.DeclareIPFSClusterPinExchange()
-		if err != nil {
-			return nil, err
-		}
 	case FileExchange:
 		err = qm.DeclareIPFSFileExchange()
-		if err != nil {
-			return nil, err
-		}
 	}
-	if err := qm.DeclareQueue(queueName); err != nil {
-		return nil, err
+	if err != nil {
+		return err
+	}
+	return qm.DeclareQueue(queueName)
+}
+
+// reconnect is used to reconnect to rabbitmq after the connection has been lost,
+// retrying with a backoff until it succeeds
+func (qm *QueueManager) reconnect() {
+	queueName := qm.Queue.Name
+	backoff := ReconnectBaseDelay
+	for {
+		conn, err := setupConnection(qm.connectionURL)
+		if err == nil {
+			qm.Connection = conn
+			if err = qm.OpenChannel(); err == nil {
+				if err = qm.declareTopology(queueName); err == nil {
+					fmt.Println("reconnected to rabbitmq")
+					return
+				}
+			}
+			conn.Close()
+		}
+		fmt.Println("error reconnecting to rabbitmq ", err)
+		time.Sleep(backoff)
+		if backoff < ReconnectMaxDelay {
+			backoff = backoff * 2
+		}
 	}
-	return &qm, nil
 }
 
 func setupConnection(connectionURL string) (*amqp.Connection, error) {
@@ -138,8 +176,13 @@ func (qm *QueueManager) OpenChannel() error {
 
 // DeclareQueue is used to declare a queue for which messages will be sent to
 func (qm *QueueManager) DeclareQueue(queueName string) error {
+	if err := qm.declareDeadLetterTopology(queueName); err != nil {
+		return err
+	}
 	// we declare the queue as durable so that even if rabbitmq server stops
-	// our messages won't be lost
+	// our messages won't be lost. The arguments must match those the queue was first
+	// declared with, so messages rejected by rabbitmq are routed to the dead-letter
+	// exchange with the policies set by scripts/rabbitmq_dead_letter_policies.sh
 	q, err := qm.Channel.QueueDeclare(
 		queueName, // name
 		true,      // durable
@@ -155,30 +198,36 @@ func (qm *QueueManager) DeclareQueue(queueName string) error {
 	return nil
 }
 
-// ConsumeMessage is used to consume messages that are sent to the queue
-// Question, do we really want to ack messages that fail to be processed?
-// Perhaps the error was temporary, and we allow it to be retried?
+// ConsumeMessage is used to consume messages that are sent to the queue, using rabbitmq as our broker
 func (qm *QueueManager) ConsumeMessage(consumer, dbPass, dbURL, ethKeyFile, ethKeyPass, dbUser string, cfg *config.TemporalConfig) error {
 	db, err := database.OpenDBConnection(dbPass, dbURL, dbUser)
 	if err != nil {
 		return err
 	}
-	// we use a false flag for auto-ack since we will use
-	// manually acknowledgemnets to ensure message delivery
-	// even if a worker dies
-	msgs, err := qm.Channel.Consume(
-		qm.Queue.Name, // queue
-		consumer,      // consumer
-		false,         // auto-ack
-		false,         // exclusive
-		false,         // no-local
-		false,         // no-wait
-		nil,           // args
-	)
+	if err = CheckDeadLetterPolicy(qm.connectionURL, cfg.RabbitMQ.ManagementURL, qm.Queue.Name); err != nil {
+		return err
+	}
+	broker, err := NewAMQPBroker(qm.connectionURL)
 	if err != nil {
 		return err
 	}
+	defer broker.Close()
+	// consume with the connection we already have to the queue
+	broker.queues[qm.Queue.Name] = qm
+	return ConsumeMessages(broker, qm.Queue.Name, consumer, db, cfg)
+}
 
+// consume is used to start consuming messages from the queue, limiting the number of
+// unacknowledged messages rabbitmq will send us to prefetch
+func (qm *QueueManager) consume(consumer string, prefetch int) (<-chan amqp.Delivery, error) {
+	err := qm.Channel.Qos(
+		prefetch, // prefetch count
+		0,        // prefetch size
+		false,    // global
+	)
+	if err != nil {
+		return nil, err
+	}
 	// ifs the queue is using an exchange, we will need to bind the queue to the exchange
 	switch qm.Queue.Name {
 	case IpfsPinRemovalQueue:
@@ -200,53 +249,21 @@ func (qm *QueueManager) ConsumeMessage(consumer, dbPass, dbURL, ethKeyFile, ethK
 	default:
 		break
 	}
-	// check the queue name
-	switch qm.Queue.Name {
-	// only parse database pin requests
-	case DatabasePinAddQueue:
-		ProcessDatabasePinAdds(msgs, db)
-	// only parse datbase file requests
-	case DatabaseFileAddQueue:
-		ProcessDatabaseFileAdds(msgs, db)
-	case IpfsPinQueue:
-		ProccessIPFSPins(msgs, db, cfg)
-	case IpfsFileQueue:
-		err = ProccessIPFSFiles(msgs, cfg, db)
-		if err != nil {
-			return err
-		}
-	case PinPaymentConfirmationQueue:
-		err = ProcessPinPaymentConfirmation(msgs, db, cfg.Ethereum.Connection.IPC.Path, cfg.Ethereum.Contracts.PaymentContractAddress, cfg)
-		if err != nil {
-			return err
-		}
-	case PinPaymentSubmissionQueue:
-		err = ProcessPinPaymentSubmissions(msgs, db, cfg.Ethereum.Connection.IPC.Path, cfg.Ethereum.Contracts.PaymentContractAddress)
-		if err != nil {
-			return err
-		}
-	case EmailSendQueue:
-		fmt.Println("processing mail sends")
-		err = ProcessMailSends(msgs, cfg)
-		if err != nil {
-			return err
-		}
-	case IpnsEntryQueue:
-		fmt.Println("processing IPNS entry creation requests")
-		err = ProcessIPNSEntryCreationRequests(msgs, db, cfg)
-		if err != nil {
-			return err
-		}
-	case IpfsPinRemovalQueue:
-		fmt.Println("processing ipfs pin removals")
-		err = ProcessIPFSPinRemovals(msgs, cfg, db)
-		if err != nil {
-			return err
-		}
-	default:
-		log.Fatal("invalid queue name")
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	// we use a false flag for auto-ack since we will use
+	// manually acknowledgemnets to ensure message delivery
+	// even if a worker dies
+	return qm.Channel.Consume(
+		qm.Queue.Name, // queue
+		consumer,      // consumer
+		false,         // auto-ack
+		false,         // exclusive
+		false,         // no-local
+		false,         // no-wait
+		nil,           // args
+	)
 }
 
 //PublishMessageWithExchange is used to publish a message to a given exchange
@@ -1,7 +1,9 @@
 package queue_test
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/RTradeLtd/Temporal/config"
 	"github.com/RTradeLtd/Temporal/queue"
@@ -66,6 +68,25 @@ func TestQueueInitialization(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+
+	_, err = queue.Initialize(queue.WebhookSendQueue, cfg.RabbitMQ.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	payload := []byte(`{"event":"pin.completed"}`)
+	sig := queue.SignWebhookPayload("secret", payload)
+	if sig != queue.SignWebhookPayload("secret", payload) {
+		t.Fatal("signature is not deterministic")
+	}
+	if sig == queue.SignWebhookPayload("other-secret", payload) {
+		t.Fatal("signature does not depend on the secret")
+	}
+	if len(sig) != len("sha256=")+64 {
+		t.Fatal("unexpected signature length ", len(sig))
+	}
 }
 
 func TestQueues(t *testing.T) {
@@ -92,3 +113,88 @@ func TestQueues(t *testing.T) {
 	}
 
 }
+
+func TestRetryDelay(t *testing.T) {
+	for retry := 2; retry <= queue.MaxRetries; retry++ {
+		if queue.RetryDelay(retry) != queue.RetryDelay(retry-1)*2 {
+			t.Fatal("retry delay does not double for retry ", retry)
+		}
+	}
+	if queue.RetryQueueName(queue.IpfsPinQueue, 1) == queue.RetryQueueName(queue.IpfsPinQueue, 2) {
+		t.Fatal("retry queues are not unique")
+	}
+}
+
+func TestMemoryBroker(t *testing.T) {
+	broker := queue.NewMemoryBroker()
+	defer broker.Close()
+	pin := queue.IPFSPin{
+		CID:              testCID,
+		NetworkName:      "public",
+		EthAddress:       testEthAddress,
+		HoldTimeInMonths: 10,
+	}
+	if err := broker.PublishMessageWithExchange(pin, queue.PinExchange); err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := broker.Consume(queue.IpfsPinQueue, "test", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case d := <-msgs:
+		if d.Queue != queue.IpfsPinQueue {
+			t.Fatal("message delivered from the wrong queue ", d.Queue)
+		}
+		if err = d.Ack(false); err != nil {
+			t.Fatal(err)
+		}
+		if err = d.Ack(false); err == nil {
+			t.Fatal("message was acknowledged twice")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+	if err = broker.Cancel("test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-msgs; ok {
+		t.Fatal("deliveries channel was not closed after cancelling")
+	}
+}
+
+func TestMemoryBrokerRetry(t *testing.T) {
+	defer func(maxRetries int, baseDelay time.Duration) {
+		queue.MaxRetries = maxRetries
+		queue.RetryBaseDelay = baseDelay
+	}(queue.MaxRetries, queue.RetryBaseDelay)
+	queue.MaxRetries = 2
+	queue.RetryBaseDelay = time.Millisecond
+	broker := queue.NewMemoryBroker()
+	defer broker.Close()
+	if err := broker.PublishMessage(queue.EmailSendQueue, queue.EmailSend{Subject: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := broker.Consume(queue.EmailSendQueue, "test", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reason := errors.New("temporary failure")
+	for retry := 0; retry <= queue.MaxRetries; retry++ {
+		select {
+		case d := <-msgs:
+			if broker.RetryMessage(d, reason) != (retry < queue.MaxRetries) {
+				t.Fatal("unexpected retry result for retry ", retry)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for retry ", retry)
+		}
+	}
+	deadLetters := broker.DeadLetters(queue.EmailSendQueue)
+	if len(deadLetters) != 1 {
+		t.Fatal("expected one dead-letter, got ", len(deadLetters))
+	}
+	if deadLetters[0].RetryCount != queue.MaxRetries || deadLetters[0].LastError != reason.Error() {
+		t.Fatal("dead-letter does not record the retries ", deadLetters[0])
+	}
+}
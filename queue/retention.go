@@ -0,0 +1,211 @@
+package queue
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/config"
+	"github.com/RTradeLtd/Temporal/models"
+	"github.com/RTradeLtd/Temporal/rtfs"
+	"github.com/RTradeLtd/Temporal/rtfs_cluster"
+	ipfsapi "github.com/RTradeLtd/go-ipfs-api"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// DefaultRetentionInterval is how often the retention worker runs when no interval is configured
+	DefaultRetentionInterval = time.Hour * 24
+	// DefaultRetentionGracePeriod is how long expired content is kept when no grace period is configured
+	DefaultRetentionGracePeriod = time.Hour * 24 * 7
+)
+
+// ExpiredUpload is an upload whose hold time, and grace period, have passed
+type ExpiredUpload struct {
+	Hash               string    `json:"hash"`
+	NetworkName        string    `json:"network_name"`
+	GarbageCollectDate time.Time `json:"garbage_collect_date"`
+	SizeInBytes        int64     `json:"size_in_bytes"`
+	Removed            bool      `json:"removed"`
+	Error              string    `json:"error,omitempty"`
+}
+
+// RetentionReport is the outcome of a retention run. For dry-runs, nothing is
+// removed and BytesReclaimed is the number of bytes that would have been reclaimed
+type RetentionReport struct {
+	DryRun         bool            `json:"dry_run"`
+	ExpiredBefore  time.Time       `json:"expired_before"`
+	Uploads        []ExpiredUpload `json:"uploads"`
+	BytesReclaimed int64           `json:"bytes_reclaimed"`
+	Failed         int             `json:"failed"`
+}
+
+// RetentionWorker is used to unpin content whose hold time has expired, from both
+// the ipfs node of the network it was uploaded to, and the cluster for the public network
+type RetentionWorker struct {
+	DB          *gorm.DB
+	GracePeriod time.Duration
+}
+
+// retentionNode holds the connections, and pins, of a network for the duration of a run
+type retentionNode struct {
+	ipfs        *rtfs.IpfsManager
+	pins        map[string]bool
+	cluster     *rtfs_cluster.ClusterManager
+	clusterPins map[string]bool
+	err         error
+}
+
+// NewRetentionWorker is used to generate our retention worker
+func NewRetentionWorker(db *gorm.DB, gracePeriod time.Duration) *RetentionWorker {
+	return &RetentionWorker{DB: db, GracePeriod: gracePeriod}
+}
+
+// RetentionSettings is used to load the interval and grace period of the retention worker, falling back to the defaults
+func RetentionSettings(cfg *config.TemporalConfig) (time.Duration, time.Duration, error) {
+	interval := DefaultRetentionInterval
+	gracePeriod := DefaultRetentionGracePeriod
+	var err error
+	if cfg.Retention.Interval != "" {
+		interval, err = time.ParseDuration(cfg.Retention.Interval)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if cfg.Retention.GracePeriod != "" {
+		gracePeriod, err = time.ParseDuration(cfg.Retention.GracePeriod)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return interval, gracePeriod, nil
+}
+
+// StartRetentionWorker is used to remove expired content on the configured interval, until the process exits
+func StartRetentionWorker(db *gorm.DB, cfg *config.TemporalConfig) error {
+	interval, gracePeriod, err := RetentionSettings(cfg)
+	if err != nil {
+		return err
+	}
+	rw := NewRetentionWorker(db, gracePeriod)
+	for {
+		report, err := rw.Run(cfg.Retention.DryRun)
+		if err != nil {
+			fmt.Println("error running retention ", err)
+		} else {
+			fmt.Printf("retention run complete, dry run %v, %v uploads expired, %v failed, %v bytes reclaimed\n",
+				report.DryRun, len(report.Uploads), report.Failed, report.BytesReclaimed)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// Run is used to unpin all uploads which expired before the grace period, removing them from the database
+// once they have been unpinned. Uploads which fail are left in the database, so they are retried on the next run
+func (rw *RetentionWorker) Run(dryRun bool) (*RetentionReport, error) {
+	um := models.NewUploadManager(rw.DB)
+	expiredBefore := time.Now().Add(-rw.GracePeriod)
+	uploads, err := um.FindExpiredUploads(expiredBefore)
+	if err != nil {
+		return nil, err
+	}
+	report := &RetentionReport{
+		DryRun:        dryRun,
+		ExpiredBefore: expiredBefore,
+		Uploads:       []ExpiredUpload{},
+	}
+	// connections to each network are shared by all of the uploads for that network
+	nodes := make(map[string]*retentionNode)
+	for i := range uploads {
+		expired := ExpiredUpload{
+			Hash:               uploads[i].Hash,
+			NetworkName:        uploads[i].NetworkName,
+			GarbageCollectDate: uploads[i].GarbageCollectDate,
+		}
+		stillExpired, err := rw.expire(um, nodes, &uploads[i], &expired, expiredBefore, dryRun)
+		if err != nil {
+			fmt.Printf("error removing expired upload %s on network %s %s\n", expired.Hash, expired.NetworkName, err)
+			expired.Error = err.Error()
+			report.Failed++
+		} else if !stillExpired {
+			// the upload was re-pinned, or extended, since the run started
+			continue
+		} else {
+			expired.Removed = !dryRun
+			report.BytesReclaimed += expired.SizeInBytes
+		}
+		report.Uploads = append(report.Uploads, expired)
+	}
+	return report, nil
+}
+
+// expire is used to unpin an upload from its network, and remove it from the database. The upload is removed
+// from the database first, as long as it is still expired, and false is returned when it no longer is
+func (rw *RetentionWorker) expire(um *models.UploadManager, nodes map[string]*retentionNode, upload *models.Upload, expired *ExpiredUpload, expiredBefore time.Time, dryRun bool) (bool, error) {
+	node := rw.node(nodes, upload.NetworkName)
+	if node.err != nil {
+		return false, node.err
+	}
+	// content which isn't pinned may not be on the node, and would be fetched from the network to stat it
+	if node.pins[upload.Hash] {
+		size, err := node.ipfs.GetObjectFileSizeInBytes(upload.Hash)
+		if err != nil {
+			return false, err
+		}
+		expired.SizeInBytes = int64(size)
+	}
+	if dryRun {
+		return true, nil
+	}
+	return um.DeleteExpiredUpload(upload, expiredBefore, func() error {
+		if node.pins[upload.Hash] {
+			if err := node.ipfs.Shell.Unpin(upload.Hash); err != nil {
+				return err
+			}
+		}
+		if node.clusterPins[upload.Hash] {
+			return node.cluster.RemovePinFromCluster(upload.Hash)
+		}
+		return nil
+	})
+}
+
+// node is used to connect to the network, loading its pins, if we haven't already done so during this run
+func (rw *RetentionWorker) node(nodes map[string]*retentionNode, networkName string) *retentionNode {
+	if node, ok := nodes[networkName]; ok {
+		return node
+	}
+	node := &retentionNode{}
+	nodes[networkName] = node
+	apiURL := ""
+	if networkName != "public" {
+		apiURL, node.err = models.NewHostedIPFSNetworkManager(rw.DB).GetAPIURLByName(networkName)
+		if node.err != nil {
+			return node
+		}
+	}
+	node.ipfs, node.err = rtfs.Initialize("", apiURL)
+	if node.err != nil {
+		return node
+	}
+	pins, err := node.ipfs.Shell.Pins()
+	if err != nil {
+		node.err = err
+		return node
+	}
+	node.pins = make(map[string]bool)
+	for hash, info := range pins {
+		// indirect pins are only pinned as part of another pin, so they can't be unpinned themselves
+		if info.Type != ipfsapi.IndirectPin {
+			node.pins[hash] = true
+		}
+	}
+	// only the public network is replicated with the cluster
+	if networkName == "public" {
+		node.cluster, node.err = rtfs_cluster.Initialize()
+		if node.err != nil {
+			return node
+		}
+		node.clusterPins, node.err = node.cluster.ListPins()
+	}
+	return node
+}
@@ -0,0 +1,212 @@
+package queue
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/models"
+	"github.com/RTradeLtd/Temporal/utils"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// WebhookMaxAttempts is the number of times we will try to deliver an event to a webhook, with
+	// failed attempts retried after the delays of the retry queues
+	WebhookMaxAttempts = 5
+	// WebhookTimeout is how long we will wait for a webhook endpoint to respond
+	WebhookTimeout = time.Second * 10
+	// WebhookSignatureHeader is the header containing the HMAC-SHA256 signature of the request body
+	WebhookSignatureHeader = "X-Temporal-Signature"
+	// WebhookEventHeader is the header containing the name of the event being delivered
+	WebhookEventHeader = "X-Temporal-Event"
+	// WebhookDeliveryHeader is the header containing the id of the delivery, which remains the same across retries
+	WebhookDeliveryHeader = "X-Temporal-Delivery"
+)
+
+// WebhookEvent is used to notify a user's webhooks of something that happened
+type WebhookEvent struct {
+	Event      string                 `json:"event"`
+	EthAddress string                 `json:"eth_address"`
+	Timestamp  time.Time              `json:"timestamp"`
+	Data       map[string]interface{} `json:"data"`
+	// DeliveryID is set once the event has been fanned out to one of the user's webhooks
+	DeliveryID uint `json:"delivery_id,omitempty"`
+}
+
+// ProcessWebhookSends is used to process webhook send queue messages. Events are fanned out into a delivery
+// for every webhook of the user that is subscribed to it, each of which is sent to the queue as its own message
+// so that failed deliveries are retried through the broker, without holding up those that succeeded
+func ProcessWebhookSends(msgs <-chan Delivery, broker Broker, db *gorm.DB) error {
+	webhookManager := models.NewWebhookManager(db)
+	// hosts are checked again as they are dialed, as they may resolve differently than when the webhook was registered
+	client := utils.NewPublicHTTPClient(WebhookTimeout)
+	for d := range msgs {
+		fmt.Println("webhook event detected")
+		we := WebhookEvent{}
+		err := json.Unmarshal(d.Body, &we)
+		if err != nil {
+			fmt.Println("error unmarshaling", err)
+			broker.DeadLetterMessage(d, err)
+			continue
+		}
+		if we.DeliveryID == 0 {
+			fanOutWebhookEvent(broker, d, webhookManager, we)
+		} else {
+			deliverWebhook(broker, d, client, webhookManager, we.DeliveryID)
+		}
+	}
+	return nil
+}
+
+// fanOutWebhookEvent is used to record a pending delivery of an event for every webhook subscribed to it,
+// sending each delivery to the queue. The event is retried until every delivery has been recorded and queued,
+// skipping webhooks the event was already fanned out to, and is only acknowledged once they all have been
+func fanOutWebhookEvent(broker Broker, d Delivery, wm *models.WebhookManager, we WebhookEvent) {
+	hooks, err := wm.FindWebhooksForEvent(we.EthAddress, we.Event)
+	if err != nil {
+		// could be a temporary database error, so lets retry
+		fmt.Println("error finding webhooks for user ", err)
+		broker.RetryMessage(d, err)
+		return
+	}
+	payload, err := json.Marshal(we)
+	if err != nil {
+		fmt.Println("error marshaling webhook event ", err)
+		broker.DeadLetterMessage(d, err)
+		return
+	}
+	for i := range hooks {
+		_, err := wm.FindDeliveryForEvent(hooks[i].ID, we.Event, string(payload))
+		if err == nil {
+			// the delivery was recorded and queued before the event was retried
+			continue
+		}
+		if err != gorm.ErrRecordNotFound {
+			fmt.Println("error finding webhook delivery ", err)
+			broker.RetryMessage(d, err)
+			return
+		}
+		delivery, err := wm.NewDelivery(&hooks[i], we.Event, string(payload))
+		if err != nil {
+			fmt.Println("error recording webhook delivery ", err)
+			broker.RetryMessage(d, err)
+			return
+		}
+		send := we
+		send.DeliveryID = delivery.ID
+		if err = broker.PublishMessage(WebhookSendQueue, send); err != nil {
+			fmt.Println("error queueing webhook delivery ", err)
+			// the delivery is removed so that it is recorded and queued again when the event is retried
+			if errOne := wm.DeleteDelivery(delivery); errOne != nil {
+				fmt.Println("error removing webhook delivery ", errOne)
+			}
+			broker.RetryMessage(d, err)
+			return
+		}
+	}
+	d.Ack(false)
+}
+
+// deliverWebhook is used to attempt a delivery, recording the outcome of the attempt in the delivery log.
+// Failed attempts are retried with the backoff of the broker, so the message is only acknowledged once
+// the delivery has succeeded, or been given up on
+func deliverWebhook(broker Broker, d Delivery, client *http.Client, wm *models.WebhookManager, deliveryID uint) {
+	delivery, err := wm.FindDeliveryByID(deliveryID)
+	if err == gorm.ErrRecordNotFound {
+		broker.DeadLetterMessage(d, err)
+		return
+	}
+	if err != nil {
+		fmt.Println("error finding webhook delivery ", err)
+		broker.RetryMessage(d, err)
+		return
+	}
+	if delivery.Delivered {
+		// the message was redelivered after the delivery succeeded
+		d.Ack(false)
+		return
+	}
+	hook, err := wm.FindWebhookByID(delivery.EthAddress, delivery.WebhookID)
+	if err == gorm.ErrRecordNotFound {
+		fmt.Printf("webhook %v was removed, dropping delivery %v\n", delivery.WebhookID, delivery.ID)
+		d.Ack(false)
+		return
+	}
+	if err != nil {
+		fmt.Println("error finding webhook ", err)
+		broker.RetryMessage(d, err)
+		return
+	}
+	delivery.Attempts++
+	delivery.LastAttemptAt = time.Now()
+	statusCode, err := postWebhook(client, *hook, delivery, []byte(delivery.Payload))
+	delivery.StatusCode = statusCode
+	if err == nil {
+		delivery.Delivered = true
+		delivery.Error = ""
+	} else {
+		delivery.Error = err.Error()
+	}
+	if errOne := wm.UpdateDelivery(delivery); errOne != nil {
+		fmt.Println("error updating webhook delivery ", errOne)
+	}
+	switch {
+	case delivery.Delivered:
+		d.Ack(false)
+	case delivery.Attempts < WebhookMaxAttempts:
+		// should the broker run out of retries first, the message is dead-lettered
+		broker.RetryMessage(d, err)
+	default:
+		fmt.Printf("giving up on webhook delivery %v after %v attempts\n", delivery.ID, delivery.Attempts)
+		d.Ack(false)
+	}
+}
+
+func postWebhook(client *http.Client, hook models.Webhook, delivery *models.WebhookDelivery, payload []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, fmt.Sprint(delivery.ID))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(hook.Secret, payload))
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook endpoint responded with status %v", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignWebhookPayload is used to generate the signature sent with a webhook delivery.
+// Receivers can verify a delivery by computing the HMAC-SHA256 of the raw request body
+// with their signing secret, and comparing it against the signature header
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// publishWebhookEvent is used by the workers to send an event to the webhook queue.
+// Failures are only logged, as webhooks must not affect the processing of the original message
+func publishWebhookEvent(p MessagePublisher, event, ethAddress string, data map[string]interface{}) {
+	we := WebhookEvent{
+		Event:      event,
+		EthAddress: ethAddress,
+		Timestamp:  time.Now(),
+		Data:       data,
+	}
+	if err := p.PublishMessage(WebhookSendQueue, we); err != nil {
+		fmt.Println("error publishing webhook event ", err)
+	}
+}
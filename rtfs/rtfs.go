@@ -1,28 +1,45 @@
 package rtfs
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	ipfsapi "github.com/RTradeLtd/go-ipfs-api"
+	files "github.com/ipfs/go-ipfs-cmdkit/files"
 )
 
 var ClusterPubSubTopic = "ipfs-cluster"
 
+// DefaultConnectionURL is the api of the ipfs node used when no connection url is given
+var DefaultConnectionURL = "localhost:5001"
+
 type IpfsManager struct {
 	Shell           *ipfsapi.Shell
 	PubSub          *ipfsapi.PubSubSubscription
 	KeystoreManager *KeystoreManager
 	KeystoreEnabled bool
 	PubTopic        string
+	// connectionURL is kept for requests the shell doesn't support
+	connectionURL string
 }
 
 func Initialize(pubTopic, connectionURL string) (*IpfsManager, error) {
 	if pubTopic == "" {
 		pubTopic = ClusterPubSubTopic
 	}
-	manager := IpfsManager{}
+	if connectionURL == "" {
+		connectionURL = DefaultConnectionURL
+	}
+	manager := IpfsManager{connectionURL: connectionURL}
 	manager.Shell = EstablishShellWithNode(connectionURL)
 	_, err := manager.Shell.ID()
 	if err != nil {
@@ -34,7 +51,7 @@ func Initialize(pubTopic, connectionURL string) (*IpfsManager, error) {
 
 func EstablishShellWithNode(url string) *ipfsapi.Shell {
 	if url == "" {
-		shell := ipfsapi.NewShell("localhost:5001")
+		shell := ipfsapi.NewShell(DefaultConnectionURL)
 		return shell
 	}
 	shell := ipfsapi.NewShell(url)
@@ -94,6 +111,99 @@ func (im *IpfsManager) GetObjectFileSizeInBytes(key string) (int, error) {
 	return stat.CumulativeSize, nil
 }
 
+// FileSize is used to retrieve the size in bytes of a unixfs file. Unlike the cumulative
+// size of the object, this doesn't include the size of the dag nodes the file is stored in
+func (im *IpfsManager) FileSize(hash string) (int64, error) {
+	object, err := im.Shell.FileList(hash)
+	if err != nil {
+		return 0, err
+	}
+	if object.Type != "File" {
+		return 0, fmt.Errorf("%s is a %s, not a file", hash, strings.ToLower(object.Type))
+	}
+	return int64(object.Size), nil
+}
+
+// CatRange is used to read length bytes of a file, starting at offset. A length of 0 reads
+// to the end of the file. Callers need to close the returned reader after usage
+func (im *IpfsManager) CatRange(hash string, offset, length int64) (io.ReadCloser, error) {
+	req := ipfsapi.NewRequest(context.Background(), im.connectionURL, "cat", hash)
+	req.Opts["offset"] = strconv.FormatInt(offset, 10)
+	if length > 0 {
+		req.Opts["length"] = strconv.FormatInt(length, 10)
+	}
+	resp, err := req.Send(http.DefaultClient)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		resp.Close()
+		return nil, resp.Error
+	}
+	return resp.Output, nil
+}
+
+// AddedObject is a file, or directory, added to ipfs. Name is the path of the object within the directory it was added in
+type AddedObject struct {
+	Name string
+	Hash string
+}
+
+// AddDirectoryContents is used to add, and pin, the contents of a local directory to ipfs, wrapped in a single directory
+// so that files keep their paths. The hash of the wrapping directory is returned, along with every object added within it
+func (im *IpfsManager) AddDirectoryContents(dir string) (string, []AddedObject, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(entries) == 0 {
+		return "", nil, errors.New("directory is empty")
+	}
+	var children []files.File
+	defer func() {
+		for _, child := range children {
+			child.Close()
+		}
+	}()
+	for _, entry := range entries {
+		// hidden files, such as .well-known, are part of the directory like any other
+		child, err := files.NewSerialFile(entry.Name(), filepath.Join(dir, entry.Name()), true, entry)
+		if err != nil {
+			return "", nil, err
+		}
+		children = append(children, child)
+	}
+	req := ipfsapi.NewRequest(context.Background(), im.connectionURL, "add")
+	req.Body = files.NewMultiFileReader(files.NewSliceFile("", dir, children), true)
+	req.Opts["r"] = "true"
+	req.Opts["wrap-with-directory"] = "true"
+	req.Opts["progress"] = "false"
+	resp, err := req.Send(http.DefaultClient)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Close()
+	if resp.Error != nil {
+		return "", nil, resp.Error
+	}
+	var added []AddedObject
+	decoder := json.NewDecoder(resp.Output)
+	for {
+		var object AddedObject
+		if err = decoder.Decode(&object); err == io.EOF {
+			break
+		} else if err != nil {
+			return "", nil, err
+		}
+		added = append(added, object)
+	}
+	// the wrapping directory is added last
+	if len(added) == 0 {
+		return "", nil, errors.New("no results received")
+	}
+	return added[len(added)-1].Hash, added[:len(added)-1], nil
+}
+
 // ObjectStat is used to retrieve the stats about an object
 func (im *IpfsManager) ObjectStat(key string) (*ipfsapi.ObjectStats, error) {
 	stat, err := im.Shell.ObjectStat(key)
@@ -105,16 +215,36 @@ func (im *IpfsManager) ObjectStat(key string) (*ipfsapi.ObjectStats, error) {
 
 // ParseLocalPinsForHash checks whether or not a pin is present
 func (im *IpfsManager) ParseLocalPinsForHash(hash string) (bool, error) {
-	pins, err := im.Shell.Pins()
+	pinType, err := im.PinLs(hash)
 	if err != nil {
 		return false, err
 	}
-	info := pins[hash]
+	return pinType != "", nil
+}
 
-	if info.Type != "" {
-		return true, nil
+// PinLs is used to get the type of the pin of a hash, only listing the pins of that hash rather than every pin
+// of the node. An empty type is returned when the hash isn't pinned
+func (im *IpfsManager) PinLs(hash string) (string, error) {
+	resp, err := ipfsapi.NewRequest(context.Background(), im.connectionURL, "pin/ls", hash).Send(http.DefaultClient)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Close()
+	if resp.Error != nil {
+		if strings.Contains(resp.Error.Message, "is not pinned") {
+			return "", nil
+		}
+		return "", resp.Error
+	}
+	raw := struct{ Keys map[string]ipfsapi.PinInfo }{}
+	if err = json.NewDecoder(resp.Output).Decode(&raw); err != nil {
+		return "", err
+	}
+	// the hash is keyed by its cid, which may be encoded differently to the hash given
+	for _, v := range raw.Keys {
+		return v.Type, nil
 	}
-	return false, nil
+	return "", nil
 }
 
 // SubscribeToPubSubTopic is used to subscribe to a pubsub topic
@@ -2,6 +2,8 @@ package rtfs_test
 
 import (
 	"fmt"
+	"io/ioutil"
+	"strings"
 	"testing"
 
 	"github.com/RTradeLtd/Temporal/rtfs"
@@ -54,6 +56,36 @@ func TestObjectStat(t *testing.T) {
 	}
 }
 
+func TestCatRange(t *testing.T) {
+	im, err := rtfs.Initialize("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := im.Shell.Add(strings.NewReader("hello temporal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := im.FileSize(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len("hello temporal")) {
+		t.Fatalf("unexpected file size %v", size)
+	}
+	reader, err := im.CatRange(hash, 6, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "temp" {
+		t.Fatalf("unexpected range %s", data)
+	}
+}
+
 func TestParseLocalPinsForHash(t *testing.T) {
 	im, err := rtfs.Initialize("", "")
 	if err != nil {
@@ -98,6 +98,19 @@ func (cm *ClusterManager) RemovePinFromCluster(cidString string) error {
 	return nil
 }
 
+// ListPins is used to retrieve the hashes of every pin tracked by the cluster
+func (cm *ClusterManager) ListPins() (map[string]bool, error) {
+	pins, err := cm.Client.Allocations()
+	if err != nil {
+		return nil, err
+	}
+	hashes := make(map[string]bool)
+	for _, v := range pins {
+		hashes[v.Cid.String()] = true
+	}
+	return hashes, nil
+}
+
 // FetchLocalStatus is used to fetch the local status of all pins
 func (cm *ClusterManager) FetchLocalStatus() (map[*gocid.Cid]string, error) {
 	var response = make(map[*gocid.Cid]string)
@@ -132,6 +145,21 @@ func (cm *ClusterManager) GetStatusForCidLocally(cidString string) (*api.GlobalP
 	return &status, nil
 }
 
+// IsTracked is used to check whether or not a cid is tracked by the cluster, only fetching the status of
+// that cid rather than every pin. Peers report untracked cids as unpinned
+func (cm *ClusterManager) IsTracked(cidString string) (bool, error) {
+	status, err := cm.GetStatusForCidGlobally(cidString)
+	if err != nil {
+		return false, err
+	}
+	for _, v := range status.PeerMap {
+		if v.Status != api.TrackerStatusUnpinned {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // GetStatusForCidGlobally is used to fetch the global status for a particular cid
 func (cm *ClusterManager) GetStatusForCidGlobally(cidString string) (*api.GlobalPinInfo, error) {
 	decoded, err := cm.DecodeHashString(cidString)
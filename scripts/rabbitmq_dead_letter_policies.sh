@@ -0,0 +1,22 @@
+#! /bin/bash
+
+# used to route messages rejected by rabbitmq consumers to the dead-letter exchange of their queue.
+# Dead-lettering is applied with policies, rather than queue arguments, so that queues declared
+# by earlier versions of temporal don't have to be deleted and declared again
+QUEUES=(
+    "dfa-queue"
+    "dpa-queue"
+    "ipfs-pin-queue"
+    "ipfs-file-queue"
+    "pin-payment-confirmation-queue"
+    "pin-payment-submission-queue"
+    "email-send-queue"
+    "ipns-entry-queue"
+    "ipns-pin-removal-queue"
+    "webhook-send-queue"
+)
+
+for QUEUE in "${QUEUES[@]}"; do
+    sudo rabbitmqctl set_policy --apply-to queues "temporal-dead-letter-$QUEUE" "^$QUEUE\$" \
+        "{\"dead-letter-exchange\":\"$QUEUE.dlx\",\"dead-letter-routing-key\":\"$QUEUE\"}"
+done
@@ -0,0 +1,339 @@
+package utils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/agl/ed25519"
+	jwtgo "gopkg.in/dgrijalva/jwt-go.v3"
+)
+
+/*
+Utilities for the asymmetric keys JWTs are signed with, and for publishing
+their public keys as a JSON Web Key Set (RFC 7517) so tokens can be verified elsewhere
+*/
+
+const (
+	// SigningAlgorithmRS256 signs tokens with RSA PKCS#1 v1.5 and SHA-256
+	SigningAlgorithmRS256 = "RS256"
+	// SigningAlgorithmEdDSA signs tokens with Ed25519 (RFC 8037)
+	SigningAlgorithmEdDSA = "EdDSA"
+	// rsaSigningKeyBits is the size of the rsa keys we generate
+	rsaSigningKeyBits = 2048
+)
+
+// oidEd25519 identifies ed25519 keys in PKCS#8 and PKIX encodings (RFC 8410), which crypto/x509 doesn't support
+var oidEd25519 = asn1.ObjectIdentifier{1, 3, 101, 112}
+
+// Ed25519PrivateKey is an ed25519 private key, the seed followed by the public key
+type Ed25519PrivateKey []byte
+
+// Ed25519PublicKey is an ed25519 public key
+type Ed25519PublicKey []byte
+
+// Public is used to return the public key of the private key
+func (k Ed25519PrivateKey) Public() crypto.PublicKey {
+	publicKey := make(Ed25519PublicKey, ed25519.PublicKeySize)
+	copy(publicKey, k[ed25519.PublicKeySize:])
+	return publicKey
+}
+
+// Sign is used to sign a message with the private key. Ed25519 signs messages rather than digests, so opts must be crypto.Hash(0)
+func (k Ed25519PrivateKey) Sign(_ io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != crypto.Hash(0) {
+		return nil, errors.New("ed25519 cannot sign hashed messages")
+	}
+	if len(k) != ed25519.PrivateKeySize {
+		return nil, errors.New("invalid ed25519 private key")
+	}
+	var privateKey [ed25519.PrivateKeySize]byte
+	copy(privateKey[:], k)
+	return ed25519.Sign(&privateKey, message)[:], nil
+}
+
+// verify is used to check a signature of a message was made by the private key of the public key
+func (k Ed25519PublicKey) verify(message, sig []byte) bool {
+	if len(k) != ed25519.PublicKeySize || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	var publicKey [ed25519.PublicKeySize]byte
+	var signature [ed25519.SignatureSize]byte
+	copy(publicKey[:], k)
+	copy(signature[:], sig)
+	return ed25519.Verify(&publicKey, message, &signature)
+}
+
+// pkcs8 is a PKCS#8 private key
+type pkcs8 struct {
+	Version    int
+	Algorithm  pkix.AlgorithmIdentifier
+	PrivateKey []byte
+}
+
+// subjectPublicKeyInfo is a PKIX public key
+type subjectPublicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// SigningMethodEdDSA implements the EdDSA signing method, which jwt-go doesn't provide
+var SigningMethodEdDSA = &signingMethodEdDSA{}
+
+type signingMethodEdDSA struct{}
+
+func init() {
+	jwtgo.RegisterSigningMethod(SigningMethodEdDSA.Alg(), func() jwtgo.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+// Alg is used to return the name of the algorithm used in the header of tokens
+func (m *signingMethodEdDSA) Alg() string {
+	return SigningAlgorithmEdDSA
+}
+
+// Sign is used to sign a token with an ed25519 private key
+func (m *signingMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(Ed25519PrivateKey)
+	if !ok {
+		return "", jwtgo.ErrInvalidKeyType
+	}
+	sig, err := privateKey.Sign(nil, []byte(signingString), crypto.Hash(0))
+	if err != nil {
+		return "", err
+	}
+	return jwtgo.EncodeSegment(sig), nil
+}
+
+// Verify is used to verify the signature of a token with an ed25519 public key
+func (m *signingMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	publicKey, ok := key.(Ed25519PublicKey)
+	if !ok {
+		return jwtgo.ErrInvalidKeyType
+	}
+	sig, err := jwtgo.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !publicKey.verify([]byte(signingString), sig) {
+		return errors.New("signature is invalid")
+	}
+	return nil
+}
+
+// JWK is the public key of a signing key, as published in a JSON Web Key Set
+type JWK struct {
+	KeyID     string `json:"kid"`
+	KeyType   string `json:"kty"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	// N and E are the modulus and exponent of rsa keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Curve and X are the curve and public key of ed25519 keys
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// GenerateSigningKey is used to generate a key for the given algorithm, returning the private and public keys PEM encoded
+func GenerateSigningKey(algorithm string) (string, string, error) {
+	var privateDER, publicDER []byte
+	var err error
+	switch algorithm {
+	case SigningAlgorithmRS256:
+		privateDER, publicDER, err = generateRSAKey()
+	case SigningAlgorithmEdDSA:
+		privateDER, publicDER, err = generateEd25519Key()
+	default:
+		return "", "", errors.New("unsupported signing algorithm")
+	}
+	if err != nil {
+		return "", "", err
+	}
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	return string(privatePEM), string(publicPEM), nil
+}
+
+// generateRSAKey is used to generate an rsa key, returning the PKCS#8 private key and PKIX public key
+func generateRSAKey() ([]byte, []byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, rsaSigningKeyBits)
+	if err != nil {
+		return nil, nil, err
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+	return privateDER, publicDER, nil
+}
+
+// generateEd25519Key is used to generate an ed25519 key, returning the PKCS#8 private key and PKIX public key (RFC 8410)
+func generateEd25519Key() ([]byte, []byte, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the private key is the seed, wrapped in an octet string
+	seed, err := asn1.Marshal(private[:ed25519.PublicKeySize])
+	if err != nil {
+		return nil, nil, err
+	}
+	privateDER, err := asn1.Marshal(pkcs8{Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidEd25519}, PrivateKey: seed})
+	if err != nil {
+		return nil, nil, err
+	}
+	publicDER, err := asn1.Marshal(subjectPublicKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidEd25519},
+		PublicKey: asn1.BitString{Bytes: public[:], BitLength: 8 * ed25519.PublicKeySize},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return privateDER, publicDER, nil
+}
+
+// ParseSigningKey is used to parse a PEM encoded private key generated by GenerateSigningKey
+func ParseSigningKey(privatePEM string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(privatePEM))
+	if block == nil {
+		return nil, errors.New("failed to decode private key")
+	}
+	var info pkcs8
+	if _, err := asn1.Unmarshal(block.Bytes, &info); err != nil {
+		return nil, err
+	}
+	if info.Algorithm.Algorithm.Equal(oidEd25519) {
+		var seed []byte
+		if _, err := asn1.Unmarshal(info.PrivateKey, &seed); err != nil {
+			return nil, err
+		}
+		if len(seed) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 private key")
+		}
+		// the public key is derived from the seed, giving the same key the seed was generated with
+		_, private, err := ed25519.GenerateKey(bytes.NewReader(seed))
+		if err != nil {
+			return nil, err
+		}
+		return Ed25519PrivateKey(private[:]), nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if key, ok := key.(*rsa.PrivateKey); ok {
+		return key, nil
+	}
+	return nil, errors.New("unsupported private key type")
+}
+
+// ParseVerificationKey is used to parse a PEM encoded public key generated by GenerateSigningKey
+func ParseVerificationKey(publicPEM string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicPEM))
+	if block == nil {
+		return nil, errors.New("failed to decode public key")
+	}
+	var info subjectPublicKeyInfo
+	if _, err := asn1.Unmarshal(block.Bytes, &info); err != nil {
+		return nil, err
+	}
+	if info.Algorithm.Algorithm.Equal(oidEd25519) {
+		if len(info.PublicKey.Bytes) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 public key")
+		}
+		return Ed25519PublicKey(info.PublicKey.Bytes), nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if key, ok := key.(*rsa.PublicKey); ok {
+		return key, nil
+	}
+	return nil, errors.New("unsupported public key type")
+}
+
+// PublicKeyToJWK is used to convert a public key to a JWK, identified by the given key id
+func PublicKeyToJWK(keyID string, publicKey crypto.PublicKey) (JWK, error) {
+	jwk := JWK{KeyID: keyID, Use: "sig"}
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.Algorithm = SigningAlgorithmRS256
+		jwk.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	case Ed25519PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Algorithm = SigningAlgorithmEdDSA
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(key)
+	default:
+		return JWK{}, errors.New("unsupported public key type")
+	}
+	return jwk, nil
+}
+
+// EncryptSigningKey is used to encrypt a PEM encoded private key before it is stored, using AES-256-GCM with a key
+// derived from the secret. The key id is authenticated along with it, so an encrypted key can't be moved to another key id
+func EncryptSigningKey(keyID, privatePEM string, secret []byte) (string, error) {
+	aead, err := signingKeyCipher(secret)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(privatePEM), []byte(keyID))), nil
+}
+
+// DecryptSigningKey is used to decrypt a private key encrypted by EncryptSigningKey
+func DecryptSigningKey(keyID, encrypted string, secret []byte) (string, error) {
+	aead, err := signingKeyCipher(secret)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("encrypted signing key is too short")
+	}
+	privatePEM, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return "", errors.New("failed to decrypt signing key")
+	}
+	return string(privatePEM), nil
+}
+
+// signingKeyCipher is used to create the cipher signing keys are encrypted with
+func signingKeyCipher(secret []byte) (cipher.AEAD, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("signing key encryption key is required")
+	}
+	key := sha256.Sum256(secret)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+/*
+Utilities used to make sure requests sent on behalf of users, such as webhook deliveries, only
+reach the public internet, and can't be pointed at our own ipfs api or other internal services
+*/
+
+// ErrNonPublicAddress is returned when a host resolves to a loopback, private, link-local or otherwise non public ip
+var ErrNonPublicAddress = errors.New("host must only resolve to public ip addresses")
+
+// nonPublicNetworks are the ranges, beyond loopback, link-local and multicast, which are not reachable on the public internet
+var nonPublicNetworks = parseCIDRs(
+	"0.0.0.0/8",       // this network
+	"10.0.0.0/8",      // private
+	"100.64.0.0/10",   // carrier grade nat
+	"172.16.0.0/12",   // private
+	"192.0.0.0/24",    // ietf protocol assignments
+	"192.0.2.0/24",    // documentation
+	"192.168.0.0/16",  // private
+	"198.18.0.0/15",   // benchmarking
+	"198.51.100.0/24", // documentation
+	"203.0.113.0/24",  // documentation
+	"240.0.0.0/4",     // reserved, and broadcast
+	"64:ff9b::/96",    // nat64, which can reach private ipv4 addresses
+	"2001:db8::/32",   // documentation
+	"fc00::/7",        // unique local
+)
+
+// IsPublicIP is used to check whether or not an ip address is reachable on the public internet
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, v := range nonPublicNetworks {
+		if v.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidatePublicURL is used to check that a url is http or https, and that its host only resolves to public ip addresses
+func ValidatePublicURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return errors.New("url must be http or https")
+	}
+	if parsed.Hostname() == "" {
+		return errors.New("url must have a host")
+	}
+	_, err = lookupPublicIPs(context.Background(), parsed.Hostname())
+	return err
+}
+
+// NewPublicHTTPClient is used to create an http client which only connects to public ip addresses. Hosts are
+// checked as they are dialed, and the checked address is the one connected to, so a host that resolves to
+// a public address when registered can't later be rebound to a private one. Proxies are not used, as the
+// proxy would be the one connecting to the host
+func NewPublicHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         publicDialContext(dialer),
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
+
+// publicDialContext is used to dial a host at the public ip addresses it resolves to
+func publicDialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := lookupPublicIPs(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			var conn net.Conn
+			conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+}
+
+// lookupPublicIPs is used to resolve a host, failing unless every address it resolves to is public
+func lookupPublicIPs(ctx context.Context, host string) ([]net.IP, error) {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("host did not resolve to any ip addresses")
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, v := range addrs {
+		if !IsPublicIP(v.IP) {
+			return nil, ErrNonPublicAddress
+		}
+		ips = append(ips, v.IP)
+	}
+	return ips, nil
+}
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, v := range cidrs {
+		_, network, err := net.ParseCIDR(v)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
@@ -0,0 +1,63 @@
+package utils_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/utils"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"8.8.8.8", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.242", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::1", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"::ffff:127.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if public := utils.IsPublicIP(net.ParseIP(tt.ip)); public != tt.public {
+			t.Errorf("expected IsPublicIP(%s) to be %v", tt.ip, tt.public)
+		}
+	}
+}
+
+func TestValidatePublicURL(t *testing.T) {
+	if err := utils.ValidatePublicURL("https://8.8.8.8/hook"); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{
+		"ftp://8.8.8.8/hook",
+		"http://127.0.0.1:5001/api/v0/pin/rm",
+		"http://[::1]/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://localhost/hook",
+	} {
+		if err := utils.ValidatePublicURL(v); err == nil {
+			t.Errorf("expected %s to be rejected", v)
+		}
+	}
+}
+
+func TestPublicHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	client := utils.NewPublicHTTPClient(time.Second * 5)
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatal("expected the client to refuse to connect to a loopback address")
+	}
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	crand "crypto/rand"
+	"encoding/hex"
 	"math/rand"
 	"time"
 )
@@ -46,3 +48,13 @@ func (u *RandomUtils) GenerateString(length int, charset string) string {
 	}
 	return string(b)
 }
+
+// GenerateSecureToken is used to generate a hex encoded token from numBytes
+// of cryptographically secure random data, suitable for identifiers and secrets
+func GenerateSecureToken(numBytes int) (string, error) {
+	b := make([]byte, numBytes)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -22,3 +22,20 @@ func TestRandomUtils(t *testing.T) {
 	}
 
 }
+
+func TestGenerateSecureToken(t *testing.T) {
+	token1, err := utils.GenerateSecureToken(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token1) != 32 {
+		t.Fatal("unexpected token length")
+	}
+	token2, err := utils.GenerateSecureToken(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token1 == token2 {
+		t.Fatal("generated two tokens that were the same")
+	}
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// PersonalMessageHash is used to hash a message the way personal_sign does, as described by EIP-191
+func PersonalMessageHash(message string) []byte {
+	prefixed := fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)
+	return crypto.Keccak256([]byte(prefixed))
+}
+
+// RecoverPersonalSignAddress is used to recover the address which signed a message with personal_sign.
+// The signature is hex encoded, and may use either 0/1 or 27/28 for its recovery id
+func RecoverPersonalSignAddress(message, signature string) (common.Address, error) {
+	if !strings.HasPrefix(signature, "0x") {
+		signature = "0x" + signature
+	}
+	sig, err := hexutil.Decode(signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if len(sig) != 65 {
+		return common.Address{}, errors.New("signature must be 65 bytes long")
+	}
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	if sig[64] > 1 {
+		return common.Address{}, errors.New("invalid signature recovery id")
+	}
+	pub, err := crypto.SigToPub(PersonalMessageHash(message), sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
@@ -0,0 +1,38 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/RTradeLtd/Temporal/utils"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestRecoverPersonalSignAddress(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	message := "sign in to temporal"
+	sig, err := crypto.Sign(utils.PersonalMessageHash(message), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// wallets return signatures with a recovery id of 27 or 28
+	sig[64] += 27
+	recovered, err := utils.RecoverPersonalSignAddress(message, hexutil.Encode(sig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovered != address {
+		t.Fatalf("recovered %s, expected %s", recovered.Hex(), address.Hex())
+	}
+	recovered, err = utils.RecoverPersonalSignAddress("a different message", hexutil.Encode(sig))
+	if err == nil && recovered == address {
+		t.Fatal("recovered the signing address for a message that was not signed")
+	}
+	if _, err = utils.RecoverPersonalSignAddress(message, "0x1234"); err == nil {
+		t.Fatal("expected an error for a short signature")
+	}
+}
@@ -0,0 +1,64 @@
+package utils_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RTradeLtd/Temporal/utils"
+)
+
+// the sha1 secret from RFC 6238, "12345678901234567890", base32 encoded
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateTOTPCode(t *testing.T) {
+	// the last six digits of the eight digit sha1 codes in RFC 6238
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		code, err := utils.GenerateTOTPCode(rfcSecret, utils.TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != tt.code {
+			t.Fatalf("got code %s at %v, expected %s", code, tt.unix, tt.code)
+		}
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	code, err := utils.GenerateTOTPCode(secret, utils.TOTPStep(now.Add(-utils.TOTPPeriod)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	step, valid := utils.ValidateTOTPCode(secret, code, now, 1)
+	if !valid {
+		t.Fatal("expected code from the previous step to be valid")
+	}
+	if step != utils.TOTPStep(now)-1 {
+		t.Fatal("wrong step returned for code")
+	}
+	if _, valid = utils.ValidateTOTPCode(secret, code, now.Add(utils.TOTPPeriod*2), 1); valid {
+		t.Fatal("expected code outside of the skew to be invalid")
+	}
+	if _, valid = utils.ValidateTOTPCode(secret, "12345", now, 1); valid {
+		t.Fatal("expected short code to be invalid")
+	}
+	uri := utils.TOTPURI("Temporal", "0xabc", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/Temporal:0xabc?") || !strings.Contains(uri, "secret="+secret) {
+		t.Fatalf("unexpected uri %s", uri)
+	}
+}